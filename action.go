@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/kinematics"
+)
+
+const (
+	// ticksTime is how long ArmLink takes to move for each unit of the delta
+	ticksTime = 16 * time.Millisecond
+	// settleTime is the pause after a move before the next step of an action
+	settleTime = 250 * time.Millisecond
+)
+
+// moveTime returns how long ArmLink takes to move with the delta
+func moveTime(delta uint8) time.Duration {
+	return time.Duration(delta) * ticksTime
+}
+
+// move is a step of an action
+type move struct {
+	name string
+	pose api.RobotPose
+}
+
+// gripperFor returns the gripper position leaving the width in mm between the fingers
+func gripperFor(as *api.ActionSettings, width float64) (uint16, *api.Problem) {
+	if width < 0 || width > as.Width {
+		return 0, &api.Problem{Detail: fmt.Sprintf("The width must be within [0, %v] mm", as.Width), Field: "width", Value: width}
+	}
+	open, closed := float64(as.Open), float64(as.Closed)
+	return uint16(math.Round(closed + (open-closed)*width/as.Width)), nil
+}
+
+// reach returns the pose putting the tip of the gripper at the point from above
+func reach(from api.RobotPose, p kinematics.Point) (api.RobotPose, *api.Problem) {
+	base, shoulder, elbow, wristAngle, err := kinematics.Inverse(p, kinematics.Down)
+	if err != nil {
+		return from, &api.Problem{Detail: "The point " + err.Error(), Value: p}
+	}
+	from.Base, from.Shoulder, from.Elbow, from.WristAngle = base, shoulder, elbow, wristAngle
+	return from, nil
+}
+
+// plan returns the moves of the action from the pose
+func (controller *Controller) plan(act api.Action, from api.RobotPose) ([]move, *api.Problem) {
+	as := &controller.ActionSettings
+	grip := as.Closed
+	if act.Width != nil {
+		width := *act.Width
+		if act.Name != api.ActionClose {
+			// hold the object firmly
+			width = math.Max(width-as.Squeeze, 0)
+		}
+		var p *api.Problem
+		if grip, p = gripperFor(as, width); p != nil {
+			p.Value = *act.Width
+			return nil, p
+		}
+	}
+
+	switch act.Name {
+	case api.ActionOpen:
+		from.Gripper = as.Open
+		return []move{{act.Name, from}}, nil
+	case api.ActionClose, api.ActionGrasp:
+		from.Gripper = grip
+		return []move{{act.Name, from}}, nil
+	}
+
+	// pick and place above the target
+	if act.X == nil || act.Y == nil || act.Z == nil {
+		return nil, &api.Problem{Detail: fmt.Sprintf("The %v needs the target in x, y and z", act.Name), Field: "z"}
+	}
+	approach := as.Approach
+	if act.Approach != nil {
+		approach = *act.Approach
+	}
+	if approach < 0 {
+		return nil, &api.Problem{Detail: "The approach height must not be negative", Field: "approach", Value: approach}
+	}
+	target := kinematics.Point{X: *act.X, Y: *act.Y, Z: *act.Z}
+	at, p := reach(from, target)
+	if p != nil {
+		return nil, p
+	}
+	above, p := reach(from, kinematics.Point{X: target.X, Y: target.Y, Z: target.Z + approach})
+	if p != nil {
+		return nil, p
+	}
+
+	if act.Name == api.ActionPick {
+		above.Gripper, at.Gripper = as.Open, as.Open
+		held := at
+		held.Gripper = grip
+		lifted := above
+		lifted.Gripper = grip
+		return []move{{"approach", above}, {"descend", at}, {"grip", held}, {"lift", lifted}}, nil
+	}
+	released := at
+	released.Gripper = as.Open
+	lifted := above
+	lifted.Gripper = as.Open
+	return []move{{"approach", above}, {"descend", at}, {"release", released}, {"lift", lifted}}, nil
+}
+
+// checkActionSettings returns a Problem if the settings of the actions are invalid
+func checkActionSettings(as *api.ActionSettings) *api.Problem {
+	gripper := api.JointRanges["gripper"]
+	switch {
+	case !gripper.Contains(as.Open):
+		return &api.Problem{Detail: fmt.Sprintf("The open gripper must be within [%v, %v]", gripper.Min, gripper.Max), Field: "open", Value: as.Open, Range: &gripper}
+	case !gripper.Contains(as.Closed):
+		return &api.Problem{Detail: fmt.Sprintf("The closed gripper must be within [%v, %v]", gripper.Min, gripper.Max), Field: "closed", Value: as.Closed, Range: &gripper}
+	case as.Width <= 0:
+		return &api.Problem{Detail: "The width must be positive", Field: "width", Value: as.Width}
+	case as.Squeeze < 0:
+		return &api.Problem{Detail: "The squeeze must not be negative", Field: "squeeze", Value: as.Squeeze}
+	case as.Approach < 0:
+		return &api.Problem{Detail: "The approach height must not be negative", Field: "approach", Value: as.Approach}
+	}
+	return nil
+}
+
+// handleAction processes the messages on the actions, performing them is for the current user
+// and changing the settings for the admins
+func (controller *Controller) handleAction(msg api.HandlerMessage) api.HandlerMessage {
+	if msg.Type == api.TypeGetActionSettings {
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentActionSettings,
+			Value: []interface{}{controller.ActionSettings},
+		}
+	}
+
+	token, ok := msg.Value[0].(string)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	if msg.Type == api.TypePutActionSettings {
+		if failure := controller.requireAdmin(token); failure != nil {
+			return *failure
+		}
+		as, ok := msg.Value[1].(api.ActionSettings)
+		if !ok {
+			return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+		}
+		if p := checkActionSettings(&as); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
+		controller.ActionSettings = as
+		log.Printf("[Action] Settings %+v", as)
+		return api.HandlerMessage{Type: api.TypeActionSettingsUpdated}
+	}
+
+	act, ok := msg.Value[1].(api.Action)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	switch act.Name {
+	case api.ActionOpen, api.ActionClose, api.ActionGrasp, api.ActionPick, api.ActionPlace:
+	default:
+		return api.HandlerMessage{
+			Type:  api.TypeActionNotFound,
+			Value: []interface{}{api.Problem{Detail: "The actions are open, close, grasp, pick and place", Value: act.Name}},
+		}
+	}
+	userAuth := controller.Validate(token)
+	if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+		return controller.authFailure(token, userAuth)
+	}
+	if controller.CurrentRobotState == Stopped {
+		return controller.stoppedFailure()
+	}
+	if controller.Replaying != nil {
+		return api.HandlerMessage{
+			Type:  api.TypeReplayBusy,
+			Value: []interface{}{api.Problem{Detail: fmt.Sprintf("The %v is still running, wait until it is over", controller.Replaying.program)}},
+		}
+	}
+	delta := *defaultDelta
+	if act.Delta != nil {
+		delta = *act.Delta
+	}
+
+	// check every move before starting, not to stop halfway with the object in the gripper
+	from := controller.startPose()
+	moves, p := controller.plan(act, from)
+	env := controller.envelopeFor(token)
+	deltas := make([]uint8, len(moves))
+	for i, m := range moves {
+		if p != nil {
+			break
+		}
+		if deltas[i], p = speedDelta(from, m.pose, delta, act.Speed); p != nil {
+			p.Detail += fmt.Sprintf(" (%v of the %v)", m.name, act.Name)
+			break
+		}
+		posCom := api.PostureCommand{
+			Token:         token,
+			Base:          m.pose.Base,
+			Shoulder:      m.pose.Shoulder,
+			Elbow:         m.pose.Elbow,
+			WristAngle:    m.pose.WristAngle,
+			WristRotation: m.pose.WristRotation,
+			Gripper:       m.pose.Gripper,
+			Delta:         deltas[i],
+		}
+		if p = checkPosture(env, &posCom); p == nil {
+			for i := range controller.Constraints {
+				if p = breaks(&controller.Constraints[i], &m.pose); p != nil {
+					break
+				}
+			}
+		}
+		if p == nil {
+			p = controller.checkPath(from, m.pose)
+		}
+		if p != nil && len(moves) > 1 {
+			p.Detail += fmt.Sprintf(" (%v of the %v)", m.name, act.Name)
+		}
+		from = m.pose
+	}
+	if p != nil {
+		return api.HandlerMessage{
+			Type:  api.TypeInvalidCommand,
+			Value: []interface{}{*p},
+		}
+	}
+
+	// perform the first move now and the rest like a program in the background
+	program := api.Program{Name: act.Name, Steps: []api.Step{}}
+	total := moveTime(deltas[0])
+	for i := range moves {
+		step := api.Step{Command: api.StepPosture, Posture: &moves[i].pose, Delta: &deltas[i]}
+		if i > 0 {
+			step.AfterMs = (moveTime(deltas[i-1]) + settleTime).Milliseconds()
+			total += settleTime + moveTime(deltas[i])
+		}
+		program.Steps = append(program.Steps, step)
+	}
+	log.Printf("[Action] %v in %v moves", act.Name, len(moves))
+	reply := controller.handle(stepMessage(program.Steps[0], token, 1))
+	if reply.Type != api.TypeActionPerformed || len(moves) == 1 {
+		return reply
+	}
+	// feedback with when the last move completes
+	c, _ := replyCompletion(reply)
+	c.DurationMs = total.Milliseconds()
+	c.CompletesAt = time.Now().UTC().Add(total)
+	reply.Value = []interface{}{c}
+	program.Steps = program.Steps[1:]
+	controller.Replaying = &replaying{program: program.Name, stop: make(chan struct{})}
+	go controller.replay(program, token, api.Replay{Speed: 1, Loops: 1}, controller.Replaying.stop)
+	return reply
+}
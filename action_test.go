@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/kinematics"
+)
+
+// TestPickUnderLoad checks that the pick runs all the moves while the others poll the robot
+func TestPickUnderLoad(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	x, y, z, delta := 200.0, 50.0, 40.0, uint8(1)
+	lifted, p := reach(currentPose(t, h), kinematics.Point{X: x, Y: y, Z: z + api.DefaultActionSettings.Approach})
+	if p != nil {
+		t.Fatal(p.Detail)
+	}
+	lifted.Gripper = api.DefaultActionSettings.Closed
+
+	done := make(chan struct{})
+	wg := pollPosture(t, h, 8, done)
+	if rec := serve(h, http.MethodPut, "/actions/pick", token, api.Action{X: &x, Y: &y, Z: &z, Delta: &delta}); rec.Code != http.StatusAccepted {
+		close(done)
+		t.Fatalf("PUT /actions/pick: %v %v", rec.Code, rec.Body)
+	}
+	deadline := time.After(10 * time.Second)
+	for currentPose(t, h) != lifted {
+		select {
+		case <-deadline:
+			close(done)
+			t.Fatalf("the pick stopped at %v, want %v", currentPose(t, h), lifted)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// the pick is over and the next action can start
+	for {
+		rec := serve(h, http.MethodPut, "/actions/open", token, api.Action{Delta: &delta})
+		if rec.Code == http.StatusAccepted {
+			break
+		}
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("PUT /actions/open: %v %v", rec.Code, rec.Body)
+		}
+		select {
+		case <-deadline:
+			t.Fatal("the pick did not finish")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
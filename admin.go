@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/armlink"
+	"github.com/badoux/checkmail"
+)
+
+// masterKeyID is the ID of the admin key given by --masterTokenHash
+const masterKeyID = "master"
+
+// newMasterKey creates the admin key for the hash of the master token
+func newMasterKey(mth string) *api.APIKey {
+	return &api.APIKey{
+		ID:        masterKeyID,
+		Name:      "Super User",
+		Email:     "root@interactions.ics.unisg.ch",
+		Role:      api.RoleAdmin,
+		TokenHash: mth,
+		Created:   time.Now().UTC(),
+	}
+}
+
+// findKey returns the API key with the token, nil if there's none
+func (controller *Controller) findKey(token string) *api.APIKey {
+	if token == "" {
+		return nil
+	}
+	hash := []byte(api.HashToken(token))
+	for _, key := range controller.Keys {
+		if subtle.ConstantTimeCompare([]byte(key.TokenHash), hash) == 1 {
+			return key
+		}
+	}
+	return nil
+}
+
+// limitsFor returns the joint limits for the role, the admins are only bound to the hard limits
+func (controller *Controller) limitsFor(role api.Role) map[string]api.JointRange {
+	if role == api.RoleAdmin {
+		return api.JointRanges
+	}
+	return controller.SoftLimits
+}
+
+// requireAdmin returns the feedback if the token is not of an admin key, nil otherwise
+func (controller *Controller) requireAdmin(token string) *api.HandlerMessage {
+	key := controller.findKey(token)
+	if key == nil {
+		return &api.HandlerMessage{
+			Type:  api.TypeInvalidToken,
+			Value: []interface{}{api.Problem{Detail: "The token is not an API key"}},
+		}
+	}
+	if key.Role != api.RoleAdmin {
+		return &api.HandlerMessage{
+			Type:  api.TypeForbidden,
+			Value: []interface{}{api.Problem{Detail: fmt.Sprintf("The request requires an admin key, not %v", key.Role)}},
+		}
+	}
+	return nil
+}
+
+// storeUnavailable creates the feedback for reading what is only kept in the store without one,
+// rather than an empty answer which would look like nothing happened
+func storeUnavailable(what string) api.HandlerMessage {
+	return api.HandlerMessage{
+		Type:  api.TypeStoreUnavailable,
+		Value: []interface{}{api.Problem{Detail: fmt.Sprintf("The %v is only kept in the store, start Leubot with --storePath", what)}},
+	}
+}
+
+// stoppedFailure creates the feedback for the commands during the emergency stop
+func (controller *Controller) stoppedFailure() api.HandlerMessage {
+	return api.HandlerMessage{
+		Type:  api.TypeEmergencyStopped,
+		Value: []interface{}{api.Problem{Detail: "An admin engaged the emergency stop, wait until it is released"}},
+	}
+}
+
+// checkKeyRequest returns a Problem if the request to issue a key is invalid
+func checkKeyRequest(req *api.APIKeyRequest) *api.Problem {
+	if req.Name == "" {
+		return &api.Problem{Detail: "The name is required", Field: "name"}
+	}
+	if !req.Role.Valid() {
+		return &api.Problem{Detail: "The role must be one of admin, operator or observer", Field: "role", Value: req.Role}
+	}
+	if req.Email != "" {
+		if err := checkmail.ValidateFormat(req.Email); err != nil {
+			return &api.Problem{Detail: err.Error(), Field: "email", Value: req.Email}
+		}
+	}
+	return nil
+}
+
+// checkLimits returns a Problem if the soft limits are not within the hard limits
+func checkLimits(soft map[string]api.JointRange) *api.Problem {
+	for joint, jr := range soft {
+		hard, ok := api.JointRanges[joint]
+		if !ok {
+			return &api.Problem{Detail: fmt.Sprintf("No such joint: %v", joint), Field: joint}
+		}
+		if jr.Min > jr.Max || !hard.Contains(jr.Min) || !hard.Contains(jr.Max) {
+			return &api.Problem{
+				Detail: fmt.Sprintf("The soft limits for %v must be within [%v, %v]", joint, hard.Min, hard.Max),
+				Field:  joint,
+				Value:  jr,
+				Range:  &hard,
+			}
+		}
+	}
+	return nil
+}
+
+// handleAdmin processes the messages on the current user, the audit log, the API keys, the limits and the emergency stop
+func (controller *Controller) handleAdmin(msg api.HandlerMessage) api.HandlerMessage {
+	if msg.Type == api.TypeGetLimits {
+		return api.HandlerMessage{
+			Type: api.TypeCurrentLimits,
+			Value: []interface{}{api.Limits{
+				Hard: api.JointRanges,
+				Soft: controller.SoftLimits,
+			}},
+		}
+	}
+
+	// the rest is only for the admins
+	token, ok := msg.Value[0].(string)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	if failure := controller.requireAdmin(token); failure != nil {
+		return *failure
+	}
+	switch msg.Type {
+	case api.TypeKickUser:
+		reason, _ := msg.Value[1].(string)
+		if *controller.CurrentUser == (api.User{}) {
+			return api.HandlerMessage{
+				Type:  api.TypeUserNotFound,
+				Value: []interface{}{api.Problem{Detail: "No user is using Leubot"}},
+			}
+		}
+		if reason == "" {
+			reason = "no reason given"
+		}
+		log.Printf("[Admin] Removing the user %v: %v", controller.CurrentUser.Name, reason)
+		controller.notify(*controller.CurrentUser, "You were removed from Leubot by an admin: "+reason)
+		controller.releaseUser("kicked")
+		return api.HandlerMessage{Type: api.TypeUserDeleted}
+	case api.TypeGetAudit:
+		q, ok := msg.Value[1].(api.AuditQuery)
+		if !ok {
+			break
+		}
+		if controller.Store == nil {
+			return storeUnavailable("audit log")
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentAudit,
+			Value: []interface{}{controller.queryAudit(&q)},
+		}
+	case api.TypeGetHistory:
+		q, ok := msg.Value[1].(api.AuditQuery)
+		if !ok {
+			break
+		}
+		if controller.Store == nil {
+			return storeUnavailable("history")
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentHistory,
+			Value: []interface{}{controller.queryHistory(&q)},
+		}
+	case api.TypeGetKeys:
+		keys := []api.APIKeyInfo{}
+		for _, key := range controller.Keys {
+			keys = append(keys, key.ToAPIKeyInfo())
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentKeys,
+			Value: []interface{}{keys},
+		}
+	case api.TypeAddKey:
+		req, ok := msg.Value[1].(api.APIKeyRequest)
+		if !ok {
+			break
+		}
+		if p := checkKeyRequest(&req); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
+		key, token := api.NewAPIKey(&req)
+		controller.Keys = append(controller.Keys, key)
+		log.Printf("[Key] Issued the %v key %v for %v", key.Role, key.ID, key.Name)
+		info := key.ToAPIKeyInfo()
+		info.Token = token
+		return api.HandlerMessage{
+			Type:  api.TypeKeyAdded,
+			Value: []interface{}{info},
+		}
+	case api.TypeDeleteKey:
+		id, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		if id == masterKeyID {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{api.Problem{Detail: "The master key is given by --masterTokenHash and cannot be revoked"}},
+			}
+		}
+		for i, key := range controller.Keys {
+			if key.ID == id {
+				controller.Keys = append(controller.Keys[:i], controller.Keys[i+1:]...)
+				log.Printf("[Key] Revoked the %v key %v of %v", key.Role, key.ID, key.Name)
+				// end the session on behalf of the key
+				if key.Session() == controller.CurrentUser.Session {
+					controller.releaseUser("revoked")
+				}
+				return api.HandlerMessage{Type: api.TypeKeyDeleted}
+			}
+		}
+		return api.HandlerMessage{Type: api.TypeKeyNotFound}
+	case api.TypePutLimits:
+		soft, ok := msg.Value[1].(map[string]api.JointRange)
+		if !ok {
+			break
+		}
+		if p := checkLimits(soft); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
+		for joint, jr := range soft {
+			controller.SoftLimits[joint] = jr
+		}
+		log.Printf("[Limits] Soft limits changed to %v", controller.SoftLimits)
+		return api.HandlerMessage{Type: api.TypeLimitsUpdated}
+	case api.TypePutEStop:
+		log.Println("[EStop] Emergency stop engaged")
+		controller.sendExtended(armlink.ExtendedStop)
+		controller.setState(Stopped)
+		postToSlack(`{"text":"<!here> The emergency stop of Leubot was engaged."}`)
+		return api.HandlerMessage{Type: api.TypeActionPerformed}
+	case api.TypeDeleteEStop:
+		if controller.CurrentRobotState == Stopped {
+			log.Println("[EStop] Emergency stop released")
+			// the pose is unknown; put the robot to sleep until the next command
+			controller.ResetPose()
+			controller.SleepRobot()
+			postToSlack(`{"text":"<!here> The emergency stop of Leubot was released."}`)
+		}
+		return api.HandlerMessage{Type: api.TypeActionPerformed}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
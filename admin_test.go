@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// addTestKey issues the key with the master key and returns it with the token
+func addTestKey(t *testing.T, h http.Handler, name string, role api.Role) api.APIKeyInfo {
+	t.Helper()
+	rec := serve(h, http.MethodPost, "/keys", testMasterKey, api.APIKeyRequest{Name: name, Email: name + "@example.com", Role: role})
+	var info api.APIKeyInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); rec.Code != http.StatusCreated || err != nil || info.Token == "" {
+		t.Fatalf("POST /keys: %v %v", rec.Code, err)
+	}
+	return info
+}
+
+func TestKeys(t *testing.T) {
+	_, h := newTestController(t, nil)
+	observer := addTestKey(t, h, "observer", api.RoleObserver)
+	operator := addTestKey(t, h, "operator", api.RoleOperator)
+	admin := addTestKey(t, h, "admin", api.RoleAdmin)
+
+	// the tokens are only in the response to POST keys
+	rec := serve(h, http.MethodGet, "/keys", admin.Token, nil)
+	var keys []api.APIKeyInfo
+	if err := json.NewDecoder(rec.Body).Decode(&keys); rec.Code != http.StatusOK || err != nil || len(keys) != 4 {
+		t.Fatalf("GET /keys: %v %v %v", rec.Code, keys, err)
+	}
+	for _, key := range keys {
+		if key.Token != "" {
+			t.Errorf("GET /keys shows the token of %v", key.Name)
+		}
+	}
+
+	// the observers only read, the operator key starts a session on its own
+	if code := moveBase(h, observer.Token, 450); code != http.StatusForbidden {
+		t.Errorf("PUT /base as an observer: %v, want 403", code)
+	}
+	if rec := serve(h, http.MethodGet, "/posture", observer.Token, nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /posture as an observer: %v", rec.Code)
+	}
+	if code := moveBase(h, operator.Token, 450); code != http.StatusAccepted {
+		t.Errorf("PUT /base as an operator: %v", code)
+	}
+	if name := currentUser(t, h).Name; name != "operator" {
+		t.Errorf("the operator key started the session of %q", name)
+	}
+	if code := moveBase(h, admin.Token, 460); code != http.StatusAccepted {
+		t.Errorf("PUT /base as an admin during the session: %v", code)
+	}
+
+	// only the admins manage the keys
+	if rec := serve(h, http.MethodPost, "/keys", operator.Token, api.APIKeyRequest{Name: "more", Role: api.RoleAdmin}); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /keys as an operator: %v, want 403", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/keys", admin.Token, api.APIKeyRequest{Name: "root", Role: "root"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /keys with the role root: %v, want 400", rec.Code)
+	}
+
+	// revoking the key ends its session at once
+	if rec := serve(h, http.MethodDelete, "/keys/"+operator.ID, admin.Token, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /keys: %v %v", rec.Code, rec.Body)
+	}
+	if name := currentUser(t, h).Name; name != "" {
+		t.Errorf("the session of %q is still on", name)
+	}
+	addTestUser(t, h, "bob")
+	if code := moveBase(h, operator.Token, 470); code != http.StatusUnauthorized {
+		t.Errorf("PUT /base with the revoked key: %v, want 401", code)
+	}
+	if rec := serve(h, http.MethodDelete, "/keys/"+operator.ID, admin.Token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE /keys again: %v, want 404", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/keys/"+masterKeyID, admin.Token, nil); rec.Code != http.StatusConflict {
+		t.Errorf("DELETE /keys of the master key: %v, want 409", rec.Code)
+	}
+}
+
+func TestSoftLimits(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	admin := addTestKey(t, h, "admin", api.RoleAdmin)
+	moveElbow := func(token string, value int) *api.Problem {
+		rec := serve(h, http.MethodPut, "/elbow", token, map[string]interface{}{"value": value})
+		if rec.Code == http.StatusAccepted {
+			return nil
+		}
+		var p api.Problem
+		json.NewDecoder(rec.Body).Decode(&p)
+		return &p
+	}
+
+	soft := map[string]api.JointRange{"elbow": {Min: 300, Max: 550}}
+	if rec := serve(h, http.MethodPut, "/limits", token, soft); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /limits as the user: %v, want 401", rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, "/limits", admin.Token, soft); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /limits: %v %v", rec.Code, rec.Body)
+	}
+	rec := serve(h, http.MethodGet, "/limits", "", nil)
+	var limits api.Limits
+	if err := json.NewDecoder(rec.Body).Decode(&limits); err != nil || limits.Soft["elbow"] != soft["elbow"] || limits.Hard["elbow"] != api.JointRanges["elbow"] || limits.Soft["base"] != api.JointRanges["base"] {
+		t.Errorf("GET /limits: %+v %v", limits, err)
+	}
+
+	// the operators are bound to the soft limits, the admins to the hard ones
+	if p := moveElbow(token, 500); p != nil {
+		t.Errorf("PUT /elbow within the soft limits: %+v", p)
+	}
+	if p := moveElbow(token, 600); p == nil || p.Field != "elbow" || p.Range == nil || *p.Range != soft["elbow"] {
+		t.Errorf("PUT /elbow beyond the soft limits: %+v", p)
+	}
+	if p := moveElbow(admin.Token, 600); p != nil {
+		t.Errorf("PUT /elbow as an admin: %+v", p)
+	}
+	if p := moveElbow(admin.Token, 950); p == nil || p.Range == nil || *p.Range != api.JointRanges["elbow"] {
+		t.Errorf("PUT /elbow beyond the hard limits: %+v", p)
+	}
+
+	for name, soft := range map[string]map[string]api.JointRange{
+		"beyond the hard limits": {"elbow": {Min: 100, Max: 700}},
+		"min above max":          {"elbow": {Min: 700, Max: 300}},
+		"no such joint":          {"knee": {Min: 0, Max: 10}},
+	} {
+		if rec := serve(h, http.MethodPut, "/limits", admin.Token, soft); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT /limits %v: %v, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestEStop(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	if rec := serve(h, http.MethodPut, "/estop", token, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /estop as the user: %v, want 401", rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, "/estop", testMasterKey, nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("PUT /estop: %v %v", rec.Code, rec.Body)
+	}
+	rec := serve(h, http.MethodPut, "/base", token, map[string]interface{}{"value": 450})
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "emergency-stop") {
+		t.Errorf("PUT /base during the emergency stop: %v %v", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodDelete, "/estop", testMasterKey, nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("DELETE /estop: %v %v", rec.Code, rec.Body)
+	}
+	if code := moveBase(h, token, 450); code != http.StatusAccepted {
+		t.Errorf("PUT /base after the emergency stop: %v", code)
+	}
+}
+
+func TestKickUser(t *testing.T) {
+	addr, mails := serveSMTP(t)
+	hook := make(chan map[string]string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		hook <- body
+	}))
+	t.Cleanup(srv.Close)
+	oldAddr, oldURL := *smtpAddr, *notifyWebhookURL
+	t.Cleanup(func() { *smtpAddr, *notifyWebhookURL = oldAddr, oldURL })
+	*smtpAddr, *notifyWebhookURL = addr, srv.URL
+	controller, h := newTestController(t, nil)
+
+	if rec := serve(h, http.MethodDelete, "/user?reason=stale", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE /user without a token: %v, want 401", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/user?reason=stale", testMasterKey, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE /user without a user: %v, want 404", rec.Code)
+	}
+	token := addTestUser(t, h, "alice")
+	if code := moveBase(h, token, 450); code != http.StatusAccepted {
+		t.Fatalf("PUT /base: %v", code)
+	}
+	operator := addTestKey(t, h, "operator", api.RoleOperator)
+	for _, key := range []string{token, operator.Token} {
+		if rec := serve(h, http.MethodDelete, "/user?reason=stale", key, nil); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+			t.Errorf("DELETE /user by a non-admin: %v, want 401 or 403", rec.Code)
+		}
+	}
+	if currentUser(t, h).Name != "alice" || !controller.UserTimerRunning {
+		t.Fatal("a non-admin removed alice")
+	}
+
+	if rec := serve(h, http.MethodDelete, "/user?reason=left+for+lunch", testMasterKey, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /user: %v %v", rec.Code, rec.Body)
+	}
+	if controller.UserTimerRunning {
+		t.Errorf("the timer of alice is still running")
+	}
+	rec := serve(h, http.MethodGet, "/status", "", nil)
+	var status api.Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil || status.UserActive || status.State != Sleeping.String() {
+		t.Errorf("GET /status after the removal: %+v %v", status, err)
+	}
+	if code := moveBase(h, token, 460); code == http.StatusAccepted {
+		t.Errorf("PUT /base with the token of the removed user: %v", code)
+	}
+
+	// the user is emailed the reason, and the webhook told about it
+	select {
+	case mail := <-mails:
+		if !strings.Contains(mail, "To: alice@example.com") || !strings.Contains(mail, "left for lunch") {
+			t.Errorf("the email: %v", mail)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("alice was not emailed")
+	}
+	select {
+	case body := <-hook:
+		if body["email"] != "alice@example.com" || !strings.Contains(body["text"], "left for lunch") {
+			t.Errorf("the webhook: %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the webhook was not notified")
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// The names of the actions
+const (
+	ActionOpen  = "open"
+	ActionClose = "close"
+	ActionGrasp = "grasp"
+	ActionPick  = "pick"
+	ActionPlace = "place"
+)
+
+// ActionSettings provides the JSON scheme for the gripper and the approach height of the
+// actions: the gripper positions when fully open and closed, the width in mm between the
+// fingers when open, how much narrower than the object to grasp it, and the height above
+// the target in mm to approach from and to lift to
+type ActionSettings struct {
+	Open     uint16  `json:"open"`
+	Closed   uint16  `json:"closed"`
+	Width    float64 `json:"width"`
+	Squeeze  float64 `json:"squeeze"`
+	Approach float64 `json:"approach"`
+}
+
+// DefaultActionSettings are for the stock gripper of the Reactor Arm
+var DefaultActionSettings = ActionSettings{
+	Open:     0,
+	Closed:   512,
+	Width:    32,
+	Squeeze:  4,
+	Approach: 50,
+}
+
+// Action provides the JSON scheme for an action: the target in mm for pick and place,
+// the width of the gripper to close to or of the object to grasp or pick, the approach
+// height in place of the one in the settings, and the delta or the speed of each move
+type Action struct {
+	Name     string   `json:"-"`
+	X        *float64 `json:"x,omitempty"`
+	Y        *float64 `json:"y,omitempty"`
+	Z        *float64 `json:"z,omitempty"`
+	Width    *float64 `json:"width,omitempty"`
+	Approach *float64 `json:"approach,omitempty"`
+	Delta    *uint8   `json:"delta,omitempty"`
+	Speed
+}
+
+// ActionSettingsHandler process the requests on the settings of the actions, changing them is only for the admins
+func ActionSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		getActionSettings(w, r)
+	case http.MethodPut:
+		putActionSettings(w, r)
+	}
+}
+
+func getActionSettings(w http.ResponseWriter, r *http.Request) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetActionSettings,
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypeCurrentActionSettings {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// putActionSettings replaces the settings of the actions
+func putActionSettings(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var as ActionSettings
+	err := decoder.Decode(&as)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypePutActionSettings, as)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeActionSettingsUpdated:
+		log.Printf("[HandlerChannel] ActionSettingsUpdated = %+v", as)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// ActionHandler process the request to perform an action with the gripper
+func ActionHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// parse the request body, which may be empty
+	decoder := json.NewDecoder(r.Body)
+	var act Action
+	err := decoder.Decode(&act)
+	if err != nil && err != io.EOF {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	act.Name = mux.Vars(r)["name"]
+	msg, ok := adminRequest(w, r, TypePerformAction, act)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeActionPerformed: // the action is started, tell when it completes
+		log.Printf("Action: %v", act.Name)
+		writeCompletion(w, r, msg)
+	case TypeInvalidCommand, TypeUserNotFound:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeActionNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeSlotReserved, TypeEmergencyStopped, TypeReplayBusy:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
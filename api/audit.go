@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AuditEntry provides the JSON scheme for a command recorded in the audit log
+type AuditEntry struct {
+	Time    time.Time     `json:"time"`
+	Name    string        `json:"name,omitempty"`
+	Email   string        `json:"email,omitempty"`
+	Key     string        `json:"key,omitempty"`
+	Type    string        `json:"type"`
+	Values  []interface{} `json:"values,omitempty"`
+	Outcome string        `json:"outcome"`
+	Detail  string        `json:"detail,omitempty"`
+	Packets []string      `json:"packets,omitempty"`
+}
+
+// AuditQuery filters the audit log by the time range and the name or email of the user
+type AuditQuery struct {
+	From time.Time
+	To   time.Time
+	User string
+}
+
+// Matches checks if the entry is within the query
+func (q *AuditQuery) Matches(entry *AuditEntry) bool {
+	if !q.From.IsZero() && entry.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !entry.Time.Before(q.To) {
+		return false
+	}
+	return q.User == "" || q.User == entry.Email || q.User == entry.Name
+}
+
+// parseAuditQuery parses the filters in the query string, responding with the problem if invalid
+func parseAuditQuery(w http.ResponseWriter, r *http.Request) (AuditQuery, bool) {
+	params := r.URL.Query()
+	var q AuditQuery
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if v := params.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				problem := problemInvalidQuery.problem("The time must be in RFC 3339")
+				problem.Field, problem.Value = p.name, v
+				writeProblem(w, r, http.StatusBadRequest, problem) // 400
+				return q, false
+			}
+			*p.t = t
+		}
+	}
+	q.User = params.Get("user")
+	return q, true
+}
+
+// AuditHandler responds with the audit log to the admins, as JSON Lines
+// with `format=jsonl` or `Accept: application/x-ndjson`
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// parse the filters
+	params := r.URL.Query()
+	q, ok := parseAuditQuery(w, r)
+	if !ok {
+		return
+	}
+
+	msg, ok := adminRequest(w, r, TypeGetAudit, q)
+	if !ok {
+		return
+	}
+	if msg.Type == TypeStoreUnavailable {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemFromMessage(msg)) // 503
+		return
+	}
+	entries, ok := msg.Value[0].([]AuditEntry)
+	if msg.Type != TypeCurrentAudit || !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+
+	// export as JSON Lines
+	if params.Get("format") == "jsonl" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="leubot-audit.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for _, entry := range entries {
+			enc.Encode(entry)
+		}
+		return
+	}
+	js, err := json.Marshal(entries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Constraint provides the JSON scheme for a rule coupling the joints: the sum of the positions
+// in ticks with the weights must be within min and max, e.g. elbow + wristAngle <= 1300
+type Constraint struct {
+	Name    string             `json:"name"`
+	Weights map[string]float64 `json:"weights"`
+	Min     *float64           `json:"min,omitempty"`
+	Max     *float64           `json:"max,omitempty"`
+}
+
+// DefaultConstraints is empty, the admins add the constraints of the setup
+var DefaultConstraints = []Constraint{}
+
+// Sum returns the weighted sum of the joints in the rp
+func (c *Constraint) Sum(rp *RobotPose) float64 {
+	sum := 0.0
+	for joint, weight := range c.Weights {
+		sum += weight * float64(rp.Get(joint))
+	}
+	return sum
+}
+
+// Holds checks if the rp is within the limits of the constraint
+func (c *Constraint) Holds(rp *RobotPose) bool {
+	sum := c.Sum(rp)
+	return (c.Min == nil || *c.Min <= sum) && (c.Max == nil || sum <= *c.Max)
+}
+
+// String returns a string rep for the constraint, e.g. 600 <= shoulder - elbow <= 900
+func (c *Constraint) String() string {
+	var b strings.Builder
+	for _, joint := range JointNames {
+		weight, ok := c.Weights[joint]
+		if !ok || weight == 0 {
+			continue
+		}
+		switch {
+		case b.Len() == 0 && weight < 0:
+			b.WriteString("-")
+		case b.Len() > 0 && weight < 0:
+			b.WriteString(" - ")
+		case b.Len() > 0:
+			b.WriteString(" + ")
+		}
+		if weight < 0 {
+			weight = -weight
+		}
+		if weight != 1 {
+			fmt.Fprintf(&b, "%v*", weight)
+		}
+		b.WriteString(joint)
+	}
+	s := b.String()
+	if c.Min != nil {
+		s = fmt.Sprintf("%v <= %v", *c.Min, s)
+	}
+	if c.Max != nil {
+		s = fmt.Sprintf("%v <= %v", s, *c.Max)
+	}
+	return s
+}
+
+// PoseCheck provides the JSON scheme for the result of a dry run,
+// listing every limit, constraint or keep-out zone the posture would break
+type PoseCheck struct {
+	Valid    bool      `json:"valid"`
+	Problems []Problem `json:"problems"`
+}
+
+// ConstraintHandler process the requests on the constraints, changing them is only for the admins
+func ConstraintHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		getConstraints(w, r)
+	case http.MethodPut:
+		putConstraints(w, r)
+	}
+}
+
+func getConstraints(w http.ResponseWriter, r *http.Request) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetConstraints,
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypeCurrentConstraints {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// putConstraints replaces the constraints
+func putConstraints(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var constraints []Constraint
+	err := decoder.Decode(&constraints)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypePutConstraints, constraints)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeConstraintsUpdated:
+		log.Printf("[HandlerChannel] ConstraintsUpdated with %v constraints", len(constraints))
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// PostureCheckHandler process the dry run of a posture, checking it without moving the robot;
+// the envelope of the token in X-API-Key applies if it is a key or of the current user
+func PostureCheckHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var posCom PostureCommand
+	err := decoder.Decode(&posCom)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	posCom.Token = r.Header.Get("X-API-Key")
+
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeCheckPosture,
+		Value: []interface{}{posCom},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypePostureChecked {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	check, ok := msg.Value[0].(PoseCheck)
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+		return
+	}
+	// the problems are what PUT posture would answer with
+	for i := range check.Problems {
+		check.Problems[i] = problemFromMessage(HandlerMessage{Type: TypeInvalidCommand, Value: []interface{}{check.Problems[i]}})
+		check.Problems[i].Status = http.StatusBadRequest
+	}
+	js, err := json.Marshal(check)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
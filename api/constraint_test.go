@@ -0,0 +1,25 @@
+package api
+
+import "testing"
+
+func TestConstraint(t *testing.T) {
+	min, max := 600.0, 900.0
+	rp := RobotPose{Base: 512, Shoulder: 800, Elbow: 100, WristAngle: 512}
+	for _, tc := range []struct {
+		c     Constraint
+		s     string
+		holds bool
+	}{
+		{Constraint{Weights: map[string]float64{"elbow": 1, "wristAngle": 1}, Max: &max}, "elbow + wristAngle <= 900", true},
+		{Constraint{Weights: map[string]float64{"shoulder": 1, "elbow": -1}, Min: &min, Max: &max}, "600 <= shoulder - elbow <= 900", true},
+		{Constraint{Weights: map[string]float64{"elbow": -2, "base": 0.5, "gripper": 0}, Min: &min}, "600 <= 0.5*base - 2*elbow", false},
+		{Constraint{Weights: map[string]float64{"shoulder": -1}, Max: &min}, "-shoulder <= 600", true},
+	} {
+		if s := tc.c.String(); s != tc.s {
+			t.Errorf("String() = %q, want %q", s, tc.s)
+		}
+		if holds := tc.c.Holds(&rp); holds != tc.holds {
+			t.Errorf("%v holds for %v: %v", tc.s, rp, holds)
+		}
+	}
+}
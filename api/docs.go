@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Interactions-HSG/leubot/dist"
+)
+
+// docsIndex is the entry page of Swagger UI
+const docsIndex = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8">
+    <title>Leubot API docs</title>
+    <link rel="stylesheet" type="text/css" href="swagger-ui.css" />
+    <link rel="stylesheet" type="text/css" href="index.css" />
+    <link rel="icon" type="image/png" href="favicon-32x32.png" sizes="32x32" />
+    <link rel="icon" type="image/png" href="favicon-16x16.png" sizes="16x16" />
+  </head>
+
+  <body>
+    <div id="swagger-ui"></div>
+    <script src="swagger-ui-bundle.js" charset="UTF-8"> </script>
+    <script src="swagger-ui-standalone-preset.js" charset="UTF-8"> </script>
+    <script src="swagger-initializer.js" charset="UTF-8"> </script>
+  </body>
+</html>
+`
+
+// docsInitializer configures Swagger UI with the spec of this server,
+// keeping the X-API-Key given in Authorize across reloads
+const docsInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %q,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    persistAuthorization: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// docsAssets serves the files of the bundled Swagger UI
+var docsAssets = http.FileServer(http.FS(dist.FS))
+
+// DocsHandler serves the bundled Swagger UI for the spec at APIBasePath
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := APIBasePath + "/docs/"
+	switch strings.TrimPrefix(r.URL.Path, prefix) {
+	case "", "index.html":
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write([]byte(docsIndex))
+	case "swagger-initializer.js":
+		w.Header().Set("Content-Type", "text/javascript; charset=UTF-8")
+		fmt.Fprintf(w, docsInitializer, APIBasePath+"/openapi.json")
+	default:
+		http.StripPrefix(prefix, docsAssets).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDocs(t *testing.T) {
+	old := HandlerChannel
+	t.Cleanup(func() { HandlerChannel = old })
+	r := NewRouter("localhost", "leubot", "http://", make(chan HandlerMessage), "v1")
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/leubot/v1/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/leubot/v1/docs/" {
+		t.Errorf("GET /leubot/v1/docs = %v to %q, want a redirect to /leubot/v1/docs/", w.Code, w.Header().Get("Location"))
+	}
+	w := get("/leubot/v1/docs/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<div id="swagger-ui">`) {
+		t.Fatalf("GET /leubot/v1/docs/ = %v %.80q", w.Code, w.Body)
+	}
+	// the embedded assets
+	for _, asset := range []string{"swagger-ui-bundle.js", "swagger-ui-standalone-preset.js", "swagger-ui.css"} {
+		if w := get("/leubot/v1/docs/" + asset); w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("GET /leubot/v1/docs/%v = %v with %v bytes", asset, w.Code, w.Body.Len())
+		}
+	}
+
+	// the initializer loads the spec of this server
+	w = get("/leubot/v1/docs/swagger-initializer.js")
+	m := regexp.MustCompile(`url: "([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if w.Code != http.StatusOK || m == nil || m[1] != "/leubot/v1/openapi.json" {
+		t.Fatalf("GET /leubot/v1/docs/swagger-initializer.js = %v %q", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "persistAuthorization: true") {
+		t.Error("the initializer does not keep the authorization")
+	}
+	w = get(m[1])
+	var spec struct {
+		Components struct {
+			SecuritySchemes map[string]struct {
+				Type string `json:"type"`
+				In   string `json:"in"`
+				Name string `json:"name"`
+			} `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET %v = %v %v", m[1], w.Code, err)
+	}
+	if s := spec.Components.SecuritySchemes["ApiKeyAuth"]; s.Type != "apiKey" || s.In != "header" || s.Name != "X-API-Key" {
+		t.Errorf("the security scheme of the spec: %+v", s)
+	}
+}
@@ -76,6 +76,222 @@ const (
 	TypeInvalidCommand
 	// TypeSomethingWentWrong says it didn't go well
 	TypeSomethingWentWrong
+	// TypeGetStatus is to get the status of Leubot
+	TypeGetStatus
+	// TypeCurrentStatus returns Status
+	TypeCurrentStatus
+	// TypeGetReservations is to get the upcoming reservations
+	TypeGetReservations
+	// TypeCurrentReservations returns the upcoming reservations
+	TypeCurrentReservations
+	// TypeGetReservation is to get a reservation
+	TypeGetReservation
+	// TypeCurrentReservation returns a reservation
+	TypeCurrentReservation
+	// TypeAddReservation is to reserve a time slot
+	TypeAddReservation
+	// TypeReservationAdded says the reservation is added
+	TypeReservationAdded
+	// TypeUpdateReservation is to change a reservation
+	TypeUpdateReservation
+	// TypeReservationUpdated says the reservation is changed
+	TypeReservationUpdated
+	// TypeDeleteReservation is to cancel a reservation
+	TypeDeleteReservation
+	// TypeReservationDeleted says the reservation is canceled
+	TypeReservationDeleted
+	// TypeReservationNotFound says no such reservation exists
+	TypeReservationNotFound
+	// TypeReservationConflict says the time slot overlaps with another reservation
+	TypeReservationConflict
+	// TypeInvalidReservation says something wrong about the reservation
+	TypeInvalidReservation
+	// TypeSlotReserved says the robot is reserved by another user now
+	TypeSlotReserved
+	// TypeReservationBoundary says a reserved time slot started or ended
+	TypeReservationBoundary
+	// TypeUserTimeout says the current user was inactive until the timeout
+	TypeUserTimeout
+	// TypeUserQueued says the user is waiting in the queue
+	TypeUserQueued
+	// TypeGetTicket is to get the state of a queue ticket
+	TypeGetTicket
+	// TypeCurrentTicket returns the state of a queue ticket
+	TypeCurrentTicket
+	// TypeDeleteTicket is to leave the queue
+	TypeDeleteTicket
+	// TypeTicketDeleted says the ticket left the queue
+	TypeTicketDeleted
+	// TypeTicketNotFound says no such ticket is in the queue
+	TypeTicketNotFound
+	// TypeForbidden says the role of the key does not allow the request
+	TypeForbidden
+	// TypeGetKeys is to list the API keys
+	TypeGetKeys
+	// TypeCurrentKeys returns the API keys
+	TypeCurrentKeys
+	// TypeAddKey is to issue an API key
+	TypeAddKey
+	// TypeKeyAdded says the API key is issued
+	TypeKeyAdded
+	// TypeDeleteKey is to revoke an API key
+	TypeDeleteKey
+	// TypeKeyDeleted says the API key is revoked
+	TypeKeyDeleted
+	// TypeKeyNotFound says no such API key exists
+	TypeKeyNotFound
+	// TypeGetLimits is to get the joint limits
+	TypeGetLimits
+	// TypeCurrentLimits returns the joint limits
+	TypeCurrentLimits
+	// TypePutLimits is to change the soft limits
+	TypePutLimits
+	// TypeLimitsUpdated says the soft limits are changed
+	TypeLimitsUpdated
+	// TypePutEStop is to engage the emergency stop
+	TypePutEStop
+	// TypeDeleteEStop is to release the emergency stop
+	TypeDeleteEStop
+	// TypeEmergencyStopped says the emergency stop is engaged
+	TypeEmergencyStopped
+	// TypeKickUser is to remove the current user by an admin
+	TypeKickUser
+	// TypeGetAudit is to get the audit log
+	TypeGetAudit
+	// TypeCurrentAudit returns the audit log
+	TypeCurrentAudit
+	// TypeStoreUnavailable says there is no store to read from
+	TypeStoreUnavailable
+	// TypeRefreshToken is to refresh the session token
+	TypeRefreshToken
+	// TypeTokenRefreshed returns the refreshed session token
+	TypeTokenRefreshed
+	// TypeVerificationSent says the link to verify the email was sent
+	TypeVerificationSent
+	// TypeVerifyUser is to add the user who clicked the link in the email
+	TypeVerifyUser
+	// TypeVerificationNotFound says the link is unknown or expired
+	TypeVerificationNotFound
+	// TypeGetProfiles is to list the safety profiles
+	TypeGetProfiles
+	// TypeCurrentProfiles returns the safety profiles
+	TypeCurrentProfiles
+	// TypePutProfile is to create or replace a safety profile
+	TypePutProfile
+	// TypeProfileUpdated says the safety profile was saved
+	TypeProfileUpdated
+	// TypeDeleteProfile is to delete a safety profile
+	TypeDeleteProfile
+	// TypeProfileDeleted says the safety profile was deleted
+	TypeProfileDeleted
+	// TypeProfileNotFound says there's no such safety profile
+	TypeProfileNotFound
+	// TypeGetWorkspace is to get the workspace
+	TypeGetWorkspace
+	// TypeCurrentWorkspace returns the workspace
+	TypeCurrentWorkspace
+	// TypePutWorkspace is to replace the workspace
+	TypePutWorkspace
+	// TypeWorkspaceUpdated says the workspace was replaced
+	TypeWorkspaceUpdated
+	// TypeGetConstraints is to get the joint constraints
+	TypeGetConstraints
+	// TypeCurrentConstraints returns the joint constraints
+	TypeCurrentConstraints
+	// TypePutConstraints is to replace the joint constraints
+	TypePutConstraints
+	// TypeConstraintsUpdated says the joint constraints were replaced
+	TypeConstraintsUpdated
+	// TypeCheckPosture is to check a posture without moving
+	TypeCheckPosture
+	// TypePostureChecked returns the result of the dry run
+	TypePostureChecked
+	// TypeGetPoses is to get the named poses
+	TypeGetPoses
+	// TypeCurrentPoses returns the named poses
+	TypeCurrentPoses
+	// TypeGetPose is to get a named pose
+	TypeGetPose
+	// TypeCurrentPose returns the named pose
+	TypeCurrentPose
+	// TypePutPose is to save a named pose
+	TypePutPose
+	// TypePoseAdded says the named pose was added
+	TypePoseAdded
+	// TypePoseUpdated says the named pose was replaced
+	TypePoseUpdated
+	// TypeDeletePose is to delete a named pose
+	TypeDeletePose
+	// TypePoseDeleted says the named pose was deleted
+	TypePoseDeleted
+	// TypePoseNotFound says there is no such named pose
+	TypePoseNotFound
+	// TypeApplyPose is to move to a named pose
+	TypeApplyPose
+	// TypeStartRecording is to start recording the commands of the current user
+	TypeStartRecording
+	// TypeRecordingStarted says the recording started
+	TypeRecordingStarted
+	// TypeStopRecording is to stop recording and save the program
+	TypeStopRecording
+	// TypeRecordingStopped returns the recorded program
+	TypeRecordingStopped
+	// TypeRecordingNotFound says nothing is being recorded
+	TypeRecordingNotFound
+	// TypeGetPrograms is to get the programs
+	TypeGetPrograms
+	// TypeCurrentPrograms returns the programs
+	TypeCurrentPrograms
+	// TypeGetProgram is to get a program
+	TypeGetProgram
+	// TypeCurrentProgram returns the program
+	TypeCurrentProgram
+	// TypePutProgram is to import a program
+	TypePutProgram
+	// TypeProgramAdded says the program was added
+	TypeProgramAdded
+	// TypeProgramUpdated says the program was replaced
+	TypeProgramUpdated
+	// TypeDeleteProgram is to delete a program
+	TypeDeleteProgram
+	// TypeProgramDeleted says the program was deleted
+	TypeProgramDeleted
+	// TypeProgramNotFound says there is no such program
+	TypeProgramNotFound
+	// TypeReplayProgram is to replay a program
+	TypeReplayProgram
+	// TypeReplayStarted says the replay started
+	TypeReplayStarted
+	// TypeReplayBusy says another program is being replayed
+	TypeReplayBusy
+	// TypeStopReplay is to stop the replay
+	TypeStopReplay
+	// TypeReplayStopped says the replay was stopped
+	TypeReplayStopped
+	// TypeReplayFinished says the replay ended by itself
+	TypeReplayFinished
+	// TypeAddProgram is to create a program, refused if the name is taken
+	TypeAddProgram
+	// TypeProgramExisted says a program with the name exists
+	TypeProgramExisted
+	// TypeGetActionSettings is to get the settings of the actions
+	TypeGetActionSettings
+	// TypeCurrentActionSettings returns the settings of the actions
+	TypeCurrentActionSettings
+	// TypePutActionSettings is to replace the settings of the actions
+	TypePutActionSettings
+	// TypeActionSettingsUpdated says the settings of the actions were replaced
+	TypeActionSettingsUpdated
+	// TypePerformAction is to perform an action with the gripper
+	TypePerformAction
+	// TypeActionNotFound says there is no such action
+	TypeActionNotFound
+	// TypePatchPosture is to move the joints by offsets
+	TypePatchPosture
+	// TypeGetHistory is to get the history of the poses
+	TypeGetHistory
+	// TypeCurrentHistory returns the history of the poses
+	TypeCurrentHistory
 )
 
 func (hmt HandlerMessageType) String() string {
@@ -116,11 +332,130 @@ func (hmt HandlerMessageType) String() string {
 		"TypeInvalidToken",
 		"TypeInvalidCommand",
 		"TypeSomethingWentWrong",
+		"TypeGetStatus",
+		"TypeCurrentStatus",
+		"TypeGetReservations",
+		"TypeCurrentReservations",
+		"TypeGetReservation",
+		"TypeCurrentReservation",
+		"TypeAddReservation",
+		"TypeReservationAdded",
+		"TypeUpdateReservation",
+		"TypeReservationUpdated",
+		"TypeDeleteReservation",
+		"TypeReservationDeleted",
+		"TypeReservationNotFound",
+		"TypeReservationConflict",
+		"TypeInvalidReservation",
+		"TypeSlotReserved",
+		"TypeReservationBoundary",
+		"TypeUserTimeout",
+		"TypeUserQueued",
+		"TypeGetTicket",
+		"TypeCurrentTicket",
+		"TypeDeleteTicket",
+		"TypeTicketDeleted",
+		"TypeTicketNotFound",
+		"TypeForbidden",
+		"TypeGetKeys",
+		"TypeCurrentKeys",
+		"TypeAddKey",
+		"TypeKeyAdded",
+		"TypeDeleteKey",
+		"TypeKeyDeleted",
+		"TypeKeyNotFound",
+		"TypeGetLimits",
+		"TypeCurrentLimits",
+		"TypePutLimits",
+		"TypeLimitsUpdated",
+		"TypePutEStop",
+		"TypeDeleteEStop",
+		"TypeEmergencyStopped",
+		"TypeKickUser",
+		"TypeGetAudit",
+		"TypeCurrentAudit",
+		"TypeStoreUnavailable",
+		"TypeRefreshToken",
+		"TypeTokenRefreshed",
+		"TypeVerificationSent",
+		"TypeVerifyUser",
+		"TypeVerificationNotFound",
+		"TypeGetProfiles",
+		"TypeCurrentProfiles",
+		"TypePutProfile",
+		"TypeProfileUpdated",
+		"TypeDeleteProfile",
+		"TypeProfileDeleted",
+		"TypeProfileNotFound",
+		"TypeGetWorkspace",
+		"TypeCurrentWorkspace",
+		"TypePutWorkspace",
+		"TypeWorkspaceUpdated",
+		"TypeGetConstraints",
+		"TypeCurrentConstraints",
+		"TypePutConstraints",
+		"TypeConstraintsUpdated",
+		"TypeCheckPosture",
+		"TypePostureChecked",
+		"TypeGetPoses",
+		"TypeCurrentPoses",
+		"TypeGetPose",
+		"TypeCurrentPose",
+		"TypePutPose",
+		"TypePoseAdded",
+		"TypePoseUpdated",
+		"TypeDeletePose",
+		"TypePoseDeleted",
+		"TypePoseNotFound",
+		"TypeApplyPose",
+		"TypeStartRecording",
+		"TypeRecordingStarted",
+		"TypeStopRecording",
+		"TypeRecordingStopped",
+		"TypeRecordingNotFound",
+		"TypeGetPrograms",
+		"TypeCurrentPrograms",
+		"TypeGetProgram",
+		"TypeCurrentProgram",
+		"TypePutProgram",
+		"TypeProgramAdded",
+		"TypeProgramUpdated",
+		"TypeDeleteProgram",
+		"TypeProgramDeleted",
+		"TypeProgramNotFound",
+		"TypeReplayProgram",
+		"TypeReplayStarted",
+		"TypeReplayBusy",
+		"TypeStopReplay",
+		"TypeReplayStopped",
+		"TypeReplayFinished",
+		"TypeAddProgram",
+		"TypeProgramExisted",
+		"TypeGetActionSettings",
+		"TypeCurrentActionSettings",
+		"TypePutActionSettings",
+		"TypeActionSettingsUpdated",
+		"TypePerformAction",
+		"TypeActionNotFound",
+		"TypePatchPosture",
+		"TypeGetHistory",
+		"TypeCurrentHistory",
 	}[hmt]
 }
 
-// HandlerMessage contains the payload for the command messages
+// HandlerMessage contains the payload for the command messages, the requests carry
+// the channel to reply on
 type HandlerMessage struct {
 	Type  HandlerMessageType
 	Value []interface{}
+	Reply chan HandlerMessage
+}
+
+// Request sends the request through the channel and waits for the reply, false if there's none;
+// every request has its own channel for the reply so that the concurrent ones can't take it
+func Request(hmc chan<- HandlerMessage, msg HandlerMessage) (HandlerMessage, bool) {
+	msg.Reply = make(chan HandlerMessage, 1)
+	hmc <- msg
+	reply, ok := <-msg.Reply
+	return reply, ok
 }
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PoseSample provides the JSON scheme for a pose commanded to the robot: when, by whom,
+// with which command and the delta it was sent with
+type PoseSample struct {
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name,omitempty"`
+	Email   string    `json:"email,omitempty"`
+	Key     string    `json:"key,omitempty"`
+	Command string    `json:"command"`
+	Delta   uint8     `json:"delta"`
+	Pose    RobotPose `json:"pose"`
+}
+
+// sampleHeader is the header of the history in CSV
+var sampleHeader = append([]string{"time", "name", "email", "key", "command", "delta"}, JointNames...)
+
+// record returns the sample as a row in CSV
+func (s *PoseSample) record() []string {
+	row := []string{s.Time.Format(time.RFC3339Nano), s.Name, s.Email, s.Key, s.Command, strconv.Itoa(int(s.Delta))}
+	for _, joint := range JointNames {
+		row = append(row, strconv.Itoa(int(s.Pose.Get(joint))))
+	}
+	return row
+}
+
+// MatchesSample checks if the sample is within the query
+func (q *AuditQuery) MatchesSample(s *PoseSample) bool {
+	return q.Matches(&AuditEntry{Time: s.Time, Name: s.Name, Email: s.Email})
+}
+
+// HistoryHandler responds with the history of the poses to the admins, as CSV with `format=csv`
+// or `Accept: text/csv`, and as JSON Lines with `format=jsonl` or `Accept: application/x-ndjson`
+func HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// parse the filters
+	params := r.URL.Query()
+	q, ok := parseAuditQuery(w, r)
+	if !ok {
+		return
+	}
+
+	msg, ok := adminRequest(w, r, TypeGetHistory, q)
+	if !ok {
+		return
+	}
+	if msg.Type == TypeStoreUnavailable {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemFromMessage(msg)) // 503
+		return
+	}
+	samples, ok := msg.Value[0].([]PoseSample)
+	if msg.Type != TypeCurrentHistory || !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+
+	// export as CSV
+	if params.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="leubot-history.csv"`)
+		w.WriteHeader(http.StatusOK)
+		cw := csv.NewWriter(w)
+		cw.Write(sampleHeader)
+		for i := range samples {
+			cw.Write(samples[i].record())
+		}
+		cw.Flush()
+		return
+	}
+	// export as JSON Lines
+	if params.Get("format") == "jsonl" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="leubot-history.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for _, sample := range samples {
+			enc.Encode(sample)
+		}
+		return
+	}
+	js, err := json.Marshal(samples)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Role is the privilege granted to an API key
+type Role string
+
+const (
+	// RoleObserver may only read the state of the robot
+	RoleObserver Role = "observer"
+	// RoleOperator may move the robot within the soft limits
+	RoleOperator Role = "operator"
+	// RoleAdmin may also kick users, change the limits, manage the keys and use the e-stop
+	RoleAdmin Role = "admin"
+)
+
+// Valid checks if the role is one of the known roles
+func (role Role) Valid() bool {
+	return role == RoleObserver || role == RoleOperator || role == RoleAdmin
+}
+
+// APIKey provides the struct for a scoped key given in X-API-Key,
+// only the hash of the token is kept
+type APIKey struct {
+	ID        string
+	Name      string
+	Email     string
+	Role      Role
+	TokenHash string
+	Created   time.Time
+}
+
+// APIKeyInfo provides the JSON scheme for APIKey, the token is only
+// disclosed once when the key is issued
+type APIKeyInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email,omitempty"`
+	Role    Role      `json:"role"`
+	Created time.Time `json:"created"`
+	Token   string    `json:"token,omitempty"`
+}
+
+// APIKeyRequest provides the JSON scheme to issue an APIKey
+type APIKeyRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Role  Role   `json:"role"`
+}
+
+// ToAPIKeyInfo parses APIKey to APIKeyInfo without the token
+func (key *APIKey) ToAPIKeyInfo() APIKeyInfo {
+	return APIKeyInfo{
+		ID:      key.ID,
+		Name:    key.Name,
+		Email:   key.Email,
+		Role:    key.Role,
+		Created: key.Created,
+	}
+}
+
+// Session returns the ID of the session on behalf of the key
+func (key *APIKey) Session() string {
+	return "key:" + key.ID
+}
+
+// ToUser creates the User acting with the key, who keeps using the key instead of a session token
+func (key *APIKey) ToUser() *User {
+	return &User{
+		Name:    key.Name,
+		Email:   key.Email,
+		Session: key.Session(),
+		Role:    key.Role,
+		Issued:  time.Now().UTC(),
+	}
+}
+
+// NewAPIKey instantiate an API key with a new token, returned only here
+func NewAPIKey(req *APIKeyRequest) (*APIKey, string) {
+	token := GenerateToken()
+	return &APIKey{
+		ID:        GenerateToken()[:8],
+		Name:      req.Name,
+		Email:     req.Email,
+		Role:      req.Role,
+		TokenHash: HashToken(token),
+		Created:   time.Now().UTC(),
+	}, token
+}
+
+// adminRequest bypasses the request of an admin, or any request with the X-API-Key, to HandlerChannel,
+// responding with a problem if the token is missing, invalid or lacks the privilege
+func adminRequest(w http.ResponseWriter, r *http.Request, reqType HandlerMessageType, values ...interface{}) (HandlerMessage, bool) {
+	// extract token from the X-API-Key header
+	token := r.Header.Get("X-API-Key")
+	if token == "" {
+		writeProblem(w, r, http.StatusUnauthorized, problemMissingToken.problem("The token is required in X-API-Key header")) // 401
+		return HandlerMessage{}, false
+	}
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  reqType,
+		Value: append([]interface{}{token}, values...),
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return msg, false
+	}
+	switch msg.Type {
+	case TypeInvalidToken:
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+		return msg, false
+	case TypeForbidden:
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+		return msg, false
+	case TypeSomethingWentWrong:
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return msg, false
+	}
+	return msg, true
+}
+
+// KeyHandler process the requests on the API keys, only for the admins
+func KeyHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	id, ok := mux.Vars(r)["id"]
+	switch {
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && !ok:
+		getKeys(w, r)
+	case r.Method == http.MethodPost:
+		addKey(w, r)
+	case r.Method == http.MethodDelete:
+		removeKey(w, r, id)
+	}
+}
+
+func getKeys(w http.ResponseWriter, r *http.Request) {
+	msg, ok := adminRequest(w, r, TypeGetKeys)
+	if !ok {
+		return
+	}
+	if msg.Type != TypeCurrentKeys {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func addKey(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var req APIKeyRequest
+	err := decoder.Decode(&req)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypeAddKey, req)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeKeyAdded: // respond with the token, which is never shown again
+		info, ok := msg.Value[0].(APIKeyInfo)
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("[HandlerChannel] KeyAdded (id, name, role) = %v, %v, %v", info.ID, info.Name, info.Role)
+		js, err := json.Marshal(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/keys/"+info.ID)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(js)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func removeKey(w http.ResponseWriter, r *http.Request, id string) {
+	msg, ok := adminRequest(w, r, TypeDeleteKey, id)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeKeyDeleted:
+		log.Printf("[HandlerChannel] KeyDeleted = %v", id)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeKeyNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
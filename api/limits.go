@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Limits provides the JSON scheme for the joint limits; the hard limits are of
+// the Reactor Arm and the soft limits are for the operators set by the admins
+type Limits struct {
+	Hard map[string]JointRange `json:"hard"`
+	Soft map[string]JointRange `json:"soft"`
+}
+
+// LimitsHandler process the requests on the joint limits
+func LimitsHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		getLimits(w, r)
+	case http.MethodPut:
+		putLimits(w, r)
+	}
+}
+
+func getLimits(w http.ResponseWriter, r *http.Request) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetLimits,
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypeCurrentLimits {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// putLimits replaces the soft limits of the joints given in the body
+func putLimits(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var soft map[string]JointRange
+	err := decoder.Decode(&soft)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypePutLimits, soft)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeLimitsUpdated:
+		log.Printf("[HandlerChannel] LimitsUpdated = %v", soft)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// EStopHandler engages (PUT) or releases (DELETE) the emergency stop
+func EStopHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	reqType := TypePutEStop
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodDelete:
+		reqType = TypeDeleteEStop
+	}
+	msg, ok := adminRequest(w, r, reqType)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeActionPerformed:
+		log.Printf("[HandlerChannel] %v", reqType)
+		w.WriteHeader(http.StatusAccepted) // 202
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcStateCookie keeps the state and the nonce of the login until the callback
+const oidcStateCookie = "leubot_oidc_state"
+
+// OIDCConfig provides the struct for the login with an OpenID Connect provider,
+// only the users with the verified emails at the Domains (any if empty) may log in
+type OIDCConfig struct {
+	OAuth2   oauth2.Config
+	Verifier *oidc.IDTokenVerifier
+	Domains  []string
+	Required bool
+}
+
+// OIDC is the login with an OpenID Connect provider, nil unless configured
+var OIDC *OIDCConfig
+
+// NewOIDCConfig discovers the provider at the issuer URL
+func NewOIDCConfig(ctx context.Context, issuer string, clientID string, clientSecret string, redirectURL string, domains []string) (*OIDCConfig, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCConfig{
+		OAuth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		Verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		Domains:  domains,
+	}, nil
+}
+
+// AllowsEmail checks if the email is at one of the Domains
+func (oc *OIDCConfig) AllowsEmail(email string) bool {
+	if len(oc.Domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	for _, domain := range oc.Domains {
+		if strings.EqualFold(email[at+1:], domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginHandler redirects to the OpenID Connect provider to log in
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if OIDC == nil {
+		writeProblem(w, r, http.StatusNotFound, problemLoginDisabled.problem("The login is not configured, add a user with POST user")) // 404
+		return
+	}
+	state, nonce := GenerateToken(), GenerateToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state + "." + nonce,
+		Path:     APIBasePath + "/login",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   APIProto == "https://",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, OIDC.OAuth2.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusFound)
+}
+
+// LoginCallbackHandler adds the user with the verified claims of the provider
+func LoginCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if OIDC == nil {
+		writeProblem(w, r, http.StatusNotFound, problemLoginDisabled.problem("The login is not configured, add a user with POST user")) // 404
+		return
+	}
+	params := r.URL.Query()
+	if e := params.Get("error"); e != "" {
+		writeProblem(w, r, http.StatusUnauthorized, problemLoginFailed.problem(fmt.Sprintf("%v: %v", e, params.Get("error_description")))) // 401
+		return
+	}
+
+	// check the state against the cookie set by LoginHandler
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemLoginFailed.problem("The login has expired, start again at login")) // 400
+		return
+	}
+	state, nonce, _ := strings.Cut(cookie.Value, ".")
+	if state != params.Get("state") {
+		writeProblem(w, r, http.StatusBadRequest, problemLoginFailed.problem("The state does not match, start again at login")) // 400
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: APIBasePath + "/login", MaxAge: -1})
+
+	// exchange the code for the ID token and verify it
+	token, err := OIDC.OAuth2.Exchange(r.Context(), params.Get("code"))
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, problemLoginFailed.problem(err.Error())) // 401
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		writeProblem(w, r, http.StatusUnauthorized, problemLoginFailed.problem("The provider returned no ID token")) // 401
+		return
+	}
+	idToken, err := OIDC.Verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, problemLoginFailed.problem(err.Error())) // 401
+		return
+	}
+	if idToken.Nonce != nonce {
+		writeProblem(w, r, http.StatusUnauthorized, problemLoginFailed.problem("The nonce does not match")) // 401
+		return
+	}
+	var claims struct {
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, problemLoginFailed.problem(err.Error())) // 401
+		return
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		writeProblem(w, r, http.StatusForbidden, problemLoginFailed.problem("The provider has not verified the email")) // 403
+		return
+	}
+	if !OIDC.AllowsEmail(claims.Email) {
+		p := problemLoginFailed.problem(fmt.Sprintf("Only the emails at %v may use Leubot", strings.Join(OIDC.Domains, ", ")))
+		p.Field, p.Value = "email", claims.Email
+		writeProblem(w, r, http.StatusForbidden, p) // 403
+		return
+	}
+	userInfo := UserInfo{
+		Name:  claims.Name,
+		Email: claims.Email,
+	}
+	if userInfo.Name == "" {
+		userInfo.Name = claims.PreferredUsername
+	}
+	log.Printf("[Login] %v (%v) logged in at %v", userInfo.Name, userInfo.Email, idToken.Issuer)
+
+	// bypass the request to HandlerChannel, the email is verified by the provider
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeAddUser,
+		Value: []interface{}{userInfo, "", true},
+	})
+	// check the channel status
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	writeUserAdded(w, r, msg, userInfo)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// httpRequests counts the requests per route
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "leubot_http_requests_total",
+		Help: "The number of HTTP requests per route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// httpDuration observes the latencies per route
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "leubot_http_request_duration_seconds",
+		Help:    "The latency of HTTP requests per route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// metricsHandler exports the metrics of the default registry
+	metricsHandler = promhttp.Handler()
+)
+
+// MetricsHandler responds with the metrics in the Prometheus text format
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
+
+// statusRecorder keeps the status code written to the ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets the long-running handlers flush through the recorder
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// observeRequest records the metrics of a request to the route
+func observeRequest(name string, method string, status int, seconds float64) {
+	httpRequests.WithLabelValues(name, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(name, method).Observe(seconds)
+}
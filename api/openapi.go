@@ -0,0 +1,321 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operation documents a method of a Route for the OpenAPI spec
+type Operation struct {
+	ID          string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        bool
+	Root        bool // served at the root of the host instead of under APIBasePath
+	Parameters  []Parameter
+	Request     interface{}
+	Responses   []Response
+}
+
+// Parameter documents a query parameter of an Operation
+type Parameter struct {
+	Name        string
+	Description string
+}
+
+// Response documents a response of an Operation
+type Response struct {
+	Status      int
+	Description string
+	Body        interface{}
+}
+
+// OpenAPI is the root object of an OpenAPI 3 document
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers"`
+	Tags       []OpenAPITag                            `json:"tags"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo provides the metadata about the API
+type OpenAPIInfo struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Version     string         `json:"version"`
+	Contact     OpenAPIContact `json:"contact"`
+}
+
+// OpenAPIContact is the contact information for the API
+type OpenAPIContact struct {
+	Email string `json:"email"`
+}
+
+// OpenAPIServer is a server serving the API
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPITag groups the operations
+type OpenAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// OpenAPIOperation describes a single API operation on a path
+type OpenAPIOperation struct {
+	Tags        []string                    `json:"tags"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	OperationID string                      `json:"operationId"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	Servers     []OpenAPIServer             `json:"servers,omitempty"`
+}
+
+// OpenAPIParameter describes a path or query parameter
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes a request body
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema for a media type
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// OpenAPIComponents holds the reusable schemas and security schemes
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema                `json:"schemas"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme describes the API key scheme
+type OpenAPISecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+// Schema is a subset of the JSON Schema used in OpenAPI
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+}
+
+// pathParameter matches the variables in the route patterns
+var pathParameter = regexp.MustCompile(`{([^}]+)}`)
+
+// schemaFor returns the Schema for the type, registering the structs in the schemas
+func schemaFor(t reflect.Type, schemas map[string]*Schema) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if t.PkgPath() == "time" && t.Name() == "Time" {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		schemas[t.Name()] = s
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			// promote the fields of the embedded structs like encoding/json
+			if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+				schemaFor(f.Type, schemas)
+				for n, p := range schemas[f.Type.Name()].Properties {
+					s.Properties[n] = p
+				}
+				continue
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			s.Properties[name] = schemaFor(f.Type, schemas)
+		}
+		return ref
+	}
+	// interface{} and anything else can be of any type
+	return &Schema{}
+}
+
+// NewOpenAPI creates the OpenAPI spec from the routes served at the server
+func NewOpenAPI(routes Routes, server string, ver string) *OpenAPI {
+	spec := &OpenAPI{
+		OpenAPI: "3.0.0",
+		Info: OpenAPIInfo{
+			Title: "Leubot API docs - University of St.Gallen (ICS-HSG)",
+			Description: "API for PhantomX AX-12 Reactor Robot Arm (Leubot).<br/>" +
+				"Users must retrieve an API Key by <a href=\"#/user/addUser\">addUser</a> for any Leubot control actuation.<br/>" +
+				"After the inactivity timeout, the user will be automatically deleted from the system.",
+			Version: ver,
+			Contact: OpenAPIContact{Email: "iori.mizutani@unisg.ch"},
+		},
+		Servers: []OpenAPIServer{{URL: server}},
+		Tags: []OpenAPITag{
+			{"user", "Manage the privilege for the robot control"},
+			{"robot", "Control base servos of PhantomX AX-12 Reactor Robot Arm (All the request requires a token of the user)"},
+			{"reservation", "Book the robot for a time slot"},
+			{"action", "Open and close the gripper, pick and place at a point"},
+			{"program", "Record, import and replay the sequences of commands"},
+			{"service", "Monitor the Leubot service"},
+			{"admin", "Manage the API keys, the limits and the emergency stop (requires an admin key)"},
+		},
+		Paths: map[string]map[string]*OpenAPIOperation{},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*OpenAPISecurityScheme{
+				"ApiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+	}
+	problem := schemaFor(reflect.TypeOf(Problem{}), spec.Components.Schemas)
+	// the probes and the metrics are at the root of the host of the server
+	root := "/"
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		root = (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	}
+
+	for _, route := range routes {
+		for method, op := range route.Operations {
+			oop := &OpenAPIOperation{
+				Tags:        []string{op.Tag},
+				Summary:     op.Summary,
+				Description: op.Description,
+				OperationID: op.ID,
+				Responses:   map[string]*OpenAPIResponse{},
+			}
+			for _, m := range pathParameter.FindAllStringSubmatch(route.Pattern, -1) {
+				oop.Parameters = append(oop.Parameters, OpenAPIParameter{
+					Name:     m[1],
+					In:       "path",
+					Required: true,
+					Schema:   &Schema{Type: "string"},
+				})
+			}
+			for _, p := range op.Parameters {
+				oop.Parameters = append(oop.Parameters, OpenAPIParameter{
+					Name:        p.Name,
+					In:          "query",
+					Description: p.Description,
+					Schema:      &Schema{Type: "string"},
+				})
+			}
+			if op.Auth {
+				oop.Security = []map[string][]string{{"ApiKeyAuth": {}}}
+			}
+			if op.Root {
+				oop.Servers = []OpenAPIServer{{URL: root}}
+			}
+			if op.Request != nil {
+				oop.RequestBody = &OpenAPIRequestBody{
+					Required: true,
+					Content: map[string]*OpenAPIMediaType{
+						"application/json": {Schema: schemaFor(reflect.TypeOf(op.Request), spec.Components.Schemas)},
+					},
+				}
+			}
+			for _, res := range op.Responses {
+				ores := &OpenAPIResponse{Description: res.Description}
+				switch {
+				case res.Body != nil:
+					ores.Content = map[string]*OpenAPIMediaType{
+						"application/json": {Schema: schemaFor(reflect.TypeOf(res.Body), spec.Components.Schemas)},
+					}
+				case res.Status >= 400:
+					ores.Content = map[string]*OpenAPIMediaType{
+						"application/problem+json": {Schema: problem},
+					}
+				}
+				oop.Responses[strconv.Itoa(res.Status)] = ores
+			}
+			if spec.Paths[route.Pattern] == nil {
+				spec.Paths[route.Pattern] = map[string]*OpenAPIOperation{}
+			}
+			spec.Paths[route.Pattern][strings.ToLower(method)] = oop
+		}
+	}
+	return spec
+}
+
+// MarshalOpenAPI encodes the spec as indented JSON
+func MarshalOpenAPI(spec *OpenAPI) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(spec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// OpenAPIHandler serves the OpenAPI spec for this server
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	spec := NewOpenAPI(NewRoutes(), APIProto+APIHost+APIBasePath, APIVersion)
+	// let the bundled Swagger UI try it out on whichever host it is loaded from
+	spec.Servers = append([]OpenAPIServer{{URL: APIBasePath}}, spec.Servers...)
+	js, err := MarshalOpenAPI(spec)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem(err.Error())) // 500
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestOpenAPI checks that the committed spec matches the route table, with the defaults of leubot-openapi
+func TestOpenAPI(t *testing.T) {
+	js, err := MarshalOpenAPI(NewOpenAPI(NewRoutes(), "https://api.interactions.ics.unisg.ch/leubot/v1.3.4", "v1.3.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, js) {
+		t.Error("openapi.json diverges from the route table, run `go generate` to update it")
+	}
+}
+
+// TestOpenAPIRoot checks that the probes and the metrics are documented at the root of the host
+func TestOpenAPIRoot(t *testing.T) {
+	spec := NewOpenAPI(NewRoutes(), "https://example.com/leubot/v1", "v1")
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		op := spec.Paths[path]["get"]
+		if op == nil || len(op.Servers) != 1 || op.Servers[0].URL != "https://example.com/" {
+			t.Errorf("GET %v: %+v", path, op)
+		}
+	}
+	for _, path := range []string{"/status", "/docs/"} {
+		if op := spec.Paths[path]["get"]; op == nil || op.Servers != nil {
+			t.Errorf("GET %v: %+v", path, op)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// NamedPose provides the JSON scheme for a pose saved under a name; the built-in
+// poses are read-only, and saving without the pose takes the current one
+type NamedPose struct {
+	Name     string     `json:"name"`
+	Pose     *RobotPose `json:"pose,omitempty"`
+	ReadOnly bool       `json:"readOnly,omitempty"`
+	SavedBy  string     `json:"savedBy,omitempty"`
+	Saved    *time.Time `json:"saved,omitempty"`
+}
+
+// PoseCommand is a struct for moving to a named pose, with the delta for the speed
+type PoseCommand struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+	Delta *uint8 `json:"delta,omitempty"`
+	Speed
+}
+
+// PoseHandler process the requests on the named poses
+func PoseHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	name, ok := mux.Vars(r)["name"]
+	switch {
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && !ok:
+		getPoses(w, r)
+	case r.Method == http.MethodGet:
+		getPose(w, r, name)
+	case r.Method == http.MethodPut:
+		putPose(w, r, name)
+	case r.Method == http.MethodDelete:
+		removePose(w, r, name)
+	}
+}
+
+func getPoses(w http.ResponseWriter, r *http.Request) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetPoses,
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypeCurrentPoses {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func getPose(w http.ResponseWriter, r *http.Request, name string) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeGetPose,
+		Value: []interface{}{name},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeCurrentPose:
+		js, err := json.Marshal(msg.Value[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+	case TypePoseNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// putPose saves the pose in the body, or else the current one, under the name
+func putPose(w http.ResponseWriter, r *http.Request, name string) {
+	// parse the request body, which may be empty
+	decoder := json.NewDecoder(r.Body)
+	var np NamedPose
+	err := decoder.Decode(&np)
+	if err != nil && err != io.EOF {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	np.Name = name
+	msg, ok := adminRequest(w, r, TypePutPose, np)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypePoseAdded:
+		log.Printf("[HandlerChannel] PoseAdded = %v", name)
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/poses/"+name)
+		w.WriteHeader(http.StatusCreated)
+	case TypePoseUpdated:
+		log.Printf("[HandlerChannel] PoseUpdated = %v", name)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func removePose(w http.ResponseWriter, r *http.Request, name string) {
+	msg, ok := adminRequest(w, r, TypeDeletePose, name)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypePoseDeleted:
+		log.Printf("[HandlerChannel] PoseDeleted = %v", name)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypePoseNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// PoseApplyHandler process the request to move to a named pose
+func PoseApplyHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// parse the request body, which may be empty
+	decoder := json.NewDecoder(r.Body)
+	var poseCom PoseCommand
+	err := decoder.Decode(&poseCom)
+	if err != nil && err != io.EOF {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	poseCom.Name = mux.Vars(r)["name"]
+
+	// extract token from the X-API-Key header
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		poseCom.Token = token
+	} else {
+		writeProblem(w, r, http.StatusUnauthorized, problemMissingToken.problem("The token is required in X-API-Key header")) // 401
+		return
+	}
+
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeApplyPose,
+		Value: []interface{}{poseCom},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+
+	// respond with the result
+	switch msg.Type {
+	case TypeActionPerformed: // the requested action is performed, tell when the joints get there unless sleeping
+		log.Printf("Pose: %v", poseCom.Name)
+		writeCompletion(w, r, msg)
+	case TypeInvalidCommand: // the invalid value provided
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeInvalidToken: // the invalid token provided
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // the key may not move the robot
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypePoseNotFound: // no such pose
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeEmergencyStopped: // the emergency stop is engaged
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeUserNotFound: // the user not found
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ProblemTypeBase is the base URI for the problem types
+const ProblemTypeBase = "https://interactions-hsg.github.io/leubot/problems/"
+
+// Problem provides the JSON scheme for the problem details (RFC 7807)
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Instance   string      `json:"instance,omitempty"`
+	Field      string      `json:"field,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+	Range      *JointRange `json:"range,omitempty"`
+	Profile    string      `json:"profile,omitempty"`
+	Zone       string      `json:"zone,omitempty"`
+	Constraint string      `json:"constraint,omitempty"`
+	Holder     *UserInfo   `json:"holder,omitempty"`
+}
+
+// problemType holds the URI suffix and the title for a problem type
+type problemType struct {
+	slug  string
+	title string
+}
+
+var (
+	// problemMalformedBody is for the request body which cannot be parsed
+	problemMalformedBody = problemType{"malformed-body", "Malformed request body"}
+	// problemMissingToken is for the request without X-API-Key
+	problemMissingToken = problemType{"missing-token", "Missing X-API-Key"}
+	// problemInvalidQuery is for the query parameter which cannot be parsed
+	problemInvalidQuery = problemType{"invalid-query", "Invalid query parameter"}
+	// problemLoginDisabled is for the login without an OpenID Connect provider
+	problemLoginDisabled = problemType{"login-disabled", "Login not configured"}
+	// problemLoginFailed is for the login which the provider or the domains did not allow
+	problemLoginFailed = problemType{"login-failed", "Login failed"}
+	// problemLoginRequired is for adding a user without logging in when the login is required
+	problemLoginRequired = problemType{"login-required", "Login required"}
+	// problemInternal is for anything unexpected
+	problemInternal = problemType{"internal-error", "Something went wrong"}
+
+	// problemTypes maps the failed HandlerMessageType to its problem type
+	problemTypes = map[HandlerMessageType]problemType{
+		TypeUserExisted:          {"user-existed", "The robot is used by another user"},
+		TypeInvalidUserInfo:      {"invalid-user-info", "Invalid user info"},
+		TypeUserNotFound:         {"user-not-found", "User not found"},
+		TypeInvalidToken:         {"invalid-token", "Invalid token"},
+		TypeInvalidCommand:       {"invalid-command", "Invalid command"},
+		TypeSomethingWentWrong:   problemInternal,
+		TypeReservationNotFound:  {"reservation-not-found", "Reservation not found"},
+		TypeReservationConflict:  {"reservation-conflict", "The time slot is already reserved"},
+		TypeInvalidReservation:   {"invalid-reservation", "Invalid reservation"},
+		TypeSlotReserved:         {"slot-reserved", "The robot is reserved by another user"},
+		TypeTicketNotFound:       {"ticket-not-found", "Ticket not found in the queue"},
+		TypeForbidden:            {"forbidden", "The role of the API key does not allow the request"},
+		TypeKeyNotFound:          {"key-not-found", "API key not found"},
+		TypeEmergencyStopped:     {"emergency-stop", "The emergency stop is engaged"},
+		TypeStoreUnavailable:     {"store-unavailable", "No store to read from"},
+		TypeVerificationNotFound: {"verification-not-found", "The link is unknown or expired"},
+		TypeProfileNotFound:      {"profile-not-found", "Safety profile not found"},
+		TypePoseNotFound:         {"pose-not-found", "Named pose not found"},
+		TypeRecordingNotFound:    {"recording-not-found", "Nothing is being recorded"},
+		TypeProgramNotFound:      {"program-not-found", "Program not found"},
+		TypeReplayBusy:           {"replay-busy", "Another program is being replayed"},
+		TypeProgramExisted:       {"program-existed", "A program with the name exists"},
+		TypeActionNotFound:       {"action-not-found", "No such action"},
+	}
+)
+
+// problem creates a Problem of the type with the detail
+func (pt problemType) problem(detail string) Problem {
+	return Problem{
+		Type:   ProblemTypeBase + pt.slug,
+		Title:  pt.title,
+		Detail: detail,
+	}
+}
+
+// problemFromMessage builds the Problem from the failed HandlerMessage,
+// taking over the details if the message carries a Problem
+func problemFromMessage(msg HandlerMessage) Problem {
+	var p Problem
+	if len(msg.Value) > 0 {
+		if mp, ok := msg.Value[0].(Problem); ok {
+			p = mp
+		}
+	}
+	pt, ok := problemTypes[msg.Type]
+	if !ok {
+		pt = problemInternal
+	}
+	p.Type = ProblemTypeBase + pt.slug
+	p.Title = pt.title
+	return p
+}
+
+// writeProblem responds with the Problem as application/problem+json
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, p Problem) {
+	p.Status = status
+	p.Instance = r.RequestURI
+	js, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[Problem] %v %v: %v", status, p.Title, p.Detail)
+	w.Header().Set("Content-Type", "application/problem+json; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(js)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemFromMessage(t *testing.T) {
+	jr := JointRange{Min: 0, Max: 1023}
+	p := problemFromMessage(HandlerMessage{
+		Type:  TypeInvalidCommand,
+		Value: []interface{}{Problem{Detail: "out of range", Field: "base", Value: 2000, Range: &jr}},
+	})
+	if p.Type != ProblemTypeBase+"invalid-command" || p.Title != "Invalid command" || p.Field != "base" || p.Range != &jr {
+		t.Errorf("problemFromMessage(TypeInvalidCommand) = %+v", p)
+	}
+
+	// an unknown type without a problem is an internal error
+	p = problemFromMessage(HandlerMessage{Type: TypeUserAdded})
+	if p.Type != ProblemTypeBase+"internal-error" || p.Detail != "" {
+		t.Errorf("problemFromMessage(TypeUserAdded) = %+v", p)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/leubot/v1/base", nil)
+	writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem("unexpected EOF"))
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := Problem{
+		Type:     ProblemTypeBase + "malformed-body",
+		Title:    "Malformed request body",
+		Status:   http.StatusBadRequest,
+		Detail:   "unexpected EOF",
+		Instance: "/leubot/v1/base",
+	}
+	if w.Code != http.StatusBadRequest || p != want {
+		t.Errorf("writeProblem = %v %+v, want %+v", w.Code, p, want)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SafetyProfile provides the JSON scheme for the envelope narrowing the soft limits
+// for the users with the emails or the roles; the min of "delta" in the limits caps
+// the speed, and the forbidden ranges of a joint are refused even within its limits
+type SafetyProfile struct {
+	Name      string                  `json:"name"`
+	Limits    map[string]JointRange   `json:"limits,omitempty"`
+	Forbidden map[string][]JointRange `json:"forbidden,omitempty"`
+	Roles     []Role                  `json:"roles,omitempty"`
+	Users     []string                `json:"users,omitempty"`
+}
+
+// AppliesTo checks if the profile is attached to the email, or to the role if byRole
+func (sp *SafetyProfile) AppliesTo(email string, role Role, byRole bool) bool {
+	if byRole {
+		for _, r := range sp.Roles {
+			if r == role {
+				return true
+			}
+		}
+		return false
+	}
+	for _, u := range sp.Users {
+		if u == email {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileHandler process the requests on the safety profiles, only for the admins
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	name, ok := mux.Vars(r)["name"]
+	switch {
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && !ok:
+		getProfiles(w, r)
+	case r.Method == http.MethodPut:
+		putProfile(w, r, name)
+	case r.Method == http.MethodDelete:
+		removeProfile(w, r, name)
+	}
+}
+
+func getProfiles(w http.ResponseWriter, r *http.Request) {
+	msg, ok := adminRequest(w, r, TypeGetProfiles)
+	if !ok {
+		return
+	}
+	if msg.Type != TypeCurrentProfiles {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// putProfile creates or replaces the profile with the name
+func putProfile(w http.ResponseWriter, r *http.Request, name string) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var sp SafetyProfile
+	err := decoder.Decode(&sp)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	sp.Name = name
+	msg, ok := adminRequest(w, r, TypePutProfile, sp)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeProfileUpdated:
+		log.Printf("[HandlerChannel] ProfileUpdated = %v", name)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func removeProfile(w http.ResponseWriter, r *http.Request, name string) {
+	msg, ok := adminRequest(w, r, TypeDeleteProfile, name)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeProfileDeleted:
+		log.Printf("[HandlerChannel] ProfileDeleted = %v", name)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeProfileNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
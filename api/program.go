@@ -0,0 +1,354 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The commands of the steps of a program
+const (
+	StepPosture = "posture"
+	StepReset   = "reset"
+	StepSleep   = "sleep"
+	StepPose    = "pose"
+)
+
+// Step provides the JSON scheme for a command of a program, run afterMs after the previous
+// one; the command is a joint name with the value, posture, reset, sleep or pose with the name
+type Step struct {
+	AfterMs int64      `json:"afterMs"`
+	Command string     `json:"command"`
+	Value   *uint16    `json:"value,omitempty"`
+	Posture *RobotPose `json:"posture,omitempty"`
+	Pose    string     `json:"pose,omitempty"`
+	Delta   *uint8     `json:"delta,omitempty"`
+}
+
+// Program provides the JSON scheme for a sequence of commands recorded or imported
+// under a name, or a Starlark script sending the commands, which can be replayed
+type Program struct {
+	Name       string     `json:"name"`
+	Steps      []Step     `json:"steps"`
+	Script     string     `json:"script,omitempty"`
+	RecordedBy string     `json:"recordedBy,omitempty"`
+	Recorded   *time.Time `json:"recorded,omitempty"`
+}
+
+// Recording provides the JSON scheme for starting to record the commands into the program
+type Recording struct {
+	Program string `json:"program"`
+}
+
+// Replay provides the JSON scheme for replaying a program: speed 2 halves the waits and
+// the deltas, and loops below 0 repeat it until stopped
+type Replay struct {
+	Program string  `json:"-"`
+	Speed   float64 `json:"speed,omitempty"`
+	Loops   int     `json:"loops,omitempty"`
+}
+
+// RecordingHandler process the requests to start and stop recording the commands of the current user
+func RecordingHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		startRecording(w, r)
+	case http.MethodDelete:
+		stopRecording(w, r)
+	}
+}
+
+// startRecording starts recording the commands into the program named in the body
+func startRecording(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var rec Recording
+	err := decoder.Decode(&rec)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypeStartRecording, rec)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeRecordingStarted:
+		log.Printf("[HandlerChannel] RecordingStarted = %v", rec.Program)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand, TypeUserNotFound:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeSlotReserved, TypeEmergencyStopped:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// stopRecording saves the recorded program
+func stopRecording(w http.ResponseWriter, r *http.Request) {
+	msg, ok := adminRequest(w, r, TypeStopRecording)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeRecordingStopped:
+		program, ok := msg.Value[0].(Program)
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("[HandlerChannel] RecordingStopped (name, steps) = %v, %v", program.Name, len(program.Steps))
+		js, err := json.Marshal(program)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/programs/"+program.Name)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(js)
+	case TypeUserNotFound:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeRecordingNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeSlotReserved, TypeEmergencyStopped:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// ProgramHandler process the requests on the programs
+func ProgramHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	name, ok := mux.Vars(r)["name"]
+	switch {
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && !ok:
+		getPrograms(w, r)
+	case r.Method == http.MethodPost:
+		addProgram(w, r)
+	case r.Method == http.MethodGet:
+		getProgram(w, r, name)
+	case r.Method == http.MethodPut:
+		putProgram(w, r, name)
+	case r.Method == http.MethodDelete:
+		removeProgram(w, r, name)
+	}
+}
+
+func getPrograms(w http.ResponseWriter, r *http.Request) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetPrograms,
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypeCurrentPrograms {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// getProgram exports the program as JSON
+func getProgram(w http.ResponseWriter, r *http.Request, name string) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeGetProgram,
+		Value: []interface{}{name},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeCurrentProgram:
+		js, err := json.Marshal(msg.Value[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+	case TypeProgramNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// addProgram creates the program named in the body
+func addProgram(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var program Program
+	err := decoder.Decode(&program)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypeAddProgram, program)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeProgramAdded:
+		log.Printf("[HandlerChannel] ProgramAdded = %v", program.Name)
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/programs/"+program.Name)
+		w.WriteHeader(http.StatusCreated)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeProgramExisted:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// putProgram imports the program in the body under the name
+func putProgram(w http.ResponseWriter, r *http.Request, name string) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var program Program
+	err := decoder.Decode(&program)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	program.Name = name
+	msg, ok := adminRequest(w, r, TypePutProgram, program)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeProgramAdded:
+		log.Printf("[HandlerChannel] ProgramAdded = %v", name)
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/programs/"+name)
+		w.WriteHeader(http.StatusCreated)
+	case TypeProgramUpdated:
+		log.Printf("[HandlerChannel] ProgramUpdated = %v", name)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func removeProgram(w http.ResponseWriter, r *http.Request, name string) {
+	msg, ok := adminRequest(w, r, TypeDeleteProgram, name)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeProgramDeleted:
+		log.Printf("[HandlerChannel] ProgramDeleted = %v", name)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeProgramNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// ReplayHandler process the requests to replay a program and to stop the replay
+func ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		replayProgram(w, r)
+	case http.MethodDelete:
+		stopReplay(w, r)
+	}
+}
+
+// replayProgram starts replaying the program with the speed and the loops in the body
+func replayProgram(w http.ResponseWriter, r *http.Request) {
+	// parse the request body, which may be empty
+	decoder := json.NewDecoder(r.Body)
+	var rp Replay
+	err := decoder.Decode(&rp)
+	if err != nil && err != io.EOF {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	rp.Program = mux.Vars(r)["name"]
+	msg, ok := adminRequest(w, r, TypeReplayProgram, rp)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeReplayStarted:
+		log.Printf("[HandlerChannel] ReplayStarted = %v", rp.Program)
+		w.WriteHeader(http.StatusAccepted)
+	case TypeInvalidCommand, TypeUserNotFound:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeProgramNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeSlotReserved, TypeEmergencyStopped, TypeReplayBusy:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// stopReplay stops replaying the program
+func stopReplay(w http.ResponseWriter, r *http.Request) {
+	msg, ok := adminRequest(w, r, TypeStopReplay, mux.Vars(r)["name"])
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeReplayStopped:
+		w.WriteHeader(http.StatusNoContent)
+	case TypeUserNotFound:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeProgramNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
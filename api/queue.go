@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MaxQueueWait is the longest a long-poll on a ticket waits
+const MaxQueueWait = 60 * time.Second
+
+// QueueEntry provides the struct for a user waiting for the robot
+type QueueEntry struct {
+	Ticket   string
+	UserInfo UserInfo
+	Callback string
+	Joined   time.Time
+}
+
+// NewQueueEntry instantiate a queue entry with a new ticket
+func NewQueueEntry(userInfo *UserInfo, callback string) *QueueEntry {
+	return &QueueEntry{
+		Ticket:   GenerateToken(),
+		UserInfo: *userInfo,
+		Callback: callback,
+		Joined:   time.Now(),
+	}
+}
+
+// QueueTicket provides the JSON scheme for the state of a queue ticket,
+// the Token is set once the user is promoted to the current user
+type QueueTicket struct {
+	Ticket        string `json:"-"`
+	Position      int    `json:"position"`
+	EstimatedWait int64  `json:"estimatedWait"`
+	Token         string `json:"-"`
+}
+
+// QueueCallback provides the JSON scheme posted to the callback on the promotion
+type QueueCallback struct {
+	Location string `json:"location"`
+	Token    string `json:"token"`
+}
+
+// QueueHandler process the requests on the queue tickets
+func QueueHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	ticket := mux.Vars(r)["ticket"]
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		getTicket(w, r, ticket)
+	case http.MethodDelete:
+		removeTicket(w, r, ticket)
+	}
+}
+
+// writeTicket responds with the state of the ticket, with the user location once promoted
+func writeTicket(w http.ResponseWriter, r *http.Request, status int, qt QueueTicket) {
+	js, err := json.Marshal(qt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if qt.Token != "" {
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/user/"+qt.Token)
+	} else {
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/queue/"+qt.Ticket)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// getTicket responds with the state of the ticket; with `wait` in seconds,
+// it holds the request until the user is promoted or the wait expires
+func getTicket(w http.ResponseWriter, r *http.Request, ticket string) {
+	wait, _ := strconv.Atoi(r.URL.Query().Get("wait"))
+	timeout := time.Duration(wait) * time.Second
+	if MaxQueueWait < timeout {
+		timeout = MaxQueueWait
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		// bypass the request to HandlerChannel
+		msg, ok := Request(HandlerChannel, HandlerMessage{
+			Type:  TypeGetTicket,
+			Value: []interface{}{ticket},
+		})
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+			return
+		}
+		switch msg.Type {
+		case TypeCurrentTicket:
+		case TypeTicketNotFound:
+			writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+			return
+		default: // something went wrong
+			writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+			return
+		}
+		qt, ok := msg.Value[0].(QueueTicket)
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		changed, ok := msg.Value[1].(chan struct{})
+		if qt.Token != "" || !ok || !time.Now().Before(deadline) {
+			writeTicket(w, r, http.StatusOK, qt)
+			return
+		}
+		// wait for the queue to move
+		select {
+		case <-changed:
+		case <-time.After(time.Until(deadline)):
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func removeTicket(w http.ResponseWriter, r *http.Request, ticket string) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeDeleteTicket,
+		Value: []interface{}{ticket},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeTicketDeleted:
+		log.Printf("[HandlerChannel] TicketDeleted = %v", ticket)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeTicketNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
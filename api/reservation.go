@@ -0,0 +1,301 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// Reservation provides the struct for a reserved time slot of the robot,
+// made with an API key or the token of the current user
+type Reservation struct {
+	ID    string
+	Name  string
+	Email string
+	Start time.Time
+	End   time.Time
+}
+
+// ReservationInfo provides the JSON scheme for Reservation
+type ReservationInfo struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ToReservationInfo parses Reservation to ReservationInfo
+func (rsv *Reservation) ToReservationInfo() ReservationInfo {
+	return ReservationInfo{
+		Name:  rsv.Name,
+		Email: rsv.Email,
+		Start: rsv.Start,
+		End:   rsv.End,
+	}
+}
+
+// ToUserInfo returns the UserInfo of who reserved the slot
+func (rsv *Reservation) ToUserInfo() UserInfo {
+	return UserInfo{
+		Name:  rsv.Name,
+		Email: rsv.Email,
+	}
+}
+
+// Overlaps checks if the reservation overlaps with the time slot
+func (rsv *Reservation) Overlaps(start time.Time, end time.Time) bool {
+	return rsv.Start.Before(end) && start.Before(rsv.End)
+}
+
+// Contains checks if the time is within the reservation
+func (rsv *Reservation) Contains(t time.Time) bool {
+	return !t.Before(rsv.Start) && t.Before(rsv.End)
+}
+
+// NewReservation instantiate a reservation
+func NewReservation(info *ReservationInfo) *Reservation {
+	return &Reservation{
+		ID:    GenerateToken(),
+		Name:  info.Name,
+		Email: info.Email,
+		Start: info.Start.UTC(),
+		End:   info.End.UTC(),
+	}
+}
+
+// ReservationHandler process the requests on the reservations
+func ReservationHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// respond to HEAD or OPTIONS
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id, ok := mux.Vars(r)["id"]
+	switch {
+	case r.Method == http.MethodGet && !ok:
+		getReservations(w, r)
+	case r.Method == http.MethodGet:
+		getReservation(w, r, id)
+	case r.Method == http.MethodPost:
+		addReservation(w, r)
+	case r.Method == http.MethodPut:
+		updateReservation(w, r, id)
+	case r.Method == http.MethodDelete:
+		removeReservation(w, r, id)
+	}
+}
+
+// requestReservations asks the controller for all the upcoming reservations
+func requestReservations() ([]ReservationInfo, bool) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetReservations,
+	})
+	if !ok || msg.Type != TypeCurrentReservations {
+		return nil, false
+	}
+	rsvs, ok := msg.Value[0].([]ReservationInfo)
+	return rsvs, ok
+}
+
+func getReservations(w http.ResponseWriter, r *http.Request) {
+	rsvs, ok := requestReservations()
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+		return
+	}
+	js, err := json.Marshal(rsvs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// icsTime formats the time for iCalendar
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsEscaper escapes the TEXT values for iCalendar (RFC 5545, 3.3.11)
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icsFold folds the content line at 75 octets (RFC 5545, 3.1) without splitting the characters
+func icsFold(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range line {
+		l := utf8.RuneLen(c)
+		if n+l > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(c)
+		n += l
+	}
+	return b.String()
+}
+
+// ReservationCalendarHandler responds with the reservations as an iCalendar feed
+func ReservationCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	rsvs, ok := requestReservations()
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+		return
+	}
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Interactions-HSG//Leubot//EN",
+		"X-WR-CALNAME:" + icsEscaper.Replace("Leubot "+APIHost+APIBasePath),
+	}
+	now := icsTime(time.Now())
+	for _, rsv := range rsvs {
+		// derive the UID from the slot not to disclose the reservation ID
+		uid := sha256.Sum256([]byte(rsv.Email + rsv.Start.String()))
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%x@%v", uid[:8], APIHost),
+			"DTSTAMP:"+now,
+			"DTSTART:"+icsTime(rsv.Start),
+			"DTEND:"+icsTime(rsv.End),
+			"SUMMARY:"+icsEscaper.Replace("Leubot reserved by "+rsv.Name),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for i := range lines {
+		lines[i] = icsFold(lines[i])
+	}
+	lines = append(lines, "")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "text/calendar; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(strings.Join(lines, "\r\n")))
+}
+
+func getReservation(w http.ResponseWriter, r *http.Request, id string) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeGetReservation,
+		Value: []interface{}{id},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeCurrentReservation:
+		js, err := json.Marshal(msg.Value[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+	case TypeReservationNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func addReservation(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var info ReservationInfo
+	err := decoder.Decode(&info)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypeAddReservation, info)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeReservationAdded: // respond with the location including the ID
+		rsv, ok := msg.Value[0].(Reservation)
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("[HandlerChannel] ReservationAdded (name, start, end) = %v, %v, %v", rsv.Name, rsv.Start, rsv.End)
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/reservations/"+rsv.ID)
+		w.WriteHeader(http.StatusCreated)
+	case TypeReservationConflict:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeInvalidReservation:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func updateReservation(w http.ResponseWriter, r *http.Request, id string) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var info ReservationInfo
+	err := decoder.Decode(&info)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypeUpdateReservation, id, info)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeReservationUpdated:
+		log.Printf("[HandlerChannel] ReservationUpdated (name, start, end) = %v, %v, %v", info.Name, info.Start, info.End)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeReservationNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeReservationConflict:
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeInvalidReservation:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+func removeReservation(w http.ResponseWriter, r *http.Request, id string) {
+	msg, ok := adminRequest(w, r, TypeDeleteReservation, id)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeReservationDeleted:
+		log.Printf("[HandlerChannel] ReservationDeleted")
+		w.WriteHeader(http.StatusNoContent)
+	case TypeReservationNotFound:
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
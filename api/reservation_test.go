@@ -0,0 +1,43 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestICSEscaper(t *testing.T) {
+	for in, want := range map[string]string{
+		"Ada Lovelace":         "Ada Lovelace",
+		`a\b`:                  `a\\b`,
+		"a,b;c":                `a\,b\;c`,
+		"a\nb":                 `a\nb`,
+		"a\r\nEND:VEVENT":      `a\nEND:VEVENT`,
+		`\,`:                   `\\\,`,
+		"Grüezi, Zürich\r\n\\": `Grüezi\, Zürich\n\\`,
+	} {
+		if got := icsEscaper.Replace(in); got != want {
+			t.Errorf("icsEscaper.Replace(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	short := "SUMMARY:Leubot reserved by Ada"
+	if got := icsFold(short); got != short {
+		t.Errorf("icsFold(%q) = %q", short, got)
+	}
+	long := "SUMMARY:" + strings.Repeat("ü", 100)
+	folded := icsFold(long)
+	for _, line := range strings.Split(folded, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("the line has %v octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("a character is split: %q", line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != long {
+		t.Errorf("unfolded to %q, want %q", unfolded, long)
+	}
+}
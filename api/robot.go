@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Interactions-HSG/leubot/armlink"
 )
@@ -15,10 +17,67 @@ type JointInfo struct {
 	Value uint16 `json:"value"`
 }
 
-// RobotCommand is a struct for each command
+// RobotCommand is a struct for each command, moving the joint to the value or by the offset
+// from where it is, clamped to the limits instead of refused if clamp
 type RobotCommand struct {
-	Token string `json:"token"`
-	Value uint16 `json:"value"`
+	Token  string `json:"token"`
+	Value  uint16 `json:"value"`
+	Offset *int   `json:"offset,omitempty"`
+	Clamp  bool   `json:"clamp,omitempty"`
+	Speed
+}
+
+// Speed provides the JSON scheme for the speed of a move in place of the ArmLink delta:
+// how long the move takes in ms, or the top speed of the joints in degrees per second
+type Speed struct {
+	DurationMs  *int64   `json:"durationMs,omitempty"`
+	MaxVelocity *float64 `json:"maxVelocity,omitempty"`
+}
+
+// Completion tells the ArmLink delta a move is sent with, how long it takes and when it completes
+type Completion struct {
+	Delta       uint8     `json:"delta"`
+	DurationMs  int64     `json:"durationMs"`
+	CompletesAt time.Time `json:"completesAt"`
+}
+
+// completionOf returns the completion in the reply to a move, false if there's none
+func completionOf(msg HandlerMessage) (Completion, bool) {
+	for _, v := range msg.Value {
+		if c, ok := v.(Completion); ok {
+			return c, true
+		}
+	}
+	return Completion{}, false
+}
+
+// writeCompletion responds that the move is accepted with when it completes if known
+func writeCompletion(w http.ResponseWriter, r *http.Request, msg HandlerMessage) {
+	c, ok := completionOf(msg)
+	if !ok {
+		w.WriteHeader(http.StatusAccepted) // 202
+		return
+	}
+	js, err := json.Marshal(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusAccepted) // 202
+	w.Write(js)
+}
+
+// JointMotion tells where the joint is moving to and when it gets there
+type JointMotion struct {
+	JointInfo
+	Completion
+}
+
+// PoseMotion tells where the joints are moving to and when they get there
+type PoseMotion struct {
+	RobotPose
+	Completion
 }
 
 // RobotPose stores the rotations of each joint
@@ -41,6 +100,68 @@ func (rp *RobotPose) String() string {
 	return fmt.Sprintf("Base: %v, Shoulder: %v, Elbow: %v, WristAngle: %v, WristRotation: %v, Gripper: %v", rp.Base, rp.Shoulder, rp.Elbow, rp.WristAngle, rp.WristRotation, rp.Gripper)
 }
 
+// JointNames lists the joints of RobotPose in the order of ArmLinkPacket
+var JointNames = []string{"base", "shoulder", "elbow", "wristAngle", "wristRotation", "gripper"}
+
+// JointRange holds the lower and upper limits for a joint
+type JointRange struct {
+	Min uint16 `json:"min"`
+	Max uint16 `json:"max"`
+}
+
+// Contains checks if the value is within the range
+func (jr JointRange) Contains(v uint16) bool {
+	return jr.Min <= v && v <= jr.Max
+}
+
+// JointRanges holds the Backhoe/Joint positioning limits of the Reactor Arm
+var JointRanges = map[string]JointRange{
+	"base":          {0, 1023},
+	"shoulder":      {205, 810},
+	"elbow":         {210, 900},
+	"wristAngle":    {200, 830},
+	"wristRotation": {0, 1023},
+	"gripper":       {0, 512},
+	"delta":         {0, 254},
+}
+
+// Get returns the value of the joint by its name
+func (rp *RobotPose) Get(joint string) uint16 {
+	switch joint {
+	case "base":
+		return rp.Base
+	case "shoulder":
+		return rp.Shoulder
+	case "elbow":
+		return rp.Elbow
+	case "wristAngle":
+		return rp.WristAngle
+	case "wristRotation":
+		return rp.WristRotation
+	case "gripper":
+		return rp.Gripper
+	}
+	return 0
+}
+
+// Set sets the value of the joint by its name
+func (rp *RobotPose) Set(joint string, v uint16) {
+	switch joint {
+	case "base":
+		rp.Base = v
+	case "shoulder":
+		rp.Shoulder = v
+	case "elbow":
+		rp.Elbow = v
+	case "wristAngle":
+		rp.WristAngle = v
+	case "wristRotation":
+		rp.WristRotation = v
+	case "gripper":
+		rp.Gripper = v
+	}
+}
+
 // PostureCommand is a struct for a posture
 type PostureCommand struct {
 	Token         string `json:"token"`
@@ -51,6 +172,53 @@ type PostureCommand struct {
 	WristRotation uint16 `json:"wristRotation"`
 	Gripper       uint16 `json:"gripper"`
 	Delta         uint8  `json:"delta"`
+	Speed
+}
+
+// PostureJog is a struct for moving the joints by the offsets from where they are at once,
+// with the delta for the speed, clamped to the limits instead of refused if clamp
+type PostureJog struct {
+	Token         string `json:"-"`
+	Base          int    `json:"base,omitempty"`
+	Shoulder      int    `json:"shoulder,omitempty"`
+	Elbow         int    `json:"elbow,omitempty"`
+	WristAngle    int    `json:"wristAngle,omitempty"`
+	WristRotation int    `json:"wristRotation,omitempty"`
+	Gripper       int    `json:"gripper,omitempty"`
+	Delta         *uint8 `json:"delta,omitempty"`
+	Clamp         bool   `json:"clamp,omitempty"`
+	Speed
+}
+
+// Offset returns the offset for the joint
+func (pj *PostureJog) Offset(joint string) int {
+	switch joint {
+	case "base":
+		return pj.Base
+	case "shoulder":
+		return pj.Shoulder
+	case "elbow":
+		return pj.Elbow
+	case "wristAngle":
+		return pj.WristAngle
+	case "wristRotation":
+		return pj.WristRotation
+	case "gripper":
+		return pj.Gripper
+	}
+	return 0
+}
+
+// RobotPose returns the RobotPose for the posCom
+func (posCom *PostureCommand) RobotPose() RobotPose {
+	return RobotPose{
+		Base:          posCom.Base,
+		Shoulder:      posCom.Shoulder,
+		Elbow:         posCom.Elbow,
+		WristAngle:    posCom.WristAngle,
+		WristRotation: posCom.WristRotation,
+		Gripper:       posCom.Gripper,
+	}
 }
 
 // RobotHandler process the request to /base
@@ -70,34 +238,34 @@ func RobotHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// process GET and PUT
+	// process GET, PUT and PATCH
 	switch r.Method {
 	case http.MethodGet:
 		getState(w, r)
 	case http.MethodPut:
 		putState(w, r)
+	case http.MethodPatch:
+		patchPosture(w, r)
 	}
 }
 
 // getPosture gets the current posture
 func getPosture(w http.ResponseWriter, r *http.Request) {
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  TypeGetPosture,
 		Value: []interface{}{},
-	}
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	// check the channel status
 	if !ok {
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 	// respond with the result
 	rp, ok := msg.Value[0].(RobotPose)
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+		return
 	}
 	js, err := json.Marshal(rp)
 	if err != nil {
@@ -129,22 +297,17 @@ func getState(w http.ResponseWriter, r *http.Request) {
 		getPosture(w, r)
 		return
 	default:
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("No such joint")) // 500
 		return
 	}
 
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  reqType,
 		Value: []interface{}{},
-	}
-
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	if !ok {
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 
@@ -164,13 +327,13 @@ func getState(w http.ResponseWriter, r *http.Request) {
 	case TypeCurrentGripper:
 		name = "gripper"
 	default: // something went wrong
-		log.Printf("%#v: %s", http.StatusInternalServerError, msg.Type)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 		return
 	}
 	val, ok := msg.Value[0].(uint16)
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+		return
 	}
 	log.Printf("%s: %v", name, val)
 	jointInfo := &JointInfo{name, val}
@@ -210,8 +373,8 @@ func putState(w http.ResponseWriter, r *http.Request) {
 		putSleep(w, r)
 		return
 	default:
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("No such joint")) // 500
+		return
 	}
 
 	// parse the request body
@@ -219,8 +382,7 @@ func putState(w http.ResponseWriter, r *http.Request) {
 	var robotCommand RobotCommand
 	err := decoder.Decode(&robotCommand)
 	if err != nil {
-		log.Printf("%#v", http.StatusBadRequest)
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
 		return
 	}
 
@@ -228,42 +390,55 @@ func putState(w http.ResponseWriter, r *http.Request) {
 	if token := r.Header.Get("X-API-Key"); token != "" {
 		robotCommand.Token = token
 	} else {
-		log.Printf("%#v", http.StatusUnauthorized)
-		w.WriteHeader(http.StatusUnauthorized) // 401
+		writeProblem(w, r, http.StatusUnauthorized, problemMissingToken.problem("The token is required in X-API-Key header")) // 401
 		return
 	}
 
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  reqType,
 		Value: []interface{}{robotCommand},
-	}
-
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	if !ok {
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 
 	// respond with the result
 	switch msg.Type {
-	case TypeActionPerformed: // the requested action is performed
-		log.Printf("robotCommand.Value: %v", robotCommand.Value)
+	case TypeActionPerformed: // the requested action is performed, tell where the joint is moving to and when it gets there
+		value, ok := msg.Value[0].(uint16)
+		c, ok2 := completionOf(msg)
+		if !ok || !ok2 {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("robotCommand.Value: %v", value)
+		js, err := json.Marshal(JointMotion{JointInfo{strings.TrimPrefix(r.URL.Path, APIBasePath+"/"), value}, c})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusAccepted) // 202
+		w.Write(js)
 	case TypeInvalidCommand: // the invalid value provided
 		log.Printf("InvalidCommand: %v", robotCommand.Value)
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	case TypeInvalidToken: // the invalid token provided
-		log.Printf("InvalidToken: %v", robotCommand.Token)
-		w.WriteHeader(http.StatusUnauthorized) // 401
+		log.Println("InvalidToken")
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // the key may not move the robot
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeEmergencyStopped: // the emergency stop is engaged
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
 	case TypeUserNotFound: // the user not found
 		log.Println("UserNotFound")
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	default: // something went wrong
-		log.Printf("%#v: %s", http.StatusInternalServerError, msg.Type)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 	}
 }
 
@@ -274,8 +449,7 @@ func putPosture(w http.ResponseWriter, r *http.Request) {
 	var posCom PostureCommand
 	err := decoder.Decode(&posCom)
 	if err != nil {
-		log.Printf("%#v", http.StatusBadRequest)
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
 		return
 	}
 
@@ -283,39 +457,106 @@ func putPosture(w http.ResponseWriter, r *http.Request) {
 	if token := r.Header.Get("X-API-Key"); token != "" {
 		posCom.Token = token
 	} else {
-		log.Printf("%#v", http.StatusUnauthorized)
-		w.WriteHeader(http.StatusUnauthorized) // 401
+		writeProblem(w, r, http.StatusUnauthorized, problemMissingToken.problem("The token is required in X-API-Key header")) // 401
 		return
 	}
 
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  TypePutPosture,
 		Value: []interface{}{posCom},
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+
+	// respond with the result
+	switch msg.Type {
+	case TypeActionPerformed: // the requested action is performed, tell when the joints get there
+		log.Println("Posture")
+		writeCompletion(w, r, msg)
+	case TypeInvalidCommand: // the invalid value provided
+		log.Printf("InvalidCommand: %v", posCom)
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	case TypeInvalidToken: // the invalid token provided
+		log.Println("InvalidToken")
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // the key may not move the robot
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeEmergencyStopped: // the emergency stop is engaged
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeUserNotFound: // the user not found
+		log.Println("UserNotFound")
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// patchPosture moves the joints by the offsets at once
+func patchPosture(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var pj PostureJog
+	err := decoder.Decode(&pj)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+
+	// extract token from the X-API-Key header
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		pj.Token = token
+	} else {
+		writeProblem(w, r, http.StatusUnauthorized, problemMissingToken.problem("The token is required in X-API-Key header")) // 401
+		return
 	}
 
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypePatchPosture,
+		Value: []interface{}{pj},
+	})
 	if !ok {
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 
 	// respond with the result
 	switch msg.Type {
-	case TypeActionPerformed: // the requested action is performed
-		log.Println("Posture")
+	case TypeActionPerformed: // the requested action is performed, tell where the joints are moving to
+		rp, ok := msg.Value[0].(RobotPose)
+		c, ok2 := completionOf(msg)
+		if !ok || !ok2 {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("Jog: %v", rp.String())
+		js, err := json.Marshal(PoseMotion{rp, c})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusAccepted) // 202
+		w.Write(js)
+	case TypeInvalidCommand: // the invalid value provided
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	case TypeInvalidToken: // the invalid token provided
-		log.Printf("InvalidToken: %v", posCom.Token)
-		w.WriteHeader(http.StatusUnauthorized) // 401
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // the key may not move the robot
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeEmergencyStopped: // the emergency stop is engaged
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
 	case TypeUserNotFound: // the user not found
-		log.Println("UserNotFound")
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	default: // something went wrong
-		log.Printf("%#v: %s", http.StatusInternalServerError, msg.Type)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 	}
 }
 
@@ -324,21 +565,17 @@ func putReset(w http.ResponseWriter, r *http.Request) {
 	// extract token from the X-API-Key header
 	token := r.Header.Get("X-API-Key")
 	if token == "" {
-		w.WriteHeader(http.StatusBadRequest) // 401
+		writeProblem(w, r, http.StatusBadRequest, problemMissingToken.problem("The token is required in X-API-Key header")) // 400
 		return
 	}
 
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  TypePutReset,
 		Value: []interface{}{token},
-	}
-
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	if !ok {
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 
@@ -348,14 +585,19 @@ func putReset(w http.ResponseWriter, r *http.Request) {
 		log.Println("Reset")
 		w.WriteHeader(http.StatusAccepted) // 202
 	case TypeInvalidToken: // the invalid token provided
-		log.Printf("InvalidToken: %v", token)
-		w.WriteHeader(http.StatusUnauthorized) // 401
+		log.Println("InvalidToken")
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // the key may not move the robot
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeEmergencyStopped: // the emergency stop is engaged
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
 	case TypeUserNotFound: // the user not found
 		log.Println("UserNotFound")
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	default: // something went wrong
-		log.Printf("%#v: %s", http.StatusInternalServerError, msg.Type)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 	}
 }
 
@@ -364,21 +606,17 @@ func putSleep(w http.ResponseWriter, r *http.Request) {
 	// extract token from the X-API-Key header
 	token := r.Header.Get("X-API-Key")
 	if token == "" {
-		w.WriteHeader(http.StatusBadRequest) // 401
+		writeProblem(w, r, http.StatusBadRequest, problemMissingToken.problem("The token is required in X-API-Key header")) // 400
 		return
 	}
 
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  TypePutSleep,
 		Value: []interface{}{token},
-	}
-
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	if !ok {
-		log.Printf("%#v", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 
@@ -388,13 +626,18 @@ func putSleep(w http.ResponseWriter, r *http.Request) {
 		log.Println("Sleep")
 		w.WriteHeader(http.StatusAccepted) // 202
 	case TypeInvalidToken: // the invalid token provided
-		log.Printf("InvalidToken: %v", token)
-		w.WriteHeader(http.StatusUnauthorized) // 401
+		log.Println("InvalidToken")
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // the key may not move the robot
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeEmergencyStopped: // the emergency stop is engaged
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
 	case TypeUserNotFound: // the user not found
 		log.Println("UserNotFound")
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	default: // something went wrong
-		log.Printf("%#v: %s", http.StatusInternalServerError, msg.Type)
-		w.WriteHeader(http.StatusInternalServerError) // 500
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -15,6 +16,7 @@ type Route struct {
 	Methods     []string
 	Pattern     string
 	HandlerFunc http.HandlerFunc
+	Operations  map[string]Operation
 }
 
 // Routes contain the Route
@@ -30,6 +32,9 @@ var (
 	// APIProto for API access protocol
 	APIProto string
 
+	// APIVersion is the version of the API
+	APIVersion string
+
 	// HandlerChannel is used to communicate between the router and other application logic
 	HandlerChannel chan HandlerMessage
 )
@@ -38,109 +43,1204 @@ var (
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		sr := &statusRecorder{w, http.StatusOK}
 
-		inner.ServeHTTP(w, r)
+		inner.ServeHTTP(sr, r)
 
+		elapsed := time.Since(start)
+		observeRequest(name, r.Method, sr.status, elapsed.Seconds())
 		log.Printf(
 			"%s %s %s %s",
 			r.Method,
-			r.RequestURI,
+			redact(r),
 			name,
-			time.Since(start),
+			elapsed,
 		)
 	})
 }
 
+// redact hides the token and the verification code in the path of the request for the logs
+func redact(r *http.Request) string {
+	uri := r.RequestURI
+	for _, v := range []string{"token", "code"} {
+		if s := mux.Vars(r)[v]; s != "" {
+			uri = strings.Replace(uri, s, "{"+v+"}", 1)
+		}
+	}
+	return uri
+}
+
 func defaultHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println(r.RequestURI)
 }
 
-// NewRouter creats a new instance of Router
-func NewRouter(apiHost string, apiPath string, apiProto string, hmc chan HandlerMessage, ver string) *mux.Router {
-	APIBasePath = fmt.Sprintf("/%s/%s", apiPath, ver)
-	APIHost = apiHost
-	APIProto = apiProto
-	log.Printf("Serving at %s%s%s", APIProto, APIHost, APIBasePath)
+// robotCommandResponses are the responses for the commands moving the robot
+var robotCommandResponses = []Response{
+	{http.StatusAccepted, "target value accepted, robot is moving towards it", nil},
+	{http.StatusBadRequest, "bad input parameter, out of the limits for the role, breaking a constraint or into a keep-out zone", nil},
+	{http.StatusUnauthorized, "invalid token provided; not authorized", nil},
+	{http.StatusForbidden, "observer keys may not move the robot", nil},
+	{http.StatusConflict, "the robot is reserved by another user or the emergency stop is engaged", nil},
+}
 
-	var routes = Routes{
+// moveResponses are the responses for the commands moving the robot at the speed,
+// telling where and when the move completes in the body
+func moveResponses(description string, body interface{}) []Response {
+	return append([]Response{{http.StatusAccepted, description, body}}, robotCommandResponses[1:]...)
+}
+
+// speedDescription describes the speed of the moves
+const speedDescription = " Give the `durationMs` the move takes or the top speed of the joints `maxVelocity` in °/s in place of the `delta`, refused if ArmLink can't move that slowly at once; the response tells the delta and when the move completes."
+
+// adminResponses are the responses for the requests requiring an admin key
+var adminResponses = []Response{
+	{http.StatusUnauthorized, "missing token or not an API key", nil},
+	{http.StatusForbidden, "not an admin key", nil},
+}
+
+// reservationResponses are the responses for the requests reserving the robot
+var reservationResponses = []Response{
+	{http.StatusUnauthorized, "missing token, or neither an API key nor the token of the current user", nil},
+	{http.StatusForbidden, "an observer key, or the reservation of someone else", nil},
+}
+
+// rangeDescription describes the valid range for the joint
+func rangeDescription(joint string) string {
+	jr := JointRanges[joint]
+	return fmt.Sprintf("The valid range for `value` is [%v,%v]. Give the `offset` in ticks instead to move from where the joint is, refused beyond the range unless `clamp` stops it there; the response tells the value the joint is moving to."+speedDescription, jr.Min, jr.Max)
+}
+
+// NewRoutes creates the Routes served under APIBasePath
+func NewRoutes() Routes {
+	return Routes{
 		Route{
 			"/user",
-			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost},
-			APIBasePath + "/user",
+			[]string{http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost},
+			"/user",
 			UserHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getUser",
+					Tag:         "user",
+					Summary:     "Get the current user information",
+					Description: "Check if anyone is currently using the robot control API",
+					Responses:   []Response{{http.StatusOK, "current user info", UserInfo{}}},
+				},
+				http.MethodDelete: {
+					ID:          "kickUser",
+					Tag:         "admin",
+					Summary:     "Remove the current user",
+					Description: "Terminate the session of whoever is using the robot and put the robot to sleep. The user is notified with the reason.",
+					Auth:        true,
+					Parameters:  []Parameter{{"reason", "why the user is removed, told to the user"}},
+					Responses: append([]Response{
+						{http.StatusNoContent, "user removed", nil},
+						{http.StatusNotFound, "nobody is using the robot", nil},
+					}, adminResponses...),
+				},
+				http.MethodPost: {
+					ID:          "addUser",
+					Tag:         "user",
+					Summary:     "Add a user",
+					Description: "Add yourself to the system and gain the API Key for the robot API access. The URL in the `Location` header ends with the API Key, a signed session token which expires unless refreshed at `/token`. The `callback` posted the token when the user waiting in the queue gets the robot must be an `http` or `https` URL at one of the `--callbackHost`s if any.",
+					Request:     UserRequest{},
+					Responses: []Response{
+						{http.StatusCreated, "user created", Token{}},
+						{http.StatusAccepted, "the robot is in use; queued with the ticket URL in the `Location` header. With the email verification, the link to add the user was emailed instead, without the `Location` header", QueueTicket{}},
+						{http.StatusBadRequest, "invalid input, object invalid, or the callback is not allowed", nil},
+						{http.StatusForbidden, "the users must log in instead", nil},
+						{http.StatusConflict, "another user already exists or the robot is reserved", nil},
+					},
+				},
+			},
 		},
 		Route{
 			"/user/{token}",
 			[]string{http.MethodDelete, http.MethodOptions},
-			APIBasePath + "/user/{token}",
+			"/user/{token}",
 			UserHandler,
+			map[string]Operation{
+				http.MethodDelete: {
+					ID:          "removeUser",
+					Tag:         "user",
+					Summary:     "Remove a user",
+					Description: "Remove yourself from the system with the token to release the privilege to others. With an admin key instead of the token, remove whoever is using the robot.",
+					Responses: []Response{
+						{http.StatusNoContent, "user deleted", nil},
+						{http.StatusUnauthorized, "the token is not of the current user", nil},
+						{http.StatusForbidden, "observer keys may not remove the user", nil},
+						{http.StatusNotFound, "invalid token, no such user", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/queue/{ticket}",
+			[]string{http.MethodDelete, http.MethodGet, http.MethodOptions},
+			"/queue/{ticket}",
+			QueueHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getTicket",
+					Tag:         "user",
+					Summary:     "Get the position in the queue",
+					Description: "Check the position and the estimated wait in seconds. Once promoted, the `Location` header points to the user with the API Key.",
+					Parameters:  []Parameter{{"wait", "hold the request up to the seconds until the user is promoted"}},
+					Responses: []Response{
+						{http.StatusOK, "current state of the ticket", QueueTicket{}},
+						{http.StatusNotFound, "no such ticket", nil},
+					},
+				},
+				http.MethodDelete: {
+					ID:      "removeTicket",
+					Tag:     "user",
+					Summary: "Leave the queue",
+					Responses: []Response{
+						{http.StatusNoContent, "ticket deleted", nil},
+						{http.StatusNotFound, "no such ticket", nil},
+					},
+				},
+			},
 		},
 		Route{
 			"/base",
 			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/base",
+			"/base",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getBase",
+					Tag:       "robot",
+					Summary:   "Get the base rotation",
+					Responses: []Response{{http.StatusOK, "current value of the base rotation", JointInfo{}}},
+				},
+				http.MethodPut: {
+					ID:          "putBase",
+					Tag:         "robot",
+					Summary:     "Set the base rotation",
+					Description: rangeDescription("base"),
+					Auth:        true,
+					Request:     RobotCommand{},
+					Responses:   moveResponses("target value accepted, robot is moving towards it", JointMotion{}),
+				},
+			},
 		},
 		Route{
 			"/shoulder",
 			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/shoulder",
+			"/shoulder",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getShoulder",
+					Tag:       "robot",
+					Summary:   "Get the shoulder joint rotation",
+					Responses: []Response{{http.StatusOK, "current value of the shoulder joint rotation", JointInfo{}}},
+				},
+				http.MethodPut: {
+					ID:          "putShoulder",
+					Tag:         "robot",
+					Summary:     "Set the shoulder joint rotation",
+					Description: rangeDescription("shoulder"),
+					Auth:        true,
+					Request:     RobotCommand{},
+					Responses:   moveResponses("target value accepted, robot is moving towards it", JointMotion{}),
+				},
+			},
 		},
 		Route{
 			"/elbow",
 			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/elbow",
+			"/elbow",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getElbow",
+					Tag:       "robot",
+					Summary:   "Get the elbow joint rotation",
+					Responses: []Response{{http.StatusOK, "current value of the elbow joint rotation", JointInfo{}}},
+				},
+				http.MethodPut: {
+					ID:          "putElbow",
+					Tag:         "robot",
+					Summary:     "Set the elbow joint rotation",
+					Description: rangeDescription("elbow"),
+					Auth:        true,
+					Request:     RobotCommand{},
+					Responses:   moveResponses("target value accepted, robot is moving towards it", JointMotion{}),
+				},
+			},
 		},
 		Route{
 			"/wrist/angle",
 			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/wrist/angle",
+			"/wrist/angle",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getWristAngle",
+					Tag:       "robot",
+					Summary:   "Get the wrist angle",
+					Responses: []Response{{http.StatusOK, "current value of the wrist angle", JointInfo{}}},
+				},
+				http.MethodPut: {
+					ID:          "putWristAngle",
+					Tag:         "robot",
+					Summary:     "Set the wrist angle",
+					Description: rangeDescription("wristAngle"),
+					Auth:        true,
+					Request:     RobotCommand{},
+					Responses:   moveResponses("target value accepted, robot is moving towards it", JointMotion{}),
+				},
+			},
 		},
 		Route{
 			"/wrist/rotation",
 			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/wrist/rotation",
+			"/wrist/rotation",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getWristRotation",
+					Tag:       "robot",
+					Summary:   "Get the wrist rotation",
+					Responses: []Response{{http.StatusOK, "current value of the wrist rotation", JointInfo{}}},
+				},
+				http.MethodPut: {
+					ID:          "putWristRotation",
+					Tag:         "robot",
+					Summary:     "Set the wrist rotation",
+					Description: rangeDescription("wristRotation"),
+					Auth:        true,
+					Request:     RobotCommand{},
+					Responses:   moveResponses("target value accepted, robot is moving towards it", JointMotion{}),
+				},
+			},
 		},
 		Route{
 			"/gripper",
 			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/gripper",
+			"/gripper",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getGripper",
+					Tag:       "robot",
+					Summary:   "Get the gripper",
+					Responses: []Response{{http.StatusOK, "current value of the gripper", JointInfo{}}},
+				},
+				http.MethodPut: {
+					ID:          "putGripper",
+					Tag:         "robot",
+					Summary:     "Set the gripper",
+					Description: rangeDescription("gripper") + " where `0` is to close and `512` is to open all the way.",
+					Auth:        true,
+					Request:     RobotCommand{},
+					Responses:   moveResponses("target value accepted, robot is moving towards it", JointMotion{}),
+				},
+			},
 		},
 		Route{
 			"/posture",
-			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut},
-			APIBasePath + "/posture",
+			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPatch, http.MethodPut},
+			"/posture",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getPosture",
+					Tag:       "robot",
+					Summary:   "Get the posture",
+					Responses: []Response{{http.StatusOK, "current values of all the joints", RobotPose{}}},
+				},
+				http.MethodPut: {
+					ID:          "putPosture",
+					Tag:         "robot",
+					Summary:     "Set the posture",
+					Description: "Set all the joints at once with the `delta` for the speed." + speedDescription,
+					Auth:        true,
+					Request:     PostureCommand{},
+					Responses:   moveResponses("target posture accepted, robot is moving towards it", Completion{}),
+				},
+				http.MethodPatch: {
+					ID:          "patchPosture",
+					Tag:         "robot",
+					Summary:     "Move the joints by offsets",
+					Description: "Move the joints by the offsets in ticks from where they are at once, e.g. `{\"base\": -10, \"gripper\": 20}`, with the `delta` for the speed. The move is refused if a joint would leave its limits, unless `clamp` stops it at the limit. Responds with the posture the joints are moving to." + speedDescription,
+					Auth:        true,
+					Request:     PostureJog{},
+					Responses:   moveResponses("offsets accepted, robot is moving to the posture", PoseMotion{}),
+				},
+			},
+		},
+		Route{
+			"/posture/check",
+			[]string{http.MethodOptions, http.MethodPost},
+			"/posture/check",
+			PostureCheckHandler,
+			map[string]Operation{
+				http.MethodPost: {
+					ID:          "checkPosture",
+					Tag:         "robot",
+					Summary:     "Check a posture without moving",
+					Description: "Dry run of `PUT posture`: list every limit, joint constraint and keep-out zone the posture would break. The limits for the token in `X-API-Key` apply if it is a key or of the current user, the soft limits otherwise.",
+					Request:     PostureCommand{},
+					Responses:   []Response{{http.StatusOK, "whether the posture is valid and the problems otherwise", PoseCheck{}}, {http.StatusBadRequest, "malformed body", nil}},
+				},
+			},
 		},
 		Route{
 			"PutReset",
 			[]string{http.MethodOptions, http.MethodPut},
-			APIBasePath + "/reset",
+			"/reset",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "resetRobot",
+					Tag:         "robot",
+					Summary:     "Reset the robot",
+					Description: "Reset the robot to the initial state.",
+					Auth:        true,
+					Responses:   robotCommandResponses,
+				},
+			},
 		},
 		Route{
 			"PutSleep",
 			[]string{http.MethodOptions, http.MethodPut},
-			APIBasePath + "/sleep",
+			"/sleep",
 			RobotHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "sleepRobot",
+					Tag:         "robot",
+					Summary:     "Sleep the robot",
+					Description: "Put the robot in the sleep mode until the next command.",
+					Auth:        true,
+					Responses:   robotCommandResponses,
+				},
+			},
+		},
+		Route{
+			"/poses",
+			[]string{http.MethodGet, http.MethodOptions},
+			"/poses",
+			PoseHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getPoses",
+					Tag:       "robot",
+					Summary:   "List the named poses",
+					Responses: []Response{{http.StatusOK, "the built-in and the saved poses", []NamedPose{}}},
+				},
+			},
+		},
+		Route{
+			"/poses/{name}",
+			[]string{http.MethodDelete, http.MethodGet, http.MethodOptions, http.MethodPut},
+			"/poses/{name}",
+			PoseHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getPose",
+					Tag:       "robot",
+					Summary:   "Get a named pose",
+					Responses: []Response{{http.StatusOK, "the named pose", NamedPose{}}, {http.StatusNotFound, "no such pose", nil}},
+				},
+				http.MethodPut: {
+					ID:          "putPose",
+					Tag:         "robot",
+					Summary:     "Save a named pose",
+					Description: "Save the `pose` in the body under the name, or the current pose if the body or the `pose` is left out. The built-in poses `home` and `sleep` are read-only.",
+					Auth:        true,
+					Request:     NamedPose{},
+					Responses: []Response{
+						{http.StatusCreated, "pose saved", nil},
+						{http.StatusNoContent, "pose replaced", nil},
+						{http.StatusBadRequest, "out of the hard limits, read-only or the robot is sleeping", nil},
+						{http.StatusUnauthorized, "missing or invalid token", nil},
+						{http.StatusForbidden, "observer keys may only read", nil},
+					},
+				},
+				http.MethodDelete: {
+					ID:      "removePose",
+					Tag:     "robot",
+					Summary: "Delete a named pose",
+					Auth:    true,
+					Responses: []Response{
+						{http.StatusNoContent, "pose deleted", nil},
+						{http.StatusBadRequest, "read-only", nil},
+						{http.StatusUnauthorized, "missing or invalid token", nil},
+						{http.StatusForbidden, "observer keys may only read", nil},
+						{http.StatusNotFound, "no such pose", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/poses/{name}/apply",
+			[]string{http.MethodOptions, http.MethodPut},
+			"/poses/{name}/apply",
+			PoseApplyHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "applyPose",
+					Tag:         "robot",
+					Summary:     "Move to a named pose",
+					Description: "Set all the joints to the named pose with the optional `delta` for the speed, checked like `PUT posture`; `sleep` puts the robot to sleep." + speedDescription,
+					Auth:        true,
+					Request:     PoseCommand{},
+					Responses:   append([]Response{{http.StatusNotFound, "no such pose", nil}}, moveResponses("pose accepted, robot is moving towards it", Completion{})...),
+				},
+			},
+		},
+		Route{
+			"/recording",
+			[]string{http.MethodDelete, http.MethodOptions, http.MethodPut},
+			"/recording",
+			RecordingHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "startRecording",
+					Tag:         "program",
+					Summary:     "Start recording a program",
+					Description: "Record every successful command moving the robot with the token, with the time since the previous one, into the `program`.",
+					Auth:        true,
+					Request:     Recording{},
+					Responses: []Response{
+						{http.StatusNoContent, "recording", nil},
+						{http.StatusBadRequest, "no name or already recording", nil},
+						{http.StatusUnauthorized, "invalid token provided; not authorized", nil},
+						{http.StatusForbidden, "observer keys may not move the robot", nil},
+						{http.StatusConflict, "the robot is reserved by another user or the emergency stop is engaged", nil},
+					},
+				},
+				http.MethodDelete: {
+					ID:          "stopRecording",
+					Tag:         "program",
+					Summary:     "Stop recording and save the program",
+					Description: "The recording is also saved when the session ends.",
+					Auth:        true,
+					Responses: []Response{
+						{http.StatusCreated, "program saved at the URL in the `Location` header", Program{}},
+						{http.StatusBadRequest, "no user is using the robot", nil},
+						{http.StatusUnauthorized, "invalid token provided; not authorized", nil},
+						{http.StatusForbidden, "observer keys may not move the robot", nil},
+						{http.StatusConflict, "the robot is reserved by another user or the emergency stop is engaged", nil},
+						{http.StatusNotFound, "nothing is being recorded with the token", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/programs",
+			[]string{http.MethodGet, http.MethodOptions, http.MethodPost},
+			"/programs",
+			ProgramHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getPrograms",
+					Tag:       "program",
+					Summary:   "List the programs",
+					Responses: []Response{{http.StatusOK, "the recorded, the imported and the scripted programs", []Program{}}},
+				},
+				http.MethodPost: {
+					ID:          "addProgram",
+					Tag:         "program",
+					Summary:     "Upload a program",
+					Description: "Create the program named in the body from the steps or from a Starlark `script`. The script calls `base`, `shoulder`, `elbow`, `wrist_angle`, `wrist_rotation` and `gripper` with the position, `posture` with any of the joints and `delta` as keywords, `pose` with the name, `reset`, `sleep`, `wait` with the milliseconds and `current` for the pose, and may use variables, loops and functions.",
+					Auth:        true,
+					Request:     Program{},
+					Responses: []Response{
+						{http.StatusCreated, "program created at the URL in the `Location` header", nil},
+						{http.StatusBadRequest, "invalid step or script", nil},
+						{http.StatusUnauthorized, "missing or invalid token", nil},
+						{http.StatusForbidden, "observer keys may only read", nil},
+						{http.StatusConflict, "a program with the name exists", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/programs/{name}",
+			[]string{http.MethodDelete, http.MethodGet, http.MethodOptions, http.MethodPut},
+			"/programs/{name}",
+			ProgramHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getProgram",
+					Tag:       "program",
+					Summary:   "Export a program",
+					Responses: []Response{{http.StatusOK, "the program as JSON", Program{}}, {http.StatusNotFound, "no such program", nil}},
+				},
+				http.MethodPut: {
+					ID:          "putProgram",
+					Tag:         "program",
+					Summary:     "Import a program",
+					Description: "Save the exported program in the body under the name. Each step waits `afterMs` after the previous one and runs the `command`: a joint name with the `value`, `posture` with the `posture` and the `delta`, `pose` with the name in `pose`, `reset` or `sleep`. A program may have a `script` instead of the steps.",
+					Auth:        true,
+					Request:     Program{},
+					Responses: []Response{
+						{http.StatusCreated, "program imported", nil},
+						{http.StatusNoContent, "program replaced", nil},
+						{http.StatusBadRequest, "invalid step", nil},
+						{http.StatusUnauthorized, "missing or invalid token", nil},
+						{http.StatusForbidden, "observer keys may only read", nil},
+					},
+				},
+				http.MethodDelete: {
+					ID:      "removeProgram",
+					Tag:     "program",
+					Summary: "Delete a program",
+					Auth:    true,
+					Responses: []Response{
+						{http.StatusNoContent, "program deleted", nil},
+						{http.StatusUnauthorized, "missing or invalid token", nil},
+						{http.StatusForbidden, "observer keys may only read", nil},
+						{http.StatusNotFound, "no such program", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/actions",
+			[]string{http.MethodGet, http.MethodOptions, http.MethodPut},
+			"/actions",
+			ActionSettingsHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getActionSettings",
+					Tag:       "action",
+					Summary:   "Get the settings of the actions",
+					Responses: []Response{{http.StatusOK, "the gripper positions and width, the squeeze and the approach height", ActionSettings{}}},
+				},
+				http.MethodPut: {
+					ID:          "putActionSettings",
+					Tag:         "action",
+					Summary:     "Replace the settings of the actions",
+					Description: "Set the gripper positions when `open` and `closed`, the `width` in mm between the fingers when open, how much narrower than the object in mm a grasp closes to `squeeze` it, and the `approach` height in mm above the target of a pick or a place.",
+					Auth:        true,
+					Request:     ActionSettings{},
+					Responses: append([]Response{
+						{http.StatusNoContent, "settings replaced", nil},
+						{http.StatusBadRequest, "invalid setting", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/actions/{name}",
+			[]string{http.MethodOptions, http.MethodPut},
+			"/actions/{name}",
+			ActionHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "performAction",
+					Tag:         "action",
+					Summary:     "Perform an action",
+					Description: "`open` the gripper, `close` it to the `width` in mm or fully, `grasp` an object of the `width` or close fully, `pick` an object of the `width` at `x`, `y` and `z` in mm, or `place` it there. Pick and place approach the target from above at the `approach` height, descend with the hand pointing down, grip or release, and lift again; every move is checked before the first one starts, and the moves after it run in the background like a replay. The `delta`, `durationMs` or `maxVelocity` is for each move, and the response tells when the last one completes.",
+					Auth:        true,
+					Request:     Action{},
+					Responses: []Response{
+						{http.StatusAccepted, "action started, robot is moving", Completion{}},
+						{http.StatusBadRequest, "bad input parameter, out of reach, out of the limits for the role, breaking a constraint or into a keep-out zone", nil},
+						{http.StatusUnauthorized, "invalid token provided; not authorized", nil},
+						{http.StatusForbidden, "observer keys may not move the robot", nil},
+						{http.StatusNotFound, "no such action", nil},
+						{http.StatusConflict, "the robot is reserved by another user, the emergency stop is engaged or a program is being replayed", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/programs/{name}/replay",
+			[]string{http.MethodDelete, http.MethodOptions, http.MethodPut},
+			"/programs/{name}/replay",
+			ReplayHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "replayProgram",
+					Tag:         "program",
+					Summary:     "Replay a program",
+					Description: "Run the steps or the script of the program in the background with the token, checked like the requests, until one fails. `speed` 2 halves the waits and the deltas, and `loops` repeats the program, until stopped if negative.",
+					Auth:        true,
+					Request:     Replay{},
+					Responses: []Response{
+						{http.StatusAccepted, "replaying", nil},
+						{http.StatusBadRequest, "no user is using the robot", nil},
+						{http.StatusUnauthorized, "invalid token provided; not authorized", nil},
+						{http.StatusForbidden, "observer keys may not move the robot", nil},
+						{http.StatusConflict, "the robot is reserved by another user or the emergency stop is engaged", nil},
+						{http.StatusNotFound, "no such program", nil},
+					},
+				},
+				http.MethodDelete: {
+					ID:      "stopReplay",
+					Tag:     "program",
+					Summary: "Stop replaying a program",
+					Auth:    true,
+					Responses: []Response{
+						{http.StatusNoContent, "not replaying anymore", nil},
+						{http.StatusBadRequest, "no user is using the robot", nil},
+						{http.StatusUnauthorized, "invalid token provided; not authorized", nil},
+						{http.StatusForbidden, "observer keys may not move the robot", nil},
+						{http.StatusConflict, "the robot is reserved by another user or the emergency stop is engaged", nil},
+						{http.StatusNotFound, "no such program", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/user/verify/{code}",
+			[]string{http.MethodGet, http.MethodPost},
+			"/user/verify/{code}",
+			VerifyHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "confirmVerification",
+					Tag:         "user",
+					Summary:     "Open the link emailed by addUser",
+					Description: "Serve the page confirming the email with verifyUser; opening the link changes nothing, so the mail clients checking it don't use it up.",
+					Responses: []Response{
+						{http.StatusOK, "the HTML page to confirm the email", nil},
+					},
+				},
+				http.MethodPost: {
+					ID:          "verifyUser",
+					Tag:         "user",
+					Summary:     "Verify the email",
+					Description: "Add the user with the link emailed by addUser, as addUser does without the email verification.",
+					Responses: []Response{
+						{http.StatusCreated, "user created", Token{}},
+						{http.StatusAccepted, "the robot is in use; queued with the ticket URL in the `Location` header", QueueTicket{}},
+						{http.StatusNotFound, "the link is unknown or expired", nil},
+						{http.StatusConflict, "another user already exists or the robot is reserved", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/login",
+			[]string{http.MethodGet},
+			"/login",
+			LoginHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "login",
+					Tag:         "user",
+					Summary:     "Log in with the OpenID Connect provider",
+					Description: "Redirect to the provider to log in; it redirects back to `/login/callback`, which adds the user with the verified email.",
+					Responses: []Response{
+						{http.StatusFound, "redirect to the provider", nil},
+						{http.StatusNotFound, "the login is not configured", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/login/callback",
+			[]string{http.MethodGet},
+			"/login/callback",
+			LoginCallbackHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "loginCallback",
+					Tag:         "user",
+					Summary:     "Add the logged in user",
+					Description: "Exchange the code from the provider for the ID token and add the user with its verified name and email, as addUser does.",
+					Parameters: []Parameter{
+						{"code", "the authorization code from the provider"},
+						{"state", "the state given to the provider"},
+					},
+					Responses: []Response{
+						{http.StatusCreated, "user created", Token{}},
+						{http.StatusAccepted, "the robot is in use; queued with the ticket URL in the `Location` header", QueueTicket{}},
+						{http.StatusBadRequest, "the login expired or the state does not match", nil},
+						{http.StatusUnauthorized, "the provider refused the login", nil},
+						{http.StatusForbidden, "the email is not verified or not at the allowed domains", nil},
+						{http.StatusNotFound, "the login is not configured", nil},
+						{http.StatusConflict, "another user already exists or the robot is reserved", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/token",
+			[]string{http.MethodOptions, http.MethodPost},
+			"/token",
+			TokenHandler,
+			map[string]Operation{
+				http.MethodPost: {
+					ID:          "refreshToken",
+					Tag:         "user",
+					Summary:     "Refresh the token",
+					Description: "Exchange the session token in `X-API-Key` before it expires for a new one of the same session; the old token is revoked. The `Location` header ends with the new token.",
+					Auth:        true,
+					Responses: []Response{
+						{http.StatusOK, "the new token with its expiry", Token{}},
+						{http.StatusUnauthorized, "missing, invalid, expired or revoked token, or the session has ended", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/reservations",
+			[]string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost},
+			"/reservations",
+			ReservationHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getReservations",
+					Tag:       "reservation",
+					Summary:   "List the reservations",
+					Responses: []Response{{http.StatusOK, "the ongoing and upcoming reservations", []ReservationInfo{}}},
+				},
+				http.MethodPost: {
+					ID:          "addReservation",
+					Tag:         "reservation",
+					Summary:     "Reserve a time slot",
+					Description: "Reserve the robot for a time slot for the holder of the API key or the current user in `X-API-Key`, an admin key may give the `name` and `email` of someone else; only the user with the email can add the user during the slot, and the slot must start `--reservationLeadMinutes` ahead unless reserved by an admin. The URL in the `Location` header ends with the ID to change or cancel the reservation.",
+					Auth:        true,
+					Request:     ReservationInfo{},
+					Responses: append([]Response{
+						{http.StatusCreated, "reservation created", nil},
+						{http.StatusBadRequest, "invalid reservation", nil},
+						{http.StatusConflict, "the time slot overlaps with another reservation", nil},
+					}, reservationResponses...),
+				},
+			},
+		},
+		Route{
+			"/reservations.ics",
+			[]string{http.MethodGet},
+			"/reservations.ics",
+			ReservationCalendarHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getReservationCalendar",
+					Tag:         "reservation",
+					Summary:     "Subscribe to the reservations",
+					Description: "The ongoing and upcoming reservations as an iCalendar feed.",
+					Responses:   []Response{{http.StatusOK, "iCalendar feed", nil}},
+				},
+			},
+		},
+		Route{
+			"/reservations/{id}",
+			[]string{http.MethodGet, http.MethodDelete, http.MethodOptions, http.MethodPut},
+			"/reservations/{id}",
+			ReservationHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:      "getReservation",
+					Tag:     "reservation",
+					Summary: "Get a reservation",
+					Responses: []Response{
+						{http.StatusOK, "the reservation", ReservationInfo{}},
+						{http.StatusNotFound, "no such reservation", nil},
+					},
+				},
+				http.MethodPut: {
+					ID:          "updateReservation",
+					Tag:         "reservation",
+					Summary:     "Change a reservation",
+					Description: "Only who reserved the slot or an admin may change it.",
+					Auth:        true,
+					Request:     ReservationInfo{},
+					Responses: append([]Response{
+						{http.StatusNoContent, "reservation changed", nil},
+						{http.StatusBadRequest, "invalid reservation", nil},
+						{http.StatusNotFound, "no such reservation", nil},
+						{http.StatusConflict, "the time slot overlaps with another reservation", nil},
+					}, reservationResponses...),
+				},
+				http.MethodDelete: {
+					ID:          "removeReservation",
+					Tag:         "reservation",
+					Summary:     "Cancel a reservation",
+					Description: "Only who reserved the slot or an admin may cancel it.",
+					Auth:        true,
+					Responses: append([]Response{
+						{http.StatusNoContent, "reservation canceled", nil},
+						{http.StatusNotFound, "no such reservation", nil},
+					}, reservationResponses...),
+				},
+			},
+		},
+		Route{
+			"/keys",
+			[]string{http.MethodGet, http.MethodOptions, http.MethodPost},
+			"/keys",
+			KeyHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getKeys",
+					Tag:       "admin",
+					Summary:   "List the API keys",
+					Auth:      true,
+					Responses: append([]Response{{http.StatusOK, "the API keys without the tokens", []APIKeyInfo{}}}, adminResponses...),
+				},
+				http.MethodPost: {
+					ID:          "addKey",
+					Tag:         "admin",
+					Summary:     "Issue an API key",
+					Description: "Observers may only read, operators may move the robot within the soft limits, and admins may also kick users, change the limits, manage the keys and use the emergency stop. The token is only in this response.",
+					Auth:        true,
+					Request:     APIKeyRequest{},
+					Responses: append([]Response{
+						{http.StatusCreated, "the API key with its token", APIKeyInfo{}},
+						{http.StatusBadRequest, "invalid name, email or role", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/keys/{id}",
+			[]string{http.MethodDelete, http.MethodOptions},
+			"/keys/{id}",
+			KeyHandler,
+			map[string]Operation{
+				http.MethodDelete: {
+					ID:          "removeKey",
+					Tag:         "admin",
+					Summary:     "Revoke an API key",
+					Description: "The session on behalf of the key ends. The master key cannot be revoked.",
+					Auth:        true,
+					Responses: append([]Response{
+						{http.StatusNoContent, "key revoked", nil},
+						{http.StatusNotFound, "no such key", nil},
+						{http.StatusConflict, "the master key cannot be revoked", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/limits",
+			[]string{http.MethodGet, http.MethodOptions, http.MethodPut},
+			"/limits",
+			LimitsHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getLimits",
+					Tag:       "admin",
+					Summary:   "Get the joint limits",
+					Responses: []Response{{http.StatusOK, "the hard limits of the robot and the soft limits for the operators", Limits{}}},
+				},
+				http.MethodPut: {
+					ID:          "putLimits",
+					Tag:         "admin",
+					Summary:     "Change the soft limits",
+					Description: "Replace the soft limits of the joints in the body, e.g. `{\"elbow\": {\"min\": 300, \"max\": 700}}`; they must be within the hard limits.",
+					Auth:        true,
+					Request:     map[string]JointRange{},
+					Responses: append([]Response{
+						{http.StatusNoContent, "soft limits changed", nil},
+						{http.StatusBadRequest, "no such joint or out of the hard limits", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/profiles",
+			[]string{http.MethodGet, http.MethodOptions},
+			"/profiles",
+			ProfileHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getProfiles",
+					Tag:       "admin",
+					Summary:   "List the safety profiles",
+					Auth:      true,
+					Responses: append([]Response{{http.StatusOK, "the safety profiles", []SafetyProfile{}}}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/profiles/{name}",
+			[]string{http.MethodDelete, http.MethodOptions, http.MethodPut},
+			"/profiles/{name}",
+			ProfileHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "putProfile",
+					Tag:         "admin",
+					Summary:     "Create or replace a safety profile",
+					Description: "Narrow the soft limits (`limits`, the `min` of `delta` caps the speed) and refuse the `forbidden` ranges of the joints for the `users` with the emails, or else for the `roles`.",
+					Auth:        true,
+					Request:     SafetyProfile{},
+					Responses: append([]Response{
+						{http.StatusNoContent, "safety profile saved", nil},
+						{http.StatusBadRequest, "no such joint, out of the hard limits, or invalid role or email", nil},
+					}, adminResponses...),
+				},
+				http.MethodDelete: {
+					ID:        "removeProfile",
+					Tag:       "admin",
+					Summary:   "Delete a safety profile",
+					Auth:      true,
+					Responses: append([]Response{{http.StatusNoContent, "safety profile deleted", nil}, {http.StatusNotFound, "no such safety profile", nil}}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/workspace",
+			[]string{http.MethodGet, http.MethodOptions, http.MethodPut},
+			"/workspace",
+			WorkspaceHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getWorkspace",
+					Tag:       "admin",
+					Summary:   "Get the keep-out zones",
+					Responses: []Response{{http.StatusOK, "the keep-out zones and the margin the arm keeps off them", Workspace{}}},
+				},
+				http.MethodPut: {
+					ID:          "putWorkspace",
+					Tag:         "admin",
+					Summary:     "Replace the keep-out zones",
+					Description: "Replace the `plane`, `box` and `cylinder` zones around the robot in mm, with x to the front, y to the left and z up from the center of the base on the table; the moves bringing a link of the arm within the `margin` of a zone are refused.",
+					Auth:        true,
+					Request:     Workspace{},
+					Responses: append([]Response{
+						{http.StatusNoContent, "keep-out zones replaced", nil},
+						{http.StatusBadRequest, "invalid zone", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/constraints",
+			[]string{http.MethodGet, http.MethodOptions, http.MethodPut},
+			"/constraints",
+			ConstraintHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:        "getConstraints",
+					Tag:       "admin",
+					Summary:   "Get the joint constraints",
+					Responses: []Response{{http.StatusOK, "the constraints coupling the joints", []Constraint{}}},
+				},
+				http.MethodPut: {
+					ID:          "putConstraints",
+					Tag:         "admin",
+					Summary:     "Replace the joint constraints",
+					Description: "Replace the inequalities coupling the joints, e.g. `[{\"name\": \"fold\", \"weights\": {\"elbow\": 1, \"wristAngle\": 1}, \"max\": 1300}]`; every command moving the robot is refused if the resulting pose breaks one.",
+					Auth:        true,
+					Request:     []Constraint{},
+					Responses: append([]Response{
+						{http.StatusNoContent, "joint constraints replaced", nil},
+						{http.StatusBadRequest, "no such joint or invalid constraint", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/estop",
+			[]string{http.MethodDelete, http.MethodOptions, http.MethodPut},
+			"/estop",
+			EStopHandler,
+			map[string]Operation{
+				http.MethodPut: {
+					ID:          "putEStop",
+					Tag:         "admin",
+					Summary:     "Engage the emergency stop",
+					Description: "Stop the robot at once and refuse any command moving it until released.",
+					Auth:        true,
+					Responses:   append([]Response{{http.StatusAccepted, "emergency stop engaged", nil}}, adminResponses...),
+				},
+				http.MethodDelete: {
+					ID:          "removeEStop",
+					Tag:         "admin",
+					Summary:     "Release the emergency stop",
+					Description: "Put the robot to sleep, it wakes up with the next command.",
+					Auth:        true,
+					Responses:   append([]Response{{http.StatusAccepted, "emergency stop released", nil}}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/audit",
+			[]string{http.MethodGet, http.MethodOptions},
+			"/audit",
+			AuditHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getAudit",
+					Tag:         "admin",
+					Summary:     "Get the audit log",
+					Description: "List every command with the user, the requested values, the outcome and the ArmLink packets sent in hex, oldest first.",
+					Auth:        true,
+					Parameters: []Parameter{
+						{"from", "only the entries at or after the time in RFC 3339"},
+						{"to", "only the entries before the time in RFC 3339"},
+						{"user", "only the entries of the user with the email or name"},
+						{"format", "`jsonl` to export as JSON Lines"},
+					},
+					Responses: append([]Response{
+						{http.StatusOK, "the entries of the audit log", []AuditEntry{}},
+						{http.StatusBadRequest, "invalid time", nil},
+						{http.StatusServiceUnavailable, "no store to keep the audit log", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/posture/history",
+			[]string{http.MethodGet, http.MethodOptions},
+			"/posture/history",
+			HistoryHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getPostureHistory",
+					Tag:         "admin",
+					Summary:     "Get the history of the poses",
+					Description: "List the poses commanded to the robot with the time, the user, the command and the delta, oldest first; only the latest ones are kept.",
+					Auth:        true,
+					Parameters: []Parameter{
+						{"from", "only the poses at or after the time in RFC 3339"},
+						{"to", "only the poses before the time in RFC 3339"},
+						{"user", "only the poses of the user with the email or name"},
+						{"format", "`csv` to export as CSV, `jsonl` as JSON Lines"},
+					},
+					Responses: append([]Response{
+						{http.StatusOK, "the poses in the history", []PoseSample{}},
+						{http.StatusBadRequest, "invalid time", nil},
+						{http.StatusServiceUnavailable, "no store to keep the history", nil},
+					}, adminResponses...),
+				},
+			},
+		},
+		Route{
+			"/status",
+			[]string{http.MethodGet},
+			"/status",
+			StatusHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getStatus",
+					Tag:         "service",
+					Summary:     "Get the status of Leubot",
+					Description: "Report the robot state, the serial connection, the uptime in seconds, the version, and the remaining session time in seconds of the current user.",
+					Responses: []Response{
+						{http.StatusOK, "current status", Status{}},
+						{http.StatusServiceUnavailable, "the controller is not responding", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/openapi.json",
+			[]string{http.MethodGet},
+			"/openapi.json",
+			OpenAPIHandler,
+			nil,
+		},
+		Route{
+			"/docs",
+			[]string{http.MethodGet, http.MethodHead},
+			"/docs/",
+			DocsHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getDocs",
+					Tag:         "service",
+					Summary:     "Browse the API docs",
+					Description: "Serve Swagger UI bundled with Leubot for this spec, keeping the `X-API-Key` given in Authorize across reloads; `/docs` redirects here.",
+					Responses:   []Response{{http.StatusOK, "the HTML page of Swagger UI", nil}},
+				},
+			},
+		},
+		Route{
+			"/healthz",
+			[]string{http.MethodGet},
+			"/healthz",
+			HealthzHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getHealthz",
+					Tag:         "service",
+					Summary:     "Check if Leubot is alive",
+					Description: "Answer `ok` in plain text while the controller loop answers, for systemd and the monitoring.",
+					Root:        true,
+					Responses: []Response{
+						{http.StatusOK, "the controller loop answers", nil},
+						{http.StatusServiceUnavailable, "the controller loop does not answer within 2 seconds", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/readyz",
+			[]string{http.MethodGet},
+			"/readyz",
+			ReadyzHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getReadyz",
+					Tag:         "service",
+					Summary:     "Check if Leubot is ready",
+					Description: "Answer `ok` in plain text if the controller loop answers, the serial connection works, and the robot is initialized and not stopped.",
+					Root:        true,
+					Responses: []Response{
+						{http.StatusOK, "ready to take the commands", nil},
+						{http.StatusServiceUnavailable, "the controller loop does not answer, the serial connection failed, the robot is not initialized or the emergency stop is engaged", nil},
+					},
+				},
+			},
+		},
+		Route{
+			"/metrics",
+			[]string{http.MethodGet},
+			"/metrics",
+			MetricsHandler,
+			map[string]Operation{
+				http.MethodGet: {
+					ID:          "getMetrics",
+					Tag:         "service",
+					Summary:     "Export the metrics",
+					Description: "Export the metrics of the requests, the packets, the sessions, the robot states and the joint travel in the Prometheus text format.",
+					Root:        true,
+					Responses:   []Response{{http.StatusOK, "the metrics", nil}},
+				},
+			},
 		},
 	}
+}
+
+// isRoot checks if the route is served at the root of the host instead of under APIBasePath
+func (route *Route) isRoot() bool {
+	for _, op := range route.Operations {
+		if op.Root {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRouter creats a new instance of Router
+func NewRouter(apiHost string, apiPath string, apiProto string, hmc chan HandlerMessage, ver string) *mux.Router {
+	APIBasePath = fmt.Sprintf("/%s/%s", apiPath, ver)
+	APIHost = apiHost
+	APIProto = apiProto
+	APIVersion = ver
+	log.Printf("Serving at %s%s%s", APIProto, APIHost, APIBasePath)
 
 	HandlerChannel = hmc
 	r := mux.NewRouter().StrictSlash(true)
 	// default handler
 	r.Path("/").HandlerFunc(defaultHandler)
-	for _, route := range routes {
+	for _, route := range NewRoutes() {
+		if route.isRoot() {
+			// the probes for systemd and the monitoring, not logged
+			r.Methods(route.Methods...).Path(route.Pattern).Name(route.Name).HandlerFunc(route.HandlerFunc)
+			continue
+		}
 		var handler http.Handler
 		handler = route.HandlerFunc
 		handler = Logger(handler, route.Name)
-		r.Methods(route.Methods...).Path(route.Pattern).Name(route.Name).Handler(handler)
+		r.Methods(route.Methods...).Path(APIBasePath + route.Pattern).Name(route.Name).Handler(handler)
 	}
+	// the assets of Swagger UI; StrictSlash redirects /docs to /docs/
+	r.Methods(http.MethodGet, http.MethodHead).PathPrefix(APIBasePath + "/docs/").Handler(Logger(http.HandlerFunc(DocsHandler), "/docs"))
 	r.Use(mux.CORSMethodMiddleware(r))
 
 	return r
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// HealthTimeout is how long the probes wait for the controller to answer
+var HealthTimeout = 2 * time.Second
+
+// problemUnavailable is for the probes failing
+var problemUnavailable = problemType{"unavailable", "Leubot is not available"}
+
+// Status provides the JSON scheme for the status of Leubot
+type Status struct {
+	State            string `json:"state"`
+	SerialConnected  bool   `json:"serialConnected"`
+	SerialError      string `json:"serialError,omitempty"`
+	Uptime           int64  `json:"uptime"`
+	Version          string `json:"version"`
+	UserActive       bool   `json:"userActive"`
+	SessionRemaining int64  `json:"sessionRemaining"`
+}
+
+// requestStatus asks the controller for the Status, failing if the controller
+// loop does not answer within HealthTimeout
+func requestStatus() (Status, error) {
+	req := HandlerMessage{Type: TypeGetStatus, Reply: make(chan HandlerMessage, 1)}
+	timeout := time.NewTimer(HealthTimeout)
+	defer timeout.Stop()
+	select {
+	case HandlerChannel <- req:
+	case <-timeout.C:
+		return Status{}, errors.New("the controller loop is not responding")
+	}
+	var msg HandlerMessage
+	var ok bool
+	select {
+	case msg, ok = <-req.Reply:
+	case <-timeout.C:
+		return Status{}, errors.New("the controller loop is not responding")
+	}
+	if !ok {
+		return Status{}, errors.New("HandlerChannel closed")
+	}
+	status, ok := msg.Value[0].(Status)
+	if msg.Type != TypeCurrentStatus || !ok {
+		return Status{}, errors.New("unexpected value from HandlerChannel")
+	}
+	return status, nil
+}
+
+// HealthzHandler reports if the controller loop is alive
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := requestStatus(); err != nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemUnavailable.problem(err.Error())) // 503
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// ReadyzHandler reports if Leubot is ready to take the commands
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := requestStatus()
+	if err != nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemUnavailable.problem(err.Error())) // 503
+		return
+	}
+	if !status.SerialConnected {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemUnavailable.problem("The serial connection failed: "+status.SerialError)) // 503
+		return
+	}
+	if status.State == "Offline" {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemUnavailable.problem("The robot is not initialized yet")) // 503
+		return
+	}
+	if status.State == "Stopped" {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemUnavailable.problem("The emergency stop is engaged")) // 503
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// StatusHandler responds with the Status of Leubot
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	status, err := requestStatus()
+	if err != nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, problemUnavailable.problem(err.Error())) // 503
+		return
+	}
+	js, err := json.Marshal(status)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem(err.Error())) // 500
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeController answers the status requests on HandlerChannel with status
+// until the test ends
+func fakeController(t *testing.T, status Status) {
+	hmc := make(chan HandlerMessage)
+	old := HandlerChannel
+	HandlerChannel = hmc
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		HandlerChannel = old
+	})
+	go func() {
+		for {
+			select {
+			case msg := <-hmc:
+				msg.Reply <- HandlerMessage{Type: TypeCurrentStatus, Value: []interface{}{status}}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func probe(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestReadyz(t *testing.T) {
+	for _, tc := range []struct {
+		status Status
+		code   int
+	}{
+		{Status{State: "Ready", SerialConnected: true}, http.StatusOK},
+		{Status{State: "Sleeping", SerialConnected: true}, http.StatusOK},
+		{Status{State: "Offline", SerialConnected: true}, http.StatusServiceUnavailable},
+		{Status{State: "Stopped", SerialConnected: true}, http.StatusServiceUnavailable},
+		{Status{State: "Ready", SerialError: "the port is gone"}, http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.status.State, func(t *testing.T) {
+			fakeController(t, tc.status)
+			if w := probe(HealthzHandler, "/healthz"); w.Code != http.StatusOK {
+				t.Errorf("GET /healthz = %v, want 200", w.Code)
+			}
+			if w := probe(ReadyzHandler, "/readyz"); w.Code != tc.code {
+				t.Errorf("GET /readyz = %v, want %v: %s", w.Code, tc.code, w.Body)
+			}
+		})
+	}
+}
+
+func TestProbesTimeout(t *testing.T) {
+	old, oldTimeout := HandlerChannel, HealthTimeout
+	t.Cleanup(func() { HandlerChannel, HealthTimeout = old, oldTimeout })
+	HealthTimeout = 50 * time.Millisecond
+
+	// the loop does not take the request
+	HandlerChannel = make(chan HandlerMessage)
+	for path, handler := range map[string]http.HandlerFunc{"/healthz": HealthzHandler, "/readyz": ReadyzHandler, "/status": StatusHandler} {
+		if w := probe(handler, path); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %v with the loop stuck = %v, want 503", path, w.Code)
+		}
+	}
+
+	// the loop takes the request but does not answer
+	hmc := make(chan HandlerMessage, 1)
+	HandlerChannel = hmc
+	if w := probe(HealthzHandler, "/healthz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /healthz without an answer = %v, want 503", w.Code)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	want := Status{State: "Ready", SerialConnected: true, Uptime: 42, Version: "v1.2.3", UserActive: true, SessionRemaining: 600}
+	fakeController(t, want)
+	w := probe(StatusHandler, "/status")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /status = %v, want 200", w.Code)
+	}
+	var got Status
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("GET /status = %+v, want %+v", got, want)
+	}
+}
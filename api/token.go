@@ -2,12 +2,37 @@ package api
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// TokenSecret is the HMAC key signing the session tokens
+	TokenSecret = NewTokenSecret()
+	// TokenTTL is how long a session token is valid until it is refreshed
+	TokenTTL = time.Hour
 )
 
-// Token for the user token
+// Token for the user token, with the time it expires
 type Token struct {
-	Token string `json:"token"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// SessionClaims are the claims of the signed session token, the session ID
+// stays the same while the token is refreshed
+type SessionClaims struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Role    Role   `json:"role"`
+	Session string `json:"sid"`
+	jwt.RegisteredClaims
 }
 
 // GenerateToken creates a new token
@@ -16,3 +41,109 @@ func GenerateToken() string {
 	rand.Read(b)
 	return fmt.Sprintf("%x", b)
 }
+
+// NewTokenSecret creates a new key to sign the session tokens
+func NewTokenSecret() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+// HashToken returns the SHA-256 of the token in hex, to keep the token without its plaintext
+func HashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// IssueToken signs a new session token for the session of the user
+func IssueToken(user *User) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	claims := SessionClaims{
+		Name:    user.Name,
+		Email:   user.Email,
+		Role:    user.Role,
+		Session: user.Session,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        GenerateToken(),
+			Subject:   user.Email,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenTTL)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(TokenSecret)
+	if err != nil {
+		return err
+	}
+	user.Token = token
+	user.TokenID = claims.ID
+	user.Issued = now
+	user.Expires = claims.ExpiresAt.Time
+	return nil
+}
+
+// VerifyToken checks the signature and the expiry of the session token
+// without the state of the controller
+func VerifyToken(token string) (*SessionClaims, error) {
+	var claims SessionClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return TokenSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Session == "" || claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("the token is not a session token")
+	}
+	return &claims, nil
+}
+
+// TokenHandler process the requests to refresh the session token
+func TokenHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// extract token from the X-API-Key header
+	token := r.Header.Get("X-API-Key")
+	if token == "" {
+		writeProblem(w, r, http.StatusUnauthorized, problemMissingToken.problem("The token is required in X-API-Key header")) // 401
+		return
+	}
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeRefreshToken,
+		Value: []interface{}{token},
+	})
+	// check the channel status
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeTokenRefreshed: // respond with the new token
+		user, ok := msg.Value[0].(User)
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("[HandlerChannel] TokenRefreshed (name, email, expires) = %v, %v, %v", user.Name, user.Email, user.Expires)
+		js, err := json.Marshal(Token{Token: user.Token, Expires: user.Expires})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/user/"+user.Token)
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+	case TypeInvalidToken: // the token is invalid, expired, revoked or not of the current session
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
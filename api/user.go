@@ -5,13 +5,22 @@ import (
 	"log"
 	"net/http"
 	"path"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
-// User provides the struct for the user
+// User provides the struct for the user, the users added by themselves are operators;
+// the Token is the signed session token with the ID TokenID, refreshed until the Session ends
 type User struct {
-	Name  string
-	Email string
-	Token string
+	Name    string
+	Email   string
+	Token   string
+	TokenID string
+	Session string
+	Role    Role
+	Issued  time.Time
+	Expires time.Time
 }
 
 // UserInfo provides the JSON scheme for User
@@ -20,6 +29,19 @@ type UserInfo struct {
 	Email string `json:"email"`
 }
 
+// UserRequest provides the JSON scheme to add a user, with the URL
+// to be notified when the user waiting in the queue gets the robot
+type UserRequest struct {
+	UserInfo
+	Callback string `json:"callback,omitempty"`
+}
+
+// Verification provides the JSON scheme for the link sent to verify the email
+type Verification struct {
+	Email   string    `json:"email"`
+	Expires time.Time `json:"expires"`
+}
+
 // ToUserInfo parses User to UserInfo
 func (u *User) ToUserInfo() UserInfo {
 	return UserInfo{
@@ -30,11 +52,16 @@ func (u *User) ToUserInfo() UserInfo {
 
 // NewUser instantiate a user
 func NewUser(userInfo *UserInfo) *User {
-	return &User{
-		Name:  userInfo.Name,
-		Email: userInfo.Email,
-		Token: GenerateToken(),
+	user := &User{
+		Name:    userInfo.Name,
+		Email:   userInfo.Email,
+		Session: GenerateToken(),
+		Role:    RoleOperator,
+	}
+	if err := IssueToken(user); err != nil {
+		log.Printf("[Token] Failed to sign the token for %v: %v", user.Name, err)
 	}
+	return user
 }
 
 // UserHandler process the requests on the user
@@ -58,66 +85,106 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		getUser(w, r)
 	case http.MethodDelete:
-		removeUser(w, r)
+		if _, ok := mux.Vars(r)["token"]; ok {
+			removeUser(w, r)
+		} else {
+			kickUser(w, r)
+		}
 	case http.MethodPost:
 		addUser(w, r)
 	}
 }
 
 func addUser(w http.ResponseWriter, r *http.Request) {
+	// the users must log in to verify their emails
+	if OIDC != nil && OIDC.Required {
+		writeProblem(w, r, http.StatusForbidden, problemLoginRequired.problem("Log in at login instead to verify the email")) // 403
+		return
+	}
 	// parse the request body
 	decoder := json.NewDecoder(r.Body)
-	var userInfo UserInfo
-	err := decoder.Decode(&userInfo)
+	var userReq UserRequest
+	err := decoder.Decode(&userReq)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest) // 400
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
 		return
 	}
+	userInfo := userReq.UserInfo
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  TypeAddUser,
-		Value: []interface{}{userInfo},
-	}
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+		Value: []interface{}{userInfo, userReq.Callback},
+	})
 	// check the channel status
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
-	// respond with the result
+	writeUserAdded(w, r, msg, userInfo)
+}
+
+// writeUserAdded responds with the result of TypeAddUser
+func writeUserAdded(w http.ResponseWriter, r *http.Request, msg HandlerMessage, userInfo UserInfo) {
 	switch msg.Type {
-	case TypeUserAdded: // respond with the added UserInfo
+	case TypeUserAdded: // respond with the token
 		user, ok := msg.Value[0].(User)
 		if !ok {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("[HandlerChannel] UserAdded (name, email) = %v, %v", user.Name, user.Email)
+		js, err := json.Marshal(Token{Token: user.Token, Expires: user.Expires})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		log.Printf("[HandlerChannel] UserAdded (name, email, token) = %v, %v, %v", user.Name, user.Email, user.Token)
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.Header().Set("Location", APIProto+APIHost+APIBasePath+"/user/"+user.Token)
 		w.WriteHeader(http.StatusCreated)
+		w.Write(js)
+	case TypeUserQueued: // there's a user in the system, wait in the queue
+		qt, ok := msg.Value[0].(QueueTicket)
+		if !ok {
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
+		}
+		log.Printf("[HandlerChannel] UserQueued (name, email, position) = %v, %v, %v", userInfo.Name, userInfo.Email, qt.Position)
+		writeTicket(w, r, http.StatusAccepted, qt)
+	case TypeVerificationSent: // the session starts when the link in the email is clicked
+		log.Printf("[HandlerChannel] VerificationSent (name, email) = %v, %v", userInfo.Name, userInfo.Email)
+		js, err := json.Marshal(msg.Value[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(js)
+	case TypeVerificationNotFound: // the link is unknown or expired
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
 	case TypeUserExisted: // there's a user in the system already
 		log.Printf("[HandlerChannel] UserExisted, not replacing with (name, email) = %v, %v", userInfo.Name, userInfo.Email)
-		w.WriteHeader(http.StatusConflict)
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	case TypeSlotReserved: // the robot is reserved by another user now
+		log.Printf("[HandlerChannel] SlotReserved, not adding (name, email) = %v, %v", userInfo.Name, userInfo.Email)
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
 	case TypeInvalidUserInfo: // invalid email
 		log.Printf("[HandlerChannel] Invalid UserInfo (name, email) = %v, %v", userInfo.Name, userInfo.Email)
-		w.WriteHeader(http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
 	default: // something went wrong
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 		return
 	}
 }
 
 func getUser(w http.ResponseWriter, r *http.Request) {
 	// bypass the request to HandlerChannel
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type: TypeGetUser,
-	}
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	// check the channel status
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 	// respond with the result
@@ -125,7 +192,8 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 	case TypeCurrentUser: // respond with the current UserInfo
 		userInfo, ok := msg.Value[0].(UserInfo)
 		if !ok {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("Unexpected value from HandlerChannel")) // 500
+			return
 		}
 		log.Printf("[HandlerChannel] CurrentUser (name, email) = %v, %v", userInfo.Name, userInfo.Email)
 		js, err := json.Marshal(userInfo)
@@ -137,7 +205,7 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(js)
 	default: // something went wrong
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 		return
 	}
 }
@@ -145,26 +213,94 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 func removeUser(w http.ResponseWriter, r *http.Request) {
 	// get the token from the path
 	token := path.Base(r.URL.Path)
-	HandlerChannel <- HandlerMessage{
+	msg, ok := Request(HandlerChannel, HandlerMessage{
 		Type:  TypeDeleteUser,
 		Value: []interface{}{token},
-	}
-	// receive a message from the other end of HandlerChannel
-	msg, ok := <-HandlerChannel
+	})
 	// check the channel status
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
 		return
 	}
 	// respond with the result
 	switch msg.Type {
 	case TypeUserDeleted: // the user removed
-		log.Printf("[HandlerChannel] UserDeleted with token = %v", token)
+		log.Println("[HandlerChannel] UserDeleted")
 		w.WriteHeader(http.StatusNoContent)
 	case TypeUserNotFound: // no user with the token
-		log.Printf("[HandlerChannel] UserNotfound with token = %v", token)
-		w.WriteHeader(http.StatusNotFound)
+		log.Println("[HandlerChannel] UserNotfound")
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
+	case TypeInvalidToken: // the token is not for the current user
+		log.Println("[HandlerChannel] InvalidToken")
+		writeProblem(w, r, http.StatusUnauthorized, problemFromMessage(msg)) // 401
+	case TypeForbidden: // observers may not remove the user
+		writeProblem(w, r, http.StatusForbidden, problemFromMessage(msg)) // 403
+	case TypeSlotReserved: // the robot is reserved by another user now
+		writeProblem(w, r, http.StatusConflict, problemFromMessage(msg)) // 409
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
+
+// verifyPage asks to confirm the link sent to the email, which adds the user only with a POST
+// so that the scanners and the previews of the mail clients opening the link don't use it up
+const verifyPage = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8">
+    <meta name="robots" content="noindex">
+    <title>Leubot</title>
+  </head>
+
+  <body>
+    <form method="post">
+      <p>Confirm your email to start using Leubot.</p>
+      <button type="submit">Start using Leubot</button>
+    </form>
+  </body>
+</html>
+`
+
+// VerifyHandler serves the page to confirm the link sent to the email on GET,
+// and adds the user who confirmed it on POST
+func VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(verifyPage))
+		return
+	}
+
+	code := mux.Vars(r)["code"]
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type:  TypeVerifyUser,
+		Value: []interface{}{code},
+	})
+	// check the channel status
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	writeUserAdded(w, r, msg, UserInfo{})
+}
+
+// kickUser removes whoever is using the robot, only for the admins
+func kickUser(w http.ResponseWriter, r *http.Request) {
+	reason := r.URL.Query().Get("reason")
+	msg, ok := adminRequest(w, r, TypeKickUser, reason)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeUserDeleted: // the user removed
+		log.Printf("[HandlerChannel] UserDeleted by an admin: %v", reason)
+		w.WriteHeader(http.StatusNoContent)
+	case TypeUserNotFound: // nobody is using the robot
+		writeProblem(w, r, http.StatusNotFound, problemFromMessage(msg)) // 404
 	default: // something went wrong
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
 	}
 }
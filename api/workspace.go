@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/Interactions-HSG/leubot/kinematics"
+)
+
+// The shapes of the keep-out zones
+const (
+	// ShapePlane keeps out everything below Z, e.g. the table
+	ShapePlane = "plane"
+	// ShapeBox keeps out the axis-aligned box between Min and Max
+	ShapeBox = "box"
+	// ShapeCylinder keeps out the upright cylinder standing on Center
+	ShapeCylinder = "cylinder"
+)
+
+// Zone provides the JSON scheme for a keep-out zone in mm, see kinematics for the axes
+type Zone struct {
+	Name   string            `json:"name"`
+	Shape  string            `json:"shape"`
+	Z      float64           `json:"z,omitempty"`
+	Min    *kinematics.Point `json:"min,omitempty"`
+	Max    *kinematics.Point `json:"max,omitempty"`
+	Center *kinematics.Point `json:"center,omitempty"`
+	Radius float64           `json:"radius,omitempty"`
+	Height float64           `json:"height,omitempty"`
+}
+
+// Workspace provides the JSON scheme for the fixtures around the robot, which the links
+// of the arm may not come closer to than the margin in mm
+type Workspace struct {
+	Margin float64 `json:"margin"`
+	Zones  []Zone  `json:"zones"`
+}
+
+// DefaultWorkspace has no keep-out zones, the admins add the fixtures of the setup
+var DefaultWorkspace = Workspace{
+	Margin: 10,
+	Zones:  []Zone{},
+}
+
+// Contains checks if the point is within the zone inflated by the margin
+func (z *Zone) Contains(p kinematics.Point, margin float64) bool {
+	switch z.Shape {
+	case ShapePlane:
+		return p.Z < z.Z+margin
+	case ShapeBox:
+		return z.Min.X-margin < p.X && p.X < z.Max.X+margin &&
+			z.Min.Y-margin < p.Y && p.Y < z.Max.Y+margin &&
+			z.Min.Z-margin < p.Z && p.Z < z.Max.Z+margin
+	case ShapeCylinder:
+		return math.Hypot(p.X-z.Center.X, p.Y-z.Center.Y) < z.Radius+margin &&
+			z.Center.Z-margin < p.Z && p.Z < z.Center.Z+z.Height+margin
+	}
+	return false
+}
+
+// WorkspaceHandler process the requests on the workspace, changing it is only for the admins
+func WorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	// allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		getWorkspace(w, r)
+	case http.MethodPut:
+		putWorkspace(w, r)
+	}
+}
+
+func getWorkspace(w http.ResponseWriter, r *http.Request) {
+	// bypass the request to HandlerChannel
+	msg, ok := Request(HandlerChannel, HandlerMessage{
+		Type: TypeGetWorkspace,
+	})
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, problemInternal.problem("HandlerChannel closed")) // 500
+		return
+	}
+	if msg.Type != TypeCurrentWorkspace {
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+		return
+	}
+	js, err := json.Marshal(msg.Value[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// putWorkspace replaces the workspace
+func putWorkspace(w http.ResponseWriter, r *http.Request) {
+	// parse the request body
+	decoder := json.NewDecoder(r.Body)
+	var ws Workspace
+	err := decoder.Decode(&ws)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problemMalformedBody.problem(err.Error())) // 400
+		return
+	}
+	msg, ok := adminRequest(w, r, TypePutWorkspace, ws)
+	if !ok {
+		return
+	}
+	// respond with the result
+	switch msg.Type {
+	case TypeWorkspaceUpdated:
+		log.Printf("[HandlerChannel] WorkspaceUpdated with %v zones", len(ws.Zones))
+		w.WriteHeader(http.StatusNoContent)
+	case TypeInvalidCommand:
+		writeProblem(w, r, http.StatusBadRequest, problemFromMessage(msg)) // 400
+	default: // something went wrong
+		writeProblem(w, r, http.StatusInternalServerError, problemFromMessage(msg)) // 500
+	}
+}
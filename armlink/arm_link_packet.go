@@ -28,6 +28,11 @@ func (alp *ArmLinkPacket) SetExtended(e byte) {
 	alp.extendedInstructionByte = e
 }
 
+// SetButton set the buttonByte with the buttons pressed on the controller
+func (alp *ArmLinkPacket) SetButton(b byte) {
+	alp.buttonByte = b
+}
+
 func (alp *ArmLinkPacket) String() string {
 	return fmt.Sprintf("Base: %v, Shoulder: %v, Elbow: %v, WristAngle: %v, WristRotation: %v, Gripper: %v, Delta: %v, Button: %v, Extended: %v", alp.baseRotation, alp.shoulderRotation, alp.elbowRotation, alp.wristAngle, alp.wristRotation, alp.gripper, alp.deltaByte, alp.buttonByte, alp.extendedInstructionByte)
 }
@@ -2,14 +2,20 @@ package armlink
 
 import (
 	"encoding/hex"
+	"fmt"
 	"io"
 	"log"
+	"os"
+	"sync"
 
 	"github.com/jacobsa/go-serial/serial"
 )
 
 type ArmLinkSerial struct {
 	port io.ReadWriteCloser
+	name string
+	mu   sync.Mutex
+	err  error
 }
 
 func NewArmLinkSerial() *ArmLinkSerial {
@@ -30,6 +36,7 @@ func NewArmLinkSerial() *ArmLinkSerial {
 		log.Fatalf("serial.Open: %v", err)
 	}
 	als.port = port
+	als.name = options.PortName
 
 	return als
 }
@@ -38,10 +45,30 @@ func (als *ArmLinkSerial) Close() {
 	als.port.Close()
 }
 
-func (als *ArmLinkSerial) Send(b []byte) {
+// Send writes the packet to the serial port, keeping the error for Err
+func (als *ArmLinkSerial) Send(b []byte) error {
 	log.Println(hex.Dump(b))
 	_, err := als.port.Write(b)
 	if err != nil {
-		log.Fatalf("port.Write: %v", err)
+		log.Printf("port.Write: %v", err)
 	}
+	als.mu.Lock()
+	als.err = err
+	als.mu.Unlock()
+	return err
+}
+
+// Err returns the error of the last Send, or if the device of the port is gone,
+// e.g. unplugged since; nil if the port looks connected
+func (als *ArmLinkSerial) Err() error {
+	als.mu.Lock()
+	err := als.err
+	als.mu.Unlock()
+	if err != nil || als.name == "" {
+		return err
+	}
+	if _, err := os.Stat(als.name); err != nil {
+		return fmt.Errorf("the port is gone: %v", err)
+	}
+	return nil
 }
@@ -0,0 +1,28 @@
+package armlink
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArmLinkSerialErr(t *testing.T) {
+	if err := NewSimulatedArmLinkSerial().Err(); err != nil {
+		t.Errorf("the simulator is not connected: %v", err)
+	}
+
+	name := filepath.Join(t.TempDir(), "ttyUSB0")
+	if err := os.WriteFile(name, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	als := &ArmLinkSerial{port: simulatedPort{}, name: name}
+	if err := als.Err(); err != nil {
+		t.Errorf("the port is not connected: %v", err)
+	}
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := als.Err(); err == nil {
+		t.Error("the port is connected after it is gone")
+	}
+}
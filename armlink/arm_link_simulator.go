@@ -0,0 +1,23 @@
+package armlink
+
+import "io"
+
+// simulatedPort takes the packets in place of the serial port
+type simulatedPort struct{}
+
+func (simulatedPort) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (simulatedPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (simulatedPort) Close() error {
+	return nil
+}
+
+// NewSimulatedArmLinkSerial creates an ArmLinkSerial without the robot, accepting every packet
+func NewSimulatedArmLinkSerial() *ArmLinkSerial {
+	return &ArmLinkSerial{port: simulatedPort{}}
+}
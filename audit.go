@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// auditBucket is the bucket of the audit log in the Store
+const auditBucket = "audit"
+
+// auditedTypes are the commands recorded in the audit log, the rest only reads
+var auditedTypes = map[api.HandlerMessageType]bool{
+	api.TypeAddUser:             true,
+	api.TypeVerifyUser:          true,
+	api.TypeDeleteUser:          true,
+	api.TypeUserTimeout:         true,
+	api.TypeKickUser:            true,
+	api.TypeRefreshToken:        true,
+	api.TypePutBase:             true,
+	api.TypePutShoulder:         true,
+	api.TypePutElbow:            true,
+	api.TypePutWristAngle:       true,
+	api.TypePutWristRotation:    true,
+	api.TypePutGripper:          true,
+	api.TypePutPosture:          true,
+	api.TypePatchPosture:        true,
+	api.TypePutReset:            true,
+	api.TypePutSleep:            true,
+	api.TypeAddReservation:      true,
+	api.TypeUpdateReservation:   true,
+	api.TypeDeleteReservation:   true,
+	api.TypeReservationBoundary: true,
+	api.TypeDeleteTicket:        true,
+	api.TypeAddKey:              true,
+	api.TypeDeleteKey:           true,
+	api.TypePutLimits:           true,
+	api.TypePutProfile:          true,
+	api.TypeDeleteProfile:       true,
+	api.TypePutWorkspace:        true,
+	api.TypePutConstraints:      true,
+	api.TypePutPose:             true,
+	api.TypeDeletePose:          true,
+	api.TypeApplyPose:           true,
+	api.TypeStartRecording:      true,
+	api.TypeStopRecording:       true,
+	api.TypeAddProgram:          true,
+	api.TypePutProgram:          true,
+	api.TypeDeleteProgram:       true,
+	api.TypeReplayProgram:       true,
+	api.TypeStopReplay:          true,
+	api.TypePutActionSettings:   true,
+	api.TypePerformAction:       true,
+	api.TypePutEStop:            true,
+	api.TypeDeleteEStop:         true,
+}
+
+// messageToken returns the token sent with the message, empty if there's none
+func messageToken(msg api.HandlerMessage) string {
+	if len(msg.Value) == 0 {
+		return ""
+	}
+	switch v := msg.Value[0].(type) {
+	case api.RobotCommand:
+		return v.Token
+	case api.PostureCommand:
+		return v.Token
+	case api.PostureJog:
+		return v.Token
+	case api.PoseCommand:
+		return v.Token
+	case string:
+		switch msg.Type {
+		case api.TypeDeleteUser, api.TypePutReset, api.TypePutSleep, api.TypeKickUser,
+			api.TypeAddKey, api.TypeDeleteKey, api.TypePutLimits, api.TypePutEStop, api.TypeDeleteEStop,
+			api.TypePutProfile, api.TypeDeleteProfile, api.TypePutWorkspace, api.TypePutConstraints,
+			api.TypePutPose, api.TypeDeletePose, api.TypeStartRecording, api.TypeStopRecording,
+			api.TypeAddProgram, api.TypePutProgram, api.TypeDeleteProgram, api.TypeReplayProgram, api.TypeStopReplay,
+			api.TypePutActionSettings, api.TypePerformAction, api.TypeAddReservation, api.TypeUpdateReservation, api.TypeDeleteReservation:
+			return v
+		}
+	}
+	return ""
+}
+
+// auditValues returns the requested values of the message without the secrets;
+// the strings sent with the commands are tokens, reservation IDs or tickets
+func auditValues(msg api.HandlerMessage) []interface{} {
+	values := []interface{}{}
+	for _, v := range msg.Value {
+		switch v := v.(type) {
+		case string:
+			if (msg.Type == api.TypeKickUser || msg.Type == api.TypeDeleteProfile || msg.Type == api.TypeDeletePose ||
+				msg.Type == api.TypeDeleteProgram || msg.Type == api.TypeStopReplay) && v != messageToken(msg) {
+				// the reason or the name
+				values = append(values, v)
+			}
+		case api.RobotCommand:
+			if v.Offset != nil {
+				// the offset of the jog
+				values = append(values, fmt.Sprintf("%+d", *v.Offset))
+				break
+			}
+			values = append(values, v.Value)
+		case api.PostureJog:
+			v.Token = ""
+			values = append(values, v)
+		case api.PostureCommand:
+			v.Token = ""
+			values = append(values, v)
+		case api.PoseCommand:
+			v.Token = ""
+			values = append(values, v)
+		default:
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// audit records the command with its outcome and the packets sent for it
+// by the user who was using the robot
+func (controller *Controller) audit(user api.User, msg api.HandlerMessage, reply api.HandlerMessage) {
+	packets := controller.SentPackets
+	controller.SentPackets = nil
+	if !auditedTypes[msg.Type] {
+		return
+	}
+
+	entry := api.AuditEntry{
+		Time:    time.Now().UTC(),
+		Name:    user.Name,
+		Email:   user.Email,
+		Type:    msg.Type.String(),
+		Values:  auditValues(msg),
+		Outcome: reply.Type.String(),
+	}
+	// the one who started the session with the command
+	if entry.Name == "" && entry.Email == "" {
+		entry.Name, entry.Email = controller.CurrentUser.Name, controller.CurrentUser.Email
+	}
+	if key := controller.findKey(messageToken(msg)); key != nil {
+		entry.Key = key.ID
+	}
+	if len(reply.Value) > 0 {
+		if p, ok := reply.Value[0].(api.Problem); ok {
+			entry.Detail = p.Detail
+		}
+	}
+	for _, b := range packets {
+		entry.Packets = append(entry.Packets, hex.EncodeToString(b))
+	}
+
+	js, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[Audit] %v", err)
+		return
+	}
+	if err := controller.Store.Append(auditBucket, entry.Time, js); err != nil {
+		log.Printf("[Audit] %v", err)
+	}
+}
+
+// queryAudit returns the entries of the audit log matching the query
+func (controller *Controller) queryAudit(q *api.AuditQuery) []api.AuditEntry {
+	entries := []api.AuditEntry{}
+	err := controller.Store.Scan(auditBucket, q.From, func(js []byte) bool {
+		var entry api.AuditEntry
+		if err := json.Unmarshal(js, &entry); err != nil {
+			log.Printf("[Audit] %v", err)
+			return true
+		}
+		if !q.To.IsZero() && !entry.Time.Before(q.To) {
+			return false
+		}
+		if q.Matches(&entry) {
+			entries = append(entries, entry)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("[Audit] %v", err)
+	}
+	return entries
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// getAudit returns the audit log with the query
+func getAudit(t *testing.T, h http.Handler, query string) []api.AuditEntry {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/audit"+query, testMasterKey, nil)
+	var entries []api.AuditEntry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /audit%v: %v %v", query, rec.Code, err)
+	}
+	return entries
+}
+
+func TestAudit(t *testing.T) {
+	_, h := newTestController(t, openTestStore(t))
+	token := addTestUser(t, h, "alice")
+	moveBase(h, token, 450)
+	moveBase(h, token, 2000)
+	serve(h, http.MethodDelete, "/user/"+token, "", nil)
+	addTestUser(t, h, "bob")
+
+	entries := getAudit(t, h, "")
+	var types []string
+	for _, entry := range entries {
+		types = append(types, entry.Type)
+	}
+	if want := "TypeAddUser TypePutBase TypePutBase TypeDeleteUser TypeAddUser"; strings.Join(types, " ") != want {
+		t.Fatalf("GET /audit: %v, want %v", types, want)
+	}
+	if put := entries[1]; put.Name != "alice" || len(put.Values) != 1 || put.Values[0] != 450.0 || put.Outcome != "TypeActionPerformed" || len(put.Packets) == 0 {
+		t.Errorf("the accepted move: %+v", put)
+	}
+	if put := entries[2]; put.Outcome != "TypeInvalidCommand" || put.Detail == "" || len(put.Packets) != 0 {
+		t.Errorf("the refused move: %+v", put)
+	}
+	if js, _ := json.Marshal(entries); strings.Contains(string(js), token) {
+		t.Error("the audit log contains the token")
+	}
+
+	if bob := getAudit(t, h, "?user=bob@example.com"); len(bob) != 1 || bob[0].Name != "bob" {
+		t.Errorf("GET /audit?user=bob@example.com: %+v", bob)
+	}
+	if later := getAudit(t, h, "?from="+entries[3].Time.Format(time.RFC3339Nano)); len(later) != 2 {
+		t.Errorf("GET /audit?from: %+v", later)
+	}
+	rec := serve(h, http.MethodGet, "/audit?format=jsonl", testMasterKey, nil)
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); rec.Code != http.StatusOK || len(lines) != len(entries) || rec.Header().Get("Content-Type") != "application/x-ndjson; charset=UTF-8" {
+		t.Errorf("GET /audit?format=jsonl: %v %v", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodGet, "/audit?from=yesterday", testMasterKey, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /audit?from=yesterday: %v, want 400", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/audit", token, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /audit as the user: %v, want 401", rec.Code)
+	}
+}
+
+func TestAuditWithoutStore(t *testing.T) {
+	_, h := newTestController(t, nil)
+	addTestUser(t, h, "alice")
+	rec := serve(h, http.MethodGet, "/audit", testMasterKey, nil)
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); rec.Code != http.StatusServiceUnavailable || err != nil || !strings.HasSuffix(p.Type, "store-unavailable") {
+		t.Errorf("GET /audit without the store: %v %+v", rec.Code, p)
+	}
+}
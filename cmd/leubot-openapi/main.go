@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Environmental variables
+var (
+	app        = kingpin.New("leubot-openapi", "Generate the OpenAPI spec of Leubot from its route table.")
+	apiHost    = app.Flag("apiHost", "The hostname for the API.").Default("api.interactions.ics.unisg.ch").String()
+	apiPath    = app.Flag("apiPath", "The name for the path.").Default("leubot").String()
+	apiProto   = app.Flag("apiProto", "The protocol for the API.").Default("https://").String()
+	apiVersion = app.Flag("apiVersion", "The API version for the spec.").Default("v1.3.4").String()
+	check      = app.Flag("check", "Fail if the spec in the output differs from the generated one.").Default("false").Bool()
+	out        = app.Flag("out", "The file to write the spec to.").Default("openapi.json").String()
+)
+
+func main() {
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	server := fmt.Sprintf("%s%s/%s/%s", *apiProto, *apiHost, *apiPath, *apiVersion)
+	js, err := api.MarshalOpenAPI(api.NewOpenAPI(api.NewRoutes(), server, *apiVersion))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *check {
+		committed, err := os.ReadFile(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(committed, js) {
+			log.Fatalf("%v diverges from the route table, run `go generate` to update it", *out)
+		}
+		return
+	}
+
+	if err := os.WriteFile(*out, js, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -8,11 +8,44 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	app        = kingpin.New("leubot-tool", "Serve the Slack slash commands to maintain Leubot.")
+	adminKey   = app.Flag("adminKey", "The admin key of Leubot for removing the current user.").Default("").String()
+	leubotURL  = app.Flag("leubotURL", "The URL to the API of Leubot.").Default("http://127.0.0.1:6789/leubot/v1.3.4").String()
+	serverAddr = app.Flag("addr", "The address to serve the slash commands.").Default("0.0.0.0:30002").String()
 )
 
+// releaseUser removes the current user of Leubot with the reason
+func releaseUser(reason string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodDelete, *leubotURL+"/user?reason="+url.QueryEscape(reason), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", *adminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return nil, fmt.Errorf("leubot responded %v: %s", resp.Status, body)
+	}
+	return body, nil
+}
+
 func main() {
-    log.Println("Initializing")
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+	log.Println("Initializing")
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// return ok
@@ -43,10 +76,22 @@ func main() {
 		*/
 
 		var action string
+		var perform func() ([]byte, error)
 
 		switch r.URL.Path {
 		case "/leubot/tool/restart":
 			action = "Restarting leubot.service"
+			perform = exec.Command("sudo", "/bin/systemctl", "restart", "leubot.service").Output
+		case "/leubot/tool/release":
+			// the text of the slash command is the reason told to the user
+			reason := params.Get("text")
+			action = "Releasing the current user of Leubot"
+			perform = func() ([]byte, error) {
+				return releaseUser(fmt.Sprintf("%v (by %v on Slack)", reason, params.Get("user_name")))
+			}
+		default:
+			log.Printf("No such command: %v", r.URL.Path)
+			return
 		}
 
 		// notify before the action
@@ -61,17 +106,24 @@ func main() {
 		resp, err := http.Post(params["response_url"][0], "application/json", bytes.NewReader(jsonBytes))
 		if err != nil {
 			log.Print(err)
-            return
+			return
 		}
 		log.Println(resp)
 
 		// do stuff
 		go func() {
-			out, err := exec.Command("sudo", "/bin/systemctl", "restart", "leubot.service").Output()
+			out, err := perform()
 
 			if err != nil {
 				log.Print(err)
-                return
+				// notify the failure only to the requester
+				reply = map[string]string{
+					"response_type": "ephemeral",
+					"text":          fmt.Sprintf("%s has failed: %v", action, err),
+				}
+				jsonBytes, _ = json.Marshal(reply)
+				http.Post(params["response_url"][0], "application/json", bytes.NewReader(jsonBytes))
+				return
 			}
 			log.Printf("%s\n", out)
 			// notify after the action as a public message
@@ -82,17 +134,17 @@ func main() {
 			jsonBytes, _ = json.Marshal(reply)
 			if err != nil {
 				log.Print(err)
-                return
+				return
 			}
 			resp, err = http.Post(params["response_url"][0], "application/json", bytes.NewReader(jsonBytes))
 			if err != nil {
 				log.Print(err)
-                return
+				return
 			}
 			log.Println(resp)
 		}()
 	})
 
-    log.Println("Starting")
-	http.ListenAndServe("0.0.0.0:30002", nil)
+	log.Println("Starting")
+	http.ListenAndServe(*serverAddr, nil)
 }
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/armlink"
+)
+
+// jogOffset moves the joint by the offset in ticks
+type jogOffset struct {
+	joint  string
+	offset int
+}
+
+// The commands besides jogging the joints
+const (
+	jogHome  = "home"
+	jogOpen  = "open"
+	jogClose = "close"
+	jogStop  = "stop"
+)
+
+// jogCommand jogs the joints with the buttons held on the joystick, or does one of the other commands
+type jogCommand struct {
+	offsets []jogOffset
+	buttons byte
+	command string
+}
+
+// jogKeys maps the keys to the joints and the directions to jog them
+var jogKeys = map[byte]jogOffset{
+	'a': {"base", 1},
+	'd': {"base", -1},
+	'w': {"shoulder", -1},
+	's': {"shoulder", 1},
+	'r': {"elbow", 1},
+	'f': {"elbow", -1},
+	't': {"wristAngle", 1},
+	'g': {"wristAngle", -1},
+	'q': {"wristRotation", -1},
+	'e': {"wristRotation", 1},
+	'[': {"gripper", -1},
+	']': {"gripper", 1},
+}
+
+// jogKeyCommands maps the keys to the other commands
+var jogKeyCommands = map[byte]string{
+	'h': jogHome,
+	'o': jogOpen,
+	'c': jogClose,
+	' ': jogStop,
+}
+
+// jogHelp describes the keys
+const jogHelp = `a/d: base  w/s: shoulder  r/f: elbow  t/g: wrist angle  q/e: wrist rotation  [/]: gripper
+o/c: open/close the gripper  h: back to the start  space: emergency stop  x: quit
+`
+
+// jogAxes maps the axes of the joystick to the joints and the directions
+var jogAxes = map[uint8]jogOffset{
+	0: {"base", -1},         // left stick x
+	1: {"shoulder", 1},      // left stick y
+	3: {"wristRotation", 1}, // right stick x
+	4: {"elbow", -1},        // right stick y
+}
+
+// jogButtons maps the buttons of the joystick held to the joints and the directions
+var jogButtons = map[uint8]jogOffset{
+	0: {"wristAngle", -1}, // A
+	3: {"wristAngle", 1},  // Y
+	4: {"gripper", -1},    // LB
+	5: {"gripper", 1},     // RB
+}
+
+// jogButtonCommands maps the buttons of the joystick pressed to the other commands
+var jogButtonCommands = map[uint8]string{
+	2: jogOpen,  // X
+	1: jogClose, // B
+	7: jogHome,  // Start
+	6: jogStop,  // Back
+}
+
+const (
+	// jsButton and jsAxis are the types of the Linux joystick events, jsInit flags the initial state
+	jsButton = 0x01
+	jsAxis   = 0x02
+	jsInit   = 0x80
+	// jsDeadzone ignores the axes resting around the center
+	jsDeadzone = 4000
+	// jogPeriod is how often the held joystick jogs the joints
+	jogPeriod = 100 * time.Millisecond
+)
+
+// jogArm moves the arm for the jogging, straight over ArmLink or through the API of Leubot
+type jogArm interface {
+	// jog moves the joints by the offsets, stopping at the limits, with the buttons held
+	jog(offsets []jogOffset, buttons byte) error
+	// do does one of the other commands
+	do(command string) error
+	// status tells where the joints are going
+	status() string
+}
+
+// linkArm jogs the arm over ArmLink, keeping the pose sent to it
+type linkArm struct {
+	als   *armlink.ArmLinkSerial
+	start api.RobotPose
+	pose  api.RobotPose
+	delta uint8
+}
+
+// jog moves the joints by the offsets, stopping at the limits, and passes the buttons on in the packet
+func (la *linkArm) jog(offsets []jogOffset, buttons byte) error {
+	for _, jo := range offsets {
+		jr := api.JointRanges[jo.joint]
+		v := int(la.pose.Get(jo.joint)) + jo.offset
+		if v < int(jr.Min) {
+			v = int(jr.Min)
+		}
+		if v > int(jr.Max) {
+			v = int(jr.Max)
+		}
+		la.pose.Set(jo.joint, uint16(v))
+	}
+	return la.send(buttons)
+}
+
+// do goes back to the start pose, opens or closes the gripper as set for the stock one,
+// or stops the arm at once
+func (la *linkArm) do(command string) error {
+	switch command {
+	case jogHome:
+		la.pose = la.start
+	case jogOpen:
+		la.pose.Gripper = api.DefaultActionSettings.Open
+	case jogClose:
+		la.pose.Gripper = api.DefaultActionSettings.Closed
+	case jogStop:
+		alp := &armlink.ArmLinkPacket{}
+		alp.SetExtended(armlink.ExtendedStop)
+		return la.als.Send(alp.Bytes())
+	}
+	return la.send(0)
+}
+
+// send sends the pose to the arm
+func (la *linkArm) send(buttons byte) error {
+	alp := la.pose.BuildArmLinkPacket(la.delta)
+	alp.SetButton(buttons)
+	return la.als.Send(alp.Bytes())
+}
+
+func (la *linkArm) status() string {
+	return la.pose.String()
+}
+
+// readKeys sends the commands for the keys pressed on the raw terminal, and closes quit on x
+func readKeys(step int, commands chan<- jogCommand, quit chan<- bool) {
+	b := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(b); err != nil {
+			close(quit)
+			return
+		}
+		if b[0] == 'x' {
+			close(quit)
+			return
+		}
+		if jo, ok := jogKeys[b[0]]; ok {
+			commands <- jogCommand{offsets: []jogOffset{{jo.joint, jo.offset * step}}}
+		}
+		if c, ok := jogKeyCommands[b[0]]; ok {
+			commands <- jogCommand{command: c}
+		}
+	}
+}
+
+// readJoystick sends the commands for the buttons pressed on the Linux joystick as they are pressed,
+// and the jogs for the axes and the buttons held every jogPeriod, up to the step for the axes fully tilted
+func readJoystick(device string, step int, commands chan<- jogCommand, quit chan<- bool) {
+	js, err := os.Open(device)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "joystick: %v\n", err)
+		close(quit)
+		return
+	}
+	defer js.Close()
+
+	// the reading goroutine keeps the state of the joystick, the ticker jogs with it
+	var mu sync.Mutex
+	axes := map[uint8]int16{}
+	buttons := map[uint8]bool{}
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		var event struct {
+			Time   uint32
+			Value  int16
+			Type   uint8
+			Number uint8
+		}
+		for {
+			if err := binary.Read(js, binary.LittleEndian, &event); err != nil {
+				if err != io.EOF {
+					fmt.Fprintf(os.Stderr, "joystick: %v\n", err)
+				}
+				return
+			}
+			mu.Lock()
+			switch event.Type &^ jsInit {
+			case jsAxis:
+				axes[event.Number] = event.Value
+			case jsButton:
+				buttons[event.Number] = event.Value != 0
+			}
+			mu.Unlock()
+			// the initial state doesn't press anything
+			if c, ok := jogButtonCommands[event.Number]; ok && event.Type == jsButton && event.Value != 0 {
+				commands <- jogCommand{command: c}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(jogPeriod)
+	defer ticker.Stop()
+	var last byte
+	for {
+		select {
+		case <-done:
+			close(quit)
+			return
+		case <-ticker.C:
+		}
+		jc := jogCommand{}
+		mu.Lock()
+		for n, v := range axes {
+			if jo, ok := jogAxes[n]; ok && (v <= -jsDeadzone || jsDeadzone <= v) {
+				jc.offsets = append(jc.offsets, jogOffset{jo.joint, jo.offset * step * int(v) / 32767})
+			}
+		}
+		for n, pressed := range buttons {
+			if !pressed {
+				continue
+			}
+			if jo, ok := jogButtons[n]; ok {
+				jc.offsets = append(jc.offsets, jogOffset{jo.joint, jo.offset * step})
+			}
+			// the button byte of ArmLink holds 7 buttons
+			if n < 7 {
+				jc.buttons |= 1 << n
+			}
+		}
+		mu.Unlock()
+		if len(jc.offsets) > 0 || jc.buttons != last {
+			commands <- jc
+		}
+		last = jc.buttons
+	}
+}
+
+// runJog jogs the arm with the keyboard and the joystick if any, until quit or the emergency stop
+func runJog(arm jogArm, step int, keyboard bool, joystick string) {
+	// keep the status line clear of the packets
+	log.SetOutput(io.Discard)
+
+	commands := make(chan jogCommand)
+	quit := make(chan bool)
+	if keyboard {
+		restore, err := rawTerminal()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "keyboard: %v\n", err)
+			return
+		}
+		defer restore()
+		fmt.Print(jogHelp)
+		go readKeys(step, commands, quit)
+	}
+	if joystick != "" {
+		// the keyboard quits if there's one, the joystick otherwise
+		jsQuit := make(chan bool)
+		go readJoystick(joystick, step, commands, jsQuit)
+		if !keyboard {
+			quit = jsQuit
+		}
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	fmt.Printf("\r%v   ", arm.status())
+
+	for {
+		select {
+		case jc := <-commands:
+			var err error
+			if jc.command != "" {
+				err = arm.do(jc.command)
+			} else {
+				err = arm.jog(jc.offsets, jc.buttons)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\r%v\n", err)
+			}
+			if jc.command == jogStop {
+				// the error tells what happened instead
+				if err == nil {
+					fmt.Println("\remergency stop")
+				}
+				return
+			}
+			fmt.Printf("\r%v   ", arm.status())
+		case <-quit:
+			fmt.Println()
+			return
+		case <-interrupt:
+			fmt.Println()
+			return
+		}
+	}
+}
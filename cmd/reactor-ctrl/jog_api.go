@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// apiArm jogs the arm through the API of Leubot with the token of the user,
+// the emergency stop takes the admin key
+type apiArm struct {
+	url      string
+	token    string
+	estopKey string
+	delta    uint8
+	last     string
+}
+
+// request sends the body in JSON with the key and decodes the reply if any,
+// a problem in the response is returned as the error
+func (aa *apiArm) request(method string, path string, key string, body interface{}, reply interface{}) error {
+	js, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, aa.url+path, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		var problem api.Problem
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil || problem.Detail == "" {
+			return fmt.Errorf("%v %v: %v", method, path, resp.Status)
+		}
+		return fmt.Errorf("%v %v: %v", method, path, problem.Detail)
+	}
+	if reply != nil {
+		return json.NewDecoder(resp.Body).Decode(reply)
+	}
+	return nil
+}
+
+// jog patches the posture by the offsets, clamped at the limits, the API has no buttons
+func (aa *apiArm) jog(offsets []jogOffset, buttons byte) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+	// the joints of the body are added up by name
+	body := map[string]interface{}{"delta": aa.delta, "clamp": true}
+	for _, jo := range offsets {
+		sum, _ := body[jo.joint].(int)
+		body[jo.joint] = sum + jo.offset
+	}
+	var rp api.RobotPose
+	if err := aa.request(http.MethodPatch, "/posture", aa.token, body, &rp); err != nil {
+		return err
+	}
+	aa.last = rp.String()
+	return nil
+}
+
+// do moves to the home pose, opens or closes the gripper with the actions,
+// or engages the emergency stop, putting the arm to sleep with the token if that fails
+func (aa *apiArm) do(command string) error {
+	var err error
+	switch command {
+	case jogHome:
+		err = aa.request(http.MethodPut, "/poses/home/apply", aa.token, api.PoseCommand{Delta: &aa.delta}, nil)
+	case jogOpen:
+		err = aa.request(http.MethodPut, "/actions/open", aa.token, api.Action{Delta: &aa.delta}, nil)
+	case jogClose:
+		err = aa.request(http.MethodPut, "/actions/close", aa.token, api.Action{Delta: &aa.delta}, nil)
+	case jogStop:
+		if err = aa.request(http.MethodPut, "/estop", aa.estopKey, nil, nil); err != nil {
+			if serr := aa.request(http.MethodPut, "/sleep", aa.token, nil, nil); serr != nil {
+				return fmt.Errorf("the emergency stop failed and the arm is not stopped: %v; %v", err, serr)
+			}
+			return fmt.Errorf("the emergency stop failed, the arm is put to sleep instead: %v", err)
+		}
+	}
+	if err == nil {
+		aa.last = command
+	}
+	return err
+}
+
+// checkEStopKey returns an error if the key for the emergency stop is not an admin key,
+// as only the admins may engage it
+func (aa *apiArm) checkEStopKey() error {
+	if aa.estopKey == "" {
+		return fmt.Errorf("the emergency stop through the API takes an admin key in --estopKey")
+	}
+	if err := aa.request(http.MethodGet, "/keys", aa.estopKey, nil, nil); err != nil {
+		return fmt.Errorf("the emergency stop through the API takes an admin key in --estopKey: %v", err)
+	}
+	return nil
+}
+
+func (aa *apiArm) status() string {
+	return aa.last
+}
@@ -6,8 +6,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/Interactions-HSG/leubot/api"
 	"github.com/Interactions-HSG/leubot/armlink"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
@@ -71,6 +73,36 @@ var (
 			Flag("extended", "Extended [0-254].").
 			Default("0").
 			Uint16()
+
+	jog = app.
+		Flag("jog", "Jog the joints from the posture with the keyboard, see the keys on start.").
+		Default("false").
+		Bool()
+
+	joystick = app.
+			Flag("joystick", "Jog the joints from the posture with the joystick at the device, e.g. /dev/input/js0: the sticks move the base, the shoulder, the wrist rotation and the elbow, A/Y the wrist angle and LB/RB the gripper, X/B open and close the gripper, Start goes back to the start and Back stops the arm.").
+			Default("").
+			String()
+
+	step = app.
+		Flag("step", "The ticks to jog by for each key press, or each 100 ms with the joystick fully tilted.").
+		Default("10").
+		Int()
+
+	leubotURL = app.
+			Flag("leubotURL", "Jog through the API of Leubot at the URL, e.g. http://127.0.0.1:6789/leubot/v1.3.4, instead of over the serial port.").
+			Default("").
+			String()
+
+	token = app.
+		Flag("token", "The token of the user or the key to jog through the API with.").
+		Default("").
+		String()
+
+	estopKey = app.
+			Flag("estopKey", "The admin key to engage the emergency stop through the API with, required with --leubotURL.").
+			Default("").
+			String()
 )
 
 func main() {
@@ -78,9 +110,36 @@ func main() {
 	parse := kingpin.MustParse(app.Parse(os.Args[1:]))
 	_ = parse
 
+	if (*jog || *joystick != "") && *leubotURL != "" {
+		arm := &apiArm{url: *leubotURL, token: *token, estopKey: *estopKey, delta: uint8(*delta)}
+		// don't jog without a working emergency stop
+		if err := arm.checkEStopKey(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		runJog(arm, *step, *jog, *joystick)
+		return
+	}
+
 	als := armlink.NewArmLinkSerial()
 	defer als.Close()
 
+	if *jog || *joystick != "" {
+		start := api.RobotPose{
+			Base:          *baseRotation,
+			Shoulder:      *shoulderRotation,
+			Elbow:         *elbowRotation,
+			WristAngle:    *wristAngle,
+			WristRotation: *wristRotation,
+			Gripper:       *gripper,
+		}
+		arm := &linkArm{als: als, start: start, pose: start, delta: uint8(*delta)}
+		// start from the posture
+		arm.send(0)
+		runJog(arm, *step, *jog, *joystick)
+		return
+	}
+
 	var alp *armlink.ArmLinkPacket
 
 	if *reset {
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// rawTerminal is only supported on Linux
+func rawTerminal() (func(), error) {
+	return nil, errors.New("jogging with the keyboard needs Linux")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// breaks returns a Problem if the pose breaks the constraint
+func breaks(c *api.Constraint, rp *api.RobotPose) *api.Problem {
+	if c.Holds(rp) {
+		return nil
+	}
+	return &api.Problem{
+		Detail:     fmt.Sprintf("The pose breaks the constraint %v: %v", c.Name, c),
+		Value:      c.Sum(rp),
+		Constraint: c.Name,
+	}
+}
+
+// checkPose returns a Problem if the target breaks a constraint coupling the joints
+// or the arm would enter a keep-out zone; every command moving the robot is checked
+func (controller *Controller) checkPose(target api.RobotPose) *api.Problem {
+	for i := range controller.Constraints {
+		if p := breaks(&controller.Constraints[i], &target); p != nil {
+			return p
+		}
+	}
+	return controller.checkCollision(target)
+}
+
+// dryRun returns every problem the posCom would be refused for, the envelope applies
+// if the token is a key or of the current user, the soft limits otherwise
+func (controller *Controller) dryRun(posCom api.PostureCommand) api.PoseCheck {
+	env := &envelope{limits: controller.SoftLimits}
+	if controller.findKey(posCom.Token) != nil {
+		env = controller.envelopeFor(posCom.Token)
+	} else if claims, err := controller.verifyToken(posCom.Token); err == nil && claims.Session == controller.CurrentUser.Session {
+		env = controller.envelopeFor(posCom.Token)
+	}
+
+	check := api.PoseCheck{Problems: []api.Problem{}}
+	target := posCom.RobotPose()
+	for _, joint := range api.JointNames {
+		if p := checkRange(env, joint, target.Get(joint)); p != nil {
+			check.Problems = append(check.Problems, *p)
+		}
+	}
+	if p := checkRange(env, "delta", uint16(posCom.Delta)); p != nil {
+		check.Problems = append(check.Problems, *p)
+	}
+	for i := range controller.Constraints {
+		if p := breaks(&controller.Constraints[i], &target); p != nil {
+			check.Problems = append(check.Problems, *p)
+		}
+	}
+	if p := controller.checkCollision(target); p != nil {
+		check.Problems = append(check.Problems, *p)
+	}
+	check.Valid = len(check.Problems) == 0
+	return check
+}
+
+// checkConstraints returns a Problem if the constraints are invalid
+func checkConstraints(constraints []api.Constraint) *api.Problem {
+	names := map[string]bool{}
+	for _, c := range constraints {
+		if c.Name == "" || names[c.Name] {
+			return &api.Problem{Detail: "The constraints need unique names", Field: "name", Value: c.Name}
+		}
+		names[c.Name] = true
+		if len(c.Weights) == 0 {
+			return &api.Problem{Detail: fmt.Sprintf("The constraint %v needs the weights of the joints", c.Name), Field: c.Name}
+		}
+		for joint := range c.Weights {
+			if _, ok := api.JointRanges[joint]; !ok || joint == "delta" {
+				return &api.Problem{Detail: fmt.Sprintf("No such joint: %v", joint), Field: c.Name, Value: joint}
+			}
+		}
+		if c.Min == nil && c.Max == nil {
+			return &api.Problem{Detail: fmt.Sprintf("The constraint %v needs min or max", c.Name), Field: c.Name}
+		}
+		if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+			return &api.Problem{Detail: fmt.Sprintf("The constraint %v must have min <= max", c.Name), Field: c.Name, Value: c}
+		}
+	}
+	return nil
+}
+
+// handleConstraint processes the messages on the constraints and the dry runs,
+// changing the constraints is only for the admins
+func (controller *Controller) handleConstraint(msg api.HandlerMessage) api.HandlerMessage {
+	switch msg.Type {
+	case api.TypeGetConstraints:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentConstraints,
+			Value: []interface{}{controller.Constraints},
+		}
+	case api.TypeCheckPosture:
+		posCom, ok := msg.Value[0].(api.PostureCommand)
+		if !ok {
+			break
+		}
+		return api.HandlerMessage{
+			Type:  api.TypePostureChecked,
+			Value: []interface{}{controller.dryRun(posCom)},
+		}
+	case api.TypePutConstraints:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		if failure := controller.requireAdmin(token); failure != nil {
+			return *failure
+		}
+		constraints, ok := msg.Value[1].([]api.Constraint)
+		if !ok {
+			break
+		}
+		if p := checkConstraints(constraints); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
+		if constraints == nil {
+			constraints = []api.Constraint{}
+		}
+		controller.Constraints = constraints
+		for _, c := range constraints {
+			log.Printf("[Constraint] %v: %v", c.Name, &c)
+		}
+		return api.HandlerMessage{Type: api.TypeConstraintsUpdated}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+func TestConstraints(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	bent := api.RobotPose{Base: 512, Shoulder: 400, Elbow: 650, WristAngle: 700, WristRotation: 512, Gripper: 128}
+
+	// nothing couples the joints by default
+	if check := dryRun(t, h, token, bent); !check.Valid {
+		t.Errorf("POST /posture/check: %+v", check.Problems)
+	}
+
+	max := 1300.0
+	fold := []api.Constraint{{Name: "fold", Weights: map[string]float64{"elbow": 1, "wristAngle": 1}, Max: &max}}
+	if rec := serve(h, http.MethodPut, "/constraints", token, fold); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /constraints as the user: %v, want 401", rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, "/constraints", testMasterKey, fold); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /constraints: %v %v", rec.Code, rec.Body)
+	}
+	rec := serve(h, http.MethodGet, "/constraints", "", nil)
+	var constraints []api.Constraint
+	if err := json.NewDecoder(rec.Body).Decode(&constraints); err != nil || len(constraints) != 1 || constraints[0].Name != "fold" {
+		t.Errorf("GET /constraints: %v %v", constraints, err)
+	}
+
+	if check := dryRun(t, h, token, bent); check.Valid || check.Problems[0].Constraint != "fold" || check.Problems[0].Value != 1350.0 {
+		t.Errorf("POST /posture/check: %+v", check)
+	}
+	rec = serve(h, http.MethodPut, "/posture", token, postureCommand(bent))
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); rec.Code != http.StatusBadRequest || err != nil || p.Constraint != "fold" {
+		t.Errorf("PUT /posture breaking fold: %v %+v", rec.Code, p)
+	}
+	bent.WristAngle = 650
+	if code := serve(h, http.MethodPut, "/posture", token, postureCommand(bent)).Code; code != http.StatusAccepted {
+		t.Errorf("PUT /posture within fold: %v", code)
+	}
+
+	// the admins drop them again
+	if rec := serve(h, http.MethodPut, "/constraints", testMasterKey, []api.Constraint{}); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /constraints: %v %v", rec.Code, rec.Body)
+	}
+	bent.WristAngle = 700
+	if check := dryRun(t, h, token, bent); !check.Valid {
+		t.Errorf("POST /posture/check: %+v", check.Problems)
+	}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	min, max := 600.0, 900.0
+	weights := map[string]float64{"shoulder": 1, "elbow": -1}
+	for name, tc := range map[string]struct {
+		constraints []api.Constraint
+		ok          bool
+	}{
+		"none":        {nil, true},
+		"min and max": {[]api.Constraint{{Name: "c", Weights: weights, Min: &min, Max: &max}}, true},
+		"max only":    {[]api.Constraint{{Name: "c", Weights: weights, Max: &max}}, true},
+		"no name":     {[]api.Constraint{{Weights: weights, Max: &max}}, false},
+		"same names":  {[]api.Constraint{{Name: "c", Weights: weights, Max: &max}, {Name: "c", Weights: weights, Min: &min}}, false},
+		"no weights":  {[]api.Constraint{{Name: "c", Max: &max}}, false},
+		"no joint":    {[]api.Constraint{{Name: "c", Weights: map[string]float64{"knee": 1}, Max: &max}}, false},
+		"delta":       {[]api.Constraint{{Name: "c", Weights: map[string]float64{"delta": 1}, Max: &max}}, false},
+		"no limit":    {[]api.Constraint{{Name: "c", Weights: weights}}, false},
+		"min > max":   {[]api.Constraint{{Name: "c", Weights: weights, Min: &max, Max: &min}}, false},
+	} {
+		if p := checkConstraints(tc.constraints); (p == nil) != tc.ok {
+			t.Errorf("checkConstraints with %v: %+v", name, p)
+		}
+	}
+}
@@ -10,79 +10,333 @@ import (
 	"github.com/badoux/checkmail"
 )
 
+// putJoints maps the commands for a single joint to the joint names
+var putJoints = map[api.HandlerMessageType]string{
+	api.TypePutBase:          "base",
+	api.TypePutShoulder:      "shoulder",
+	api.TypePutElbow:         "elbow",
+	api.TypePutWristAngle:    "wristAngle",
+	api.TypePutWristRotation: "wristRotation",
+	api.TypePutGripper:       "gripper",
+}
+
+// outOfRange creates the Problem for the value out of the limits for the joint
+func outOfRange(env *envelope, joint string, value interface{}) *api.Problem {
+	jr := env.limits[joint]
+	p := &api.Problem{
+		Detail:  fmt.Sprintf("The value for %v must be within [%v, %v]", joint, jr.Min, jr.Max),
+		Field:   joint,
+		Value:   value,
+		Range:   &jr,
+		Profile: env.profile,
+	}
+	if env.profile != "" {
+		p.Detail += " in the safety profile " + env.profile
+	}
+	return p
+}
+
+// checkRange returns a Problem if the value is out of the limits or forbidden for the joint
+func checkRange(env *envelope, joint string, value uint16) *api.Problem {
+	if jr := env.limits[joint]; !jr.Contains(value) {
+		return outOfRange(env, joint, value)
+	}
+	for _, fr := range env.forbidden[joint] {
+		if fr.Contains(value) {
+			return &api.Problem{
+				Detail:  fmt.Sprintf("The values [%v, %v] for %v are forbidden in the safety profile %v", fr.Min, fr.Max, joint, env.profile),
+				Field:   joint,
+				Value:   value,
+				Range:   &fr,
+				Profile: env.profile,
+			}
+		}
+	}
+	return nil
+}
+
+// jog returns the value of the joint moved by the offset, stopped at the limits if clamp,
+// or a Problem if it would leave them
+func jog(env *envelope, joint string, from uint16, offset int, clamp bool) (uint16, *api.Problem) {
+	jr := env.limits[joint]
+	v := int(from) + offset
+	if clamp {
+		if v < int(jr.Min) {
+			v = int(jr.Min)
+		}
+		if v > int(jr.Max) {
+			v = int(jr.Max)
+		}
+	}
+	if v < int(jr.Min) || v > int(jr.Max) {
+		return 0, outOfRange(env, joint, v)
+	}
+	return uint16(v), nil
+}
+
+// checkPosture returns a Problem for the first joint out of its limits in the posCom
+func checkPosture(env *envelope, posCom *api.PostureCommand) *api.Problem {
+	rp := posCom.RobotPose()
+	for _, joint := range api.JointNames {
+		if p := checkRange(env, joint, rp.Get(joint)); p != nil {
+			return p
+		}
+	}
+	return checkRange(env, "delta", uint16(posCom.Delta))
+}
+
 // Controller is the main thread for this API provider
 type Controller struct {
+	ActionSettings    api.ActionSettings
 	ArmLinkSerial     *armlink.ArmLinkSerial
+	Constraints       []api.Constraint
 	CurrentRobotPose  *api.RobotPose
 	CurrentRobotState RobotState
 	CurrentUser       *api.User
+	Events            chan api.HandlerMessage
 	HandlerChannel    chan api.HandlerMessage
+	Keys              []*api.APIKey
 	LastArmLinkPacket *armlink.ArmLinkPacket
-	MasterToken       string
+	LastSentPose      *api.RobotPose
+	LastSnapshot      []byte
+	Mailer            Notifier
+	Notifiers         []Notifier
+	PendingUsers      map[string]*pendingUser
+	Poses             []*api.NamedPose
+	Profiles          []*api.SafetyProfile
+	Programs          []*api.Program
+	Queue             []*api.QueueEntry
+	QueueChanged      chan struct{}
+	QueuePromoted     map[string]string
+	Recording         *recording
+	Replaying         *replaying
+	Reservations      []*api.Reservation
+	ReservationTimer  *time.Timer
+	Revoked           map[string]time.Time
+	SentPackets       [][]byte
+	SentPoses         []api.PoseSample
+	SoftLimits        map[string]api.JointRange
+	StartTime         time.Time
+	Store             *Store
 	UserActChannel    chan bool
+	UserDeadline      time.Time
+	UserReservationID string
 	UserTimer         *time.Timer
 	UserTimerFinish   chan bool
+	UserTimerRunning  bool
 	Version           string
+	Workspace         api.Workspace
 }
 
 // InitRobot initialize the robot
 func (controller *Controller) InitRobot() {
-	// turn on the light
-	switchLight(true)
+	// keep the robot still during the emergency stop
+	stopped := controller.CurrentRobotState == Stopped
+	if !stopped {
+		// turn on the light
+		switchLight(true)
 
-	// set the robot in Joint mode and go to home
-	alp := &armlink.ArmLinkPacket{}
-	alp.SetExtended(armlink.ExtendedReset)
-	controller.ArmLinkSerial.Send(alp.Bytes())
+		// set the robot in Joint mode and go to home
+		controller.sendExtended(armlink.ExtendedReset)
 
-	// reset CurrentRobotPose
-	controller.ResetPose()
+		// reset CurrentRobotPose
+		controller.ResetPose()
 
-	// sync with Leubot
-	alp = controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-	controller.ArmLinkSerial.Send(alp.Bytes())
-	log.Printf("[ArmLinkPacket] %v", alp.String())
+		// sync with Leubot
+		controller.sendPose(*defaultDelta)
+	}
 
 	// post to Slack - stop
 	postToSlack(fmt.Sprintf(`{"text":"<!here> User %v (%v) started using Leubot."}`, controller.CurrentUser.Name, controller.CurrentUser.Email))
 
 	// start the timer
-	if *userTimeout != 0 {
-		controller.UserTimer.Reset(time.Second * time.Duration(*userTimeout))
+	if *userTimeout != 0 && !controller.UserTimerRunning {
+		controller.UserTimerRunning = true
+		controller.resetUserTimer()
 		log.Printf("[UserTimer] Started for %v", controller.CurrentUser.ToUserInfo().Name)
-		go func() {
-			for {
-				select {
-				case <-controller.UserActChannel: // Upon any activity, reset the timer
-					log.Println("[UserTimer] Activity detected, resetting the timer")
-					controller.UserTimer.Reset(time.Second * time.Duration(*userTimeout))
-				case <-controller.UserTimer.C: // Inactive, logout
-					log.Printf("[UserTimer] Timeout, deleting the user %v", controller.CurrentUser.Name)
-					// reset CurrentRobotPose
-					controller.ResetPose()
-					// set the robot in sleep mode
-					controller.SleepRobot()
-					// post to Slack
-					postToSlack(fmt.Sprintf(`{"text":"<!here> User %v (%v) was inactive for %v seconds, releasing Leubot."}`, controller.CurrentUser.Name, controller.CurrentUser.Email, *userTimeout))
-
-					// delete the current user; assign an empty User
-					controller.CurrentUser = &api.User{}
-					return
-				case <-controller.UserTimerFinish:
-					log.Println("[UserTimer] User deleted, terminating the timer")
-					return
-				}
+		go controller.watchUserTimer()
+	}
+	if !stopped {
+		controller.setState(Ready)
+	}
+}
+
+// watchUserTimer resets the UserTimer upon the activities and deletes the user
+// through Events when it expires, until the user is deleted otherwise
+func (controller *Controller) watchUserTimer() {
+	for {
+		select {
+		case <-controller.UserActChannel: // Upon any activity, reset the timer
+			log.Println("[UserTimer] Activity detected, resetting the timer")
+			controller.UserTimer.Reset(time.Second * time.Duration(*userTimeout))
+		case <-controller.UserTimer.C: // Inactive, logout
+			if controller.expireUser() {
+				return
+			}
+		case <-controller.UserTimerFinish:
+			log.Println("[UserTimer] User deleted, terminating the timer")
+			return
+		}
+	}
+}
+
+// expireUser asks the controller to delete the inactive user, returns false
+// if the user became active in the meantime
+func (controller *Controller) expireUser() bool {
+	select {
+	case controller.Events <- api.HandlerMessage{Type: api.TypeUserTimeout}:
+		return true
+	case <-controller.UserActChannel:
+		log.Println("[UserTimer] Activity detected, resetting the timer")
+		controller.UserTimer.Reset(time.Second * time.Duration(*userTimeout))
+		return false
+	case <-controller.UserTimerFinish:
+		log.Println("[UserTimer] User deleted, terminating the timer")
+		return true
+	}
+}
+
+// resetUserTimer (re)starts the UserTimer and records its deadline
+func (controller *Controller) resetUserTimer() {
+	timeout := time.Second * time.Duration(*userTimeout)
+	controller.UserTimer.Reset(timeout)
+	controller.UserDeadline = time.Now().Add(timeout)
+}
+
+// ackUserTimer notifies the UserTimer of an activity of the user
+func (controller *Controller) ackUserTimer() {
+	if controller.UserTimerRunning {
+		controller.UserDeadline = time.Now().Add(time.Second * time.Duration(*userTimeout))
+		controller.UserActChannel <- true
+	}
+}
+
+// Status reports the current status of the controller
+func (controller *Controller) Status() api.Status {
+	status := api.Status{
+		State:           controller.CurrentRobotState.String(),
+		SerialConnected: true,
+		Uptime:          int64(time.Since(controller.StartTime).Seconds()),
+		Version:         controller.Version,
+		UserActive:      *controller.CurrentUser != (api.User{}),
+	}
+	if err := controller.ArmLinkSerial.Err(); err != nil {
+		status.SerialConnected = false
+		status.SerialError = err.Error()
+	}
+	if status.UserActive && *userTimeout != 0 {
+		status.SessionRemaining = int64(time.Until(controller.UserDeadline).Seconds())
+	}
+	return status
+}
+
+// setState changes CurrentRobotState, accounting the time spent in the states
+func (controller *Controller) setState(state RobotState) {
+	controller.CurrentRobotState = state
+	robotStateClock.Set(state)
+}
+
+// send writes the packet to the serial and records it
+func (controller *Controller) send(alp *armlink.ArmLinkPacket) {
+	controller.LastArmLinkPacket = alp
+	b := alp.Bytes()
+	controller.SentPackets = append(controller.SentPackets, b)
+	packetsSent.Inc()
+	if err := controller.ArmLinkSerial.Send(b); err != nil {
+		serialErrors.Inc()
+	}
+	log.Printf("[ArmLinkPacket] %v", alp.String())
+}
+
+// sendPose moves the robot to CurrentRobotPose with the delta
+func (controller *Controller) sendPose(delta uint8) {
+	controller.send(controller.CurrentRobotPose.BuildArmLinkPacket(delta))
+	// account the travel from the last pose sent
+	if controller.LastSentPose != nil {
+		for _, joint := range api.JointNames {
+			from, to := controller.LastSentPose.Get(joint), controller.CurrentRobotPose.Get(joint)
+			if from > to {
+				from, to = to, from
 			}
-		}()
-	} // End if *userTimeout != 0
-	controller.CurrentRobotState = Ready
+			jointTravel.WithLabelValues(joint).Add(float64(to - from))
+		}
+	}
+	rp := *controller.CurrentRobotPose
+	controller.LastSentPose = &rp
+	controller.SentPoses = append(controller.SentPoses, api.PoseSample{Time: time.Now().UTC(), Delta: delta, Pose: rp})
+}
+
+// sendExtended sends the extended instruction, after which the pose of the robot is unknown
+func (controller *Controller) sendExtended(e byte) {
+	alp := &armlink.ArmLinkPacket{}
+	alp.SetExtended(e)
+	controller.send(alp)
+	controller.LastSentPose = nil
+}
+
+// startSession registers the user and initializes the robot,
+// the user keeps the robot until the end of the reservation if any
+func (controller *Controller) startSession(user *api.User, rsv *api.Reservation) {
+	controller.CurrentUser = user
+	controller.UserReservationID = ""
+	if rsv != nil {
+		controller.UserReservationID = rsv.ID
+	}
+	sessionsStarted.Inc()
+
+	// initialize the robot
+	controller.InitRobot()
+}
+
+// releaseUser deletes the current user and sleeps the robot, the reason is for the metrics
+func (controller *Controller) releaseUser(reason string) {
+	// stop the timer
+	if controller.UserTimerRunning {
+		controller.UserTimer.Stop()
+		controller.UserTimerFinish <- true
+		controller.UserTimerRunning = false
+	}
+
+	// keep the robot still during the emergency stop
+	if controller.CurrentRobotState != Stopped {
+		// reset CurrentRobotPose
+		controller.ResetPose()
+
+		// set the robot in sleep mode
+		controller.SleepRobot()
+	}
+
+	// post to Slack - stop
+	postToSlack(fmt.Sprintf(`{"text":"<!here> User %v (%v) stopped using Leubot (%v)."}`, controller.CurrentUser.Name, controller.CurrentUser.Email, reason))
+
+	// save the recording and stop the replay of the session
+	if controller.Recording != nil && controller.Recording.session == controller.CurrentUser.Session {
+		controller.finishRecording()
+	}
+	controller.stopReplay()
+
+	// forget the ticket of the user promoted from the queue
+	for ticket, session := range controller.QueuePromoted {
+		if session == controller.CurrentUser.Session {
+			delete(controller.QueuePromoted, ticket)
+		}
+	}
+
+	// the token of the ended session may not be used anymore
+	controller.revoke(controller.CurrentUser.TokenID, controller.CurrentUser.Expires)
+
+	// delete the current user; assign an empty User
+	controller.CurrentUser = &api.User{}
+	controller.UserReservationID = ""
+	sessionsEnded.WithLabelValues(reason).Inc()
+
+	// hand over to the next user in the queue
+	controller.promoteQueue()
 }
 
 // SleepRobot sleeps the robot
 func (controller *Controller) SleepRobot() {
-	alp := armlink.ArmLinkPacket{}
-	alp.SetExtended(armlink.ExtendedSleep)
-	controller.ArmLinkSerial.Send(alp.Bytes())
+	controller.sendExtended(armlink.ExtendedSleep)
 	// turn off the light
 	switchLight(false)
 	// zero out the CurrentRobotPose
@@ -95,681 +349,707 @@ func (controller *Controller) SleepRobot() {
 		Gripper:       0,
 	}
 	// enter sleeping state
-	controller.CurrentRobotState = Sleeping
+	controller.setState(Sleeping)
 }
 
-// Validate checks if the given token is valid, if the token is master token
-// and there's no user then create a super user
+// Validate checks if the given token may control the robot; an operator or admin key
+// starts a session on behalf of the key if there's no user, and an admin key may
+// control the robot while someone else is using it
 func (controller *Controller) Validate(token string) api.HandlerMessageType {
-	log.Printf("Validate the token: %v", token)
-	if token == controller.MasterToken {
-		if *controller.CurrentUser == (api.User{}) {
-			// register a super user
-			log.Println("Create a super user")
-			controller.CurrentUser = &api.User{
-				Name:  "Super User",
-				Email: "root@interactions.ics.unisg.ch",
-				Token: token,
-			}
-			controller.UserTimer.Reset(time.Second * time.Duration(*userTimeout))
-
-			// initialize the robot
-			controller.InitRobot()
-			return api.TypeUserAdded
+	key := controller.findKey(token)
+	session := controller.sessionOf(token)
+	switch {
+	case key != nil && key.Role == api.RoleObserver:
+		return api.TypeForbidden
+	case session != "" && session == controller.CurrentUser.Session:
+		return api.TypeUserExisted
+	case key != nil && *controller.CurrentUser == (api.User{}):
+		// the operators are bound to the reservations of others
+		rsv := controller.activeReservation(time.Now())
+		if key.Role != api.RoleAdmin && rsv != nil && rsv.Email != key.Email {
+			return api.TypeSlotReserved
 		}
+		// register the key as the user
+		log.Printf("Create a session for the %v key %v", key.Role, key.ID)
+		controller.startSession(key.ToUser(), nil)
+		return api.TypeUserAdded
+	case key != nil && key.Role == api.RoleAdmin:
 		return api.TypeUserExisted
-	} else if controller.CurrentUser.ToUserInfo() == (api.UserInfo{}) {
+	case *controller.CurrentUser == (api.User{}):
 		// no user exists
 		return api.TypeUserNotFound
-	} else if token == controller.CurrentUser.Token {
-		return api.TypeUserExisted
 	}
 	return api.TypeInvalidToken
 }
 
+// sessionOf returns the session of the key or the signed token, empty if the token is invalid
+func (controller *Controller) sessionOf(token string) string {
+	if key := controller.findKey(token); key != nil {
+		return key.Session()
+	}
+	if claims, err := controller.verifyToken(token); err == nil {
+		return claims.Session
+	}
+	return ""
+}
+
+// authFailure creates the feedback for the token which failed in Validate
+func (controller *Controller) authFailure(token string, userAuth api.HandlerMessageType) api.HandlerMessage {
+	p := api.Problem{}
+	switch userAuth {
+	case api.TypeInvalidToken:
+		holder := controller.CurrentUser.ToUserInfo()
+		p.Detail = "The token does not belong to the current user"
+		if _, err := controller.verifyToken(token); err != nil && controller.findKey(token) == nil {
+			p.Detail = "The token is invalid: " + err.Error()
+		}
+		p.Holder = &holder
+	case api.TypeUserNotFound:
+		p.Detail = "No user is using Leubot, add a user first"
+	case api.TypeForbidden:
+		p.Detail = "Observer keys may only read the state of the robot"
+	case api.TypeSlotReserved:
+		if rsv := controller.activeReservation(time.Now()); rsv != nil {
+			holder := rsv.ToUserInfo()
+			p.Detail = fmt.Sprintf("Leubot is reserved until %v", rsv.End.Format(time.RFC3339))
+			p.Holder = &holder
+		}
+	}
+	return api.HandlerMessage{
+		Type:  userAuth,
+		Value: []interface{}{p},
+	}
+}
+
+// homePose is where the robot goes on reset
+var homePose = api.RobotPose{
+	Base:          512,
+	Shoulder:      400,
+	Elbow:         400,
+	WristAngle:    580,
+	WristRotation: 512,
+	Gripper:       128,
+}
+
 // ResetPose resets the RobotPose to its home position
 func (controller *Controller) ResetPose() {
-	controller.CurrentRobotPose = &api.RobotPose{
-		Base:          512,
-		Shoulder:      400,
-		Elbow:         400,
-		WristAngle:    580,
-		WristRotation: 512,
-		Gripper:       128,
-	}
+	pose := homePose
+	controller.CurrentRobotPose = &pose
 }
 
 // Shutdown processes the graceful termination of the program
 func (controller *Controller) Shutdown() {
 	// set the robot in sleep mode
-	alp := armlink.ArmLinkPacket{}
-	alp.SetExtended(armlink.ExtendedSleep)
-	controller.ArmLinkSerial.Send(alp.Bytes())
+	controller.sendExtended(armlink.ExtendedSleep)
 	// turn off the light
 	switchLight(false)
 }
 
-// NewController creates a new instance of Controller
-func NewController(als *armlink.ArmLinkSerial, mt string, ver string) *Controller {
-	hmc := make(chan api.HandlerMessage)
-	controller := Controller{
-		ArmLinkSerial:     als,
-		CurrentRobotPose:  &api.RobotPose{},
-		CurrentRobotState: Offline,
-		CurrentUser:       &api.User{},
-		HandlerChannel:    hmc,
-		LastArmLinkPacket: &armlink.ArmLinkPacket{},
-		MasterToken:       mt,
-		UserActChannel:    make(chan bool),
-		UserTimer:         time.NewTimer(time.Second * 10),
-		UserTimerFinish:   make(chan bool),
-		Version:           ver,
-	}
-	controller.ResetPose()
-	controller.UserTimer.Stop()
-
-	// set the robot in sleep mode
-	controller.SleepRobot()
-
-	go func() {
-		for {
-			msg, ok := <-hmc
-			if !ok {
-				break
+// handle processes the message from HandlerChannel and returns the feedback
+func (controller *Controller) handle(msg api.HandlerMessage) api.HandlerMessage {
+	switch msg.Type {
+	case api.TypeAddUser:
+		userInfo, ok := msg.Value[0].(api.UserInfo)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
 			}
+		}
+		var callback string
+		if len(msg.Value) > 1 {
+			callback, _ = msg.Value[1].(string)
+		}
+		var verified bool
+		if len(msg.Value) > 2 {
+			verified, _ = msg.Value[2].(bool)
+		}
 
-			log.Printf("%v", controller.CurrentRobotPose.String())
-			switch msg.Type {
-			case api.TypeAddUser:
-				userInfo, ok := msg.Value[0].(api.UserInfo)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
-
-				// check if the email is valid
-				if err := checkmail.ValidateFormat(userInfo.Email); err != nil {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidUserInfo,
-					}
-					break
-				}
-
-				// check if there's no user in the system
-				if controller.CurrentUser.ToUserInfo() != (api.UserInfo{}) && userInfo.Email != controller.CurrentUser.Email {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeUserExisted,
-					}
-					break
-				}
-
-				// reissue the token for the existing user an return
-				if userInfo.Email == controller.CurrentUser.Email {
-					controller.CurrentUser = api.NewUser(&userInfo)
-					log.Printf("Token reissued for %v", userInfo.Name)
-					controller.UserTimer.Reset(time.Second * time.Duration(*userTimeout))
-					log.Println("[UserTimer] Timer resetted")
-					// skip the rest and return the response with the new token
-					hmc <- api.HandlerMessage{
-						Type:  api.TypeUserAdded,
-						Value: []interface{}{*controller.CurrentUser},
-					}
-					break
-				}
-
-				// register the user to the system with the new token
-				controller.CurrentUser = api.NewUser(&userInfo)
-
-				// initialize the robot
-				controller.InitRobot()
-
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeUserAdded,
-					Value: []interface{}{*controller.CurrentUser},
-				}
-			case api.TypeGetUser:
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentUser,
-					Value: []interface{}{controller.CurrentUser.ToUserInfo()},
-				}
-			case api.TypeDeleteUser:
-				// receive the token
-				token, ok := msg.Value[0].(string)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
-				// check if the token is valid
-				userAuth := controller.Validate(token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
-
-				// stop the timer
-				if *userTimeout != 0 {
-					controller.UserTimer.Stop()
-					controller.UserTimerFinish <- true
-				}
-
-				// reset CurrentRobotPose
-				controller.ResetPose()
-
-				// set the robot in sleep mode
-				controller.SleepRobot()
-
-				// post to Slack - start
-				postToSlack(fmt.Sprintf(`{"text":"<!here> User %v (%v) started using Leubot."}`, controller.CurrentUser.Name, controller.CurrentUser.Email))
-
-				// delete the current user; assign an empty User
-				controller.CurrentUser = &api.User{}
+		// check if the email is valid
+		if err := checkmail.ValidateFormat(userInfo.Email); err != nil {
+			return api.HandlerMessage{
+				Type: api.TypeInvalidUserInfo,
+				Value: []interface{}{api.Problem{
+					Detail: err.Error(),
+					Field:  "email",
+					Value:  userInfo.Email,
+				}},
+			}
+		}
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeUserDeleted,
-				}
-			case api.TypeGetBase:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentBase,
-					Value: []interface{}{controller.CurrentRobotPose.Base},
-				}
-			case api.TypeGetShoulder:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentShoulder,
-					Value: []interface{}{controller.CurrentRobotPose.Shoulder},
-				}
-			case api.TypeGetElbow:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentElbow,
-					Value: []interface{}{controller.CurrentRobotPose.Elbow},
-				}
-			case api.TypeGetWristAngle:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentWristAngle,
-					Value: []interface{}{controller.CurrentRobotPose.WristAngle},
-				}
-			case api.TypeGetWristRotation:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentWristRotation,
-					Value: []interface{}{controller.CurrentRobotPose.WristRotation},
-				}
-			case api.TypeGetGripper:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentGripper,
-					Value: []interface{}{controller.CurrentRobotPose.Gripper},
-				}
-			case api.TypeGetPosture:
-				hmc <- api.HandlerMessage{
-					Type:  api.TypeCurrentPosture,
-					Value: []interface{}{*controller.CurrentRobotPose},
-				}
-			case api.TypePutBase:
-				// receive the roboCom
-				roboCom, ok := msg.Value[0].(api.RobotCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
+		// the token is posted to the callback, only to the web
+		if callback != "" {
+			if p := checkCallback(callback); p != nil {
+				return api.HandlerMessage{
+					Type:  api.TypeInvalidUserInfo,
+					Value: []interface{}{*p},
 				}
+			}
+		}
 
-				// check if the token is valid
-				userAuth := controller.Validate(roboCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// the email needs to be verified first unless the user logged in or clicked the link
+		if *verifyEmail && !verified {
+			return controller.requestVerification(&userInfo, callback)
+		}
 
-				// check the value is valid
-				if roboCom.Value < 0 || 1023 < roboCom.Value {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// check if the robot is reserved by someone else now
+		now := time.Now()
+		controller.enforceReservations(now)
+		rsv := controller.activeReservation(now)
+		if rsv != nil && rsv.Email != userInfo.Email {
+			holder := rsv.ToUserInfo()
+			return api.HandlerMessage{
+				Type: api.TypeSlotReserved,
+				Value: []interface{}{api.Problem{
+					Detail: fmt.Sprintf("Leubot is reserved until %v", rsv.End.Format(time.RFC3339)),
+					Holder: &holder,
+				}},
+			}
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
+		// check if there's no user in the system
+		if controller.CurrentUser.ToUserInfo() != (api.UserInfo{}) && userInfo.Email != controller.CurrentUser.Email {
+			// wait in the queue
+			if *userQueue {
+				return api.HandlerMessage{
+					Type:  api.TypeUserQueued,
+					Value: []interface{}{*controller.joinQueue(&userInfo, callback)},
 				}
+			}
+			holder := controller.CurrentUser.ToUserInfo()
+			return api.HandlerMessage{
+				Type: api.TypeUserExisted,
+				Value: []interface{}{api.Problem{
+					Detail: "Leubot is currently used by another user, try again later",
+					Holder: &holder,
+				}},
+			}
+		}
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+		// reissue the token for the existing user an return
+		if userInfo.Email == controller.CurrentUser.Email {
+			controller.revoke(controller.CurrentUser.TokenID, controller.CurrentUser.Expires)
+			controller.CurrentUser = api.NewUser(&userInfo)
+			log.Printf("Token reissued for %v", userInfo.Name)
+			controller.resetUserTimer()
+			log.Println("[UserTimer] Timer resetted")
+			// skip the rest and return the response with the new token
+			return api.HandlerMessage{
+				Type:  api.TypeUserAdded,
+				Value: []interface{}{*controller.CurrentUser},
+			}
+		}
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.Base = roboCom.Value
+		// register the user to the system with the new token and initialize the robot
+		controller.startSession(api.NewUser(&userInfo), rsv)
 
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
+		// feedback
+		return api.HandlerMessage{
+			Type:  api.TypeUserAdded,
+			Value: []interface{}{*controller.CurrentUser},
+		}
+	case api.TypeUserTimeout:
+		log.Printf("[UserTimer] Timeout, deleting the user %v", controller.CurrentUser.Name)
+		// the timer goroutine terminates by itself
+		controller.UserTimerRunning = false
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutShoulder:
-				// receive the roboCom
-				roboCom, ok := msg.Value[0].(api.RobotCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
+		// post to Slack
+		postToSlack(fmt.Sprintf(`{"text":"<!here> User %v (%v) was inactive for %v seconds, releasing Leubot."}`, controller.CurrentUser.Name, controller.CurrentUser.Email, *userTimeout))
 
-				// check if the token is valid
-				userAuth := controller.Validate(roboCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// delete the user and sleep the robot
+		controller.releaseUser("timeout")
 
-				// check the value is valid
-				if roboCom.Value < 205 || 810 < roboCom.Value {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// feedback
+		return api.HandlerMessage{
+			Type: api.TypeUserDeleted,
+		}
+	case api.TypeGetUser:
+		// feedback
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentUser,
+			Value: []interface{}{controller.CurrentUser.ToUserInfo()},
+		}
+	case api.TypeDeleteUser:
+		// receive the token
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
+			}
+		}
+		// check if the token is valid
+		userAuth := controller.Validate(token)
+		if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+			// feedback
+			return controller.authFailure(token, userAuth)
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// delete the user and sleep the robot
+		controller.releaseUser("deleted")
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+		// feedback
+		return api.HandlerMessage{
+			Type: api.TypeUserDeleted,
+		}
+	case api.TypeVerifyUser:
+		code, ok := msg.Value[0].(string)
+		if !ok {
+			return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+		}
+		return controller.verifyUser(code)
+	case api.TypeRefreshToken:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+		}
+		return controller.refreshToken(token)
+	case api.TypeGetReservations, api.TypeGetReservation, api.TypeAddReservation, api.TypeUpdateReservation, api.TypeDeleteReservation, api.TypeReservationBoundary:
+		return controller.handleReservation(msg)
+	case api.TypeGetTicket, api.TypeDeleteTicket:
+		return controller.handleQueue(msg)
+	case api.TypeKickUser, api.TypeGetAudit, api.TypeGetHistory, api.TypeGetKeys, api.TypeAddKey, api.TypeDeleteKey, api.TypeGetLimits, api.TypePutLimits, api.TypePutEStop, api.TypeDeleteEStop:
+		return controller.handleAdmin(msg)
+	case api.TypeGetProfiles, api.TypePutProfile, api.TypeDeleteProfile:
+		return controller.handleProfile(msg)
+	case api.TypeGetWorkspace, api.TypePutWorkspace:
+		return controller.handleWorkspace(msg)
+	case api.TypeGetConstraints, api.TypePutConstraints, api.TypeCheckPosture:
+		return controller.handleConstraint(msg)
+	case api.TypeGetPoses, api.TypeGetPose, api.TypePutPose, api.TypeDeletePose, api.TypeApplyPose:
+		return controller.handlePose(msg)
+	case api.TypeGetActionSettings, api.TypePutActionSettings, api.TypePerformAction:
+		return controller.handleAction(msg)
+	case api.TypeStartRecording, api.TypeStopRecording, api.TypeGetPrograms, api.TypeGetProgram, api.TypeAddProgram, api.TypePutProgram,
+		api.TypeDeleteProgram, api.TypeReplayProgram, api.TypeStopReplay, api.TypeReplayFinished:
+		return controller.handleProgram(msg)
+	case api.TypeGetBase:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentBase,
+			Value: []interface{}{controller.CurrentRobotPose.Base},
+		}
+	case api.TypeGetShoulder:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentShoulder,
+			Value: []interface{}{controller.CurrentRobotPose.Shoulder},
+		}
+	case api.TypeGetElbow:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentElbow,
+			Value: []interface{}{controller.CurrentRobotPose.Elbow},
+		}
+	case api.TypeGetWristAngle:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentWristAngle,
+			Value: []interface{}{controller.CurrentRobotPose.WristAngle},
+		}
+	case api.TypeGetWristRotation:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentWristRotation,
+			Value: []interface{}{controller.CurrentRobotPose.WristRotation},
+		}
+	case api.TypeGetGripper:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentGripper,
+			Value: []interface{}{controller.CurrentRobotPose.Gripper},
+		}
+	case api.TypeGetStatus:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentStatus,
+			Value: []interface{}{controller.Status()},
+		}
+	case api.TypeGetPosture:
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentPosture,
+			Value: []interface{}{*controller.CurrentRobotPose},
+		}
+	case api.TypePutBase, api.TypePutShoulder, api.TypePutElbow, api.TypePutWristAngle, api.TypePutWristRotation, api.TypePutGripper:
+		joint := putJoints[msg.Type]
+
+		// receive the roboCom
+		roboCom, ok := msg.Value[0].(api.RobotCommand)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
+			}
+		}
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.Shoulder = roboCom.Value
+		// check if the token is valid
+		userAuth := controller.Validate(roboCom.Token)
+		if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+			// feedback
+			return controller.authFailure(roboCom.Token, userAuth)
+		}
 
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
+		// refuse to move during the emergency stop
+		if controller.CurrentRobotState == Stopped {
+			return controller.stoppedFailure()
+		}
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutElbow:
-				// receive the roboCom
-				roboCom, ok := msg.Value[0].(api.RobotCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
+		// move by the offset from where the joint is
+		if roboCom.Offset != nil {
+			from := controller.startPose()
+			var p *api.Problem
+			if roboCom.Value, p = jog(controller.envelopeFor(roboCom.Token), joint, from.Get(joint), *roboCom.Offset, roboCom.Clamp); p != nil {
+				return api.HandlerMessage{
+					Type:  api.TypeInvalidCommand,
+					Value: []interface{}{*p},
 				}
+			}
+		}
 
-				// check if the token is valid
-				userAuth := controller.Validate(roboCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// check the value is valid
+		if p := checkRange(controller.envelopeFor(roboCom.Token), joint, roboCom.Value); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
 
-				// check the value is valid
-				if roboCom.Value < 210 || 900 < roboCom.Value {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// check the constraints and the keep-out zones on the resulting pose
+		target := controller.startPose()
+		target.Set(joint, roboCom.Value)
+		if p := controller.checkPose(target); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// the delta for the speed of the move if given, within the limits either way
+		delta, p := speedDelta(controller.startPose(), target, *defaultDelta, roboCom.Speed)
+		if p == nil {
+			p = checkRange(controller.envelopeFor(roboCom.Token), "delta", uint16(delta))
+		}
+		if p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+		// ack the timer
+		controller.ackUserTimer()
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.Elbow = roboCom.Value
+		// wake up if sleeping
+		if controller.CurrentRobotState == Sleeping {
+			log.Println("Leubot is sleeping, waking up")
+			controller.InitRobot()
+		}
 
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
+		// set the value to CurrentRobotPose
+		controller.CurrentRobotPose.Set(joint, roboCom.Value)
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutWristAngle:
-				// receive the roboCom
-				roboCom, ok := msg.Value[0].(api.RobotCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
+		// perform the move
+		controller.sendPose(delta)
 
-				// check if the token is valid
-				userAuth := controller.Validate(roboCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// feedback with the value moving to and when it gets there
+		return api.HandlerMessage{
+			Type:  api.TypeActionPerformed,
+			Value: []interface{}{roboCom.Value, completion(delta)},
+		}
+	case api.TypePatchPosture:
+		// receive the jog
+		pj, ok := msg.Value[0].(api.PostureJog)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
+			}
+		}
 
-				// check the value is valid
-				if roboCom.Value < 200 || 830 < roboCom.Value {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// check if the token is valid
+		userAuth := controller.Validate(pj.Token)
+		if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+			// feedback
+			return controller.authFailure(pj.Token, userAuth)
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// refuse to move during the emergency stop
+		if controller.CurrentRobotState == Stopped {
+			return controller.stoppedFailure()
+		}
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
+		// move every joint by its offset from where it is, all or none
+		env := controller.envelopeFor(pj.Token)
+		target := controller.startPose()
+		for _, joint := range api.JointNames {
+			if pj.Offset(joint) == 0 {
+				continue
+			}
+			v, p := jog(env, joint, target.Get(joint), pj.Offset(joint), pj.Clamp)
+			if p != nil {
+				return api.HandlerMessage{
+					Type:  api.TypeInvalidCommand,
+					Value: []interface{}{*p},
 				}
+			}
+			target.Set(joint, v)
+		}
+		delta := *defaultDelta
+		if pj.Delta != nil {
+			delta = *pj.Delta
+		}
+		reply := controller.handle(api.HandlerMessage{
+			Type: api.TypePutPosture,
+			Value: []interface{}{api.PostureCommand{
+				Token:         pj.Token,
+				Base:          target.Base,
+				Shoulder:      target.Shoulder,
+				Elbow:         target.Elbow,
+				WristAngle:    target.WristAngle,
+				WristRotation: target.WristRotation,
+				Gripper:       target.Gripper,
+				Delta:         delta,
+				Speed:         pj.Speed,
+			}},
+		})
+		if reply.Type == api.TypeActionPerformed {
+			// feedback with the posture moving to and when it gets there
+			reply.Value = append([]interface{}{target}, reply.Value...)
+		}
+		return reply
+	case api.TypePutPosture:
+		// receive the posCom
+		posCom, ok := msg.Value[0].(api.PostureCommand)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
+			}
+		}
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.WristAngle = roboCom.Value
-
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
-
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutWristRotation:
-				// receive the roboCom
-				roboCom, ok := msg.Value[0].(api.RobotCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
+		// check if the token is valid
+		userAuth := controller.Validate(posCom.Token)
+		if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+			// feedback
+			return controller.authFailure(posCom.Token, userAuth)
+		}
 
-				// check if the token is valid
-				userAuth := controller.Validate(roboCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// refuse to move during the emergency stop
+		if controller.CurrentRobotState == Stopped {
+			return controller.stoppedFailure()
+		}
 
-				// check the value is valid
-				if roboCom.Value < 0 || 1023 < roboCom.Value {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// ack the timer
+		controller.ackUserTimer()
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// the delta for the speed of the move
+		var p *api.Problem
+		if posCom.Delta, p = speedDelta(controller.startPose(), posCom.RobotPose(), posCom.Delta, posCom.Speed); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+		// check the value is valid
+		log.Printf("[Posture] %v", posCom)
+		if p := checkPosture(controller.envelopeFor(posCom.Token), &posCom); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.WristRotation = roboCom.Value
+		// check the constraints and the keep-out zones on the resulting pose
+		if p := controller.checkPose(posCom.RobotPose()); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
 
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
+		// wake up if sleeping
+		if controller.CurrentRobotState == Sleeping {
+			log.Println("Leubot is sleeping, waking up")
+			controller.InitRobot()
+		}
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutGripper:
-				// receive the roboCom
-				roboCom, ok := msg.Value[0].(api.RobotCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
+		// set the value to CurrentRobotPose
+		controller.CurrentRobotPose.Base = posCom.Base
+		controller.CurrentRobotPose.Shoulder = posCom.Shoulder
+		controller.CurrentRobotPose.Elbow = posCom.Elbow
+		controller.CurrentRobotPose.WristAngle = posCom.WristAngle
+		controller.CurrentRobotPose.WristRotation = posCom.WristRotation
+		controller.CurrentRobotPose.Gripper = posCom.Gripper
+
+		// perform the move
+		controller.sendPose(posCom.Delta)
+
+		// feedback with when the joints get there
+		return api.HandlerMessage{
+			Type:  api.TypeActionPerformed,
+			Value: []interface{}{completion(posCom.Delta)},
+		}
+	case api.TypePutReset:
+		// receive the token
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
+			}
+		}
 
-				// check if the token is valid
-				userAuth := controller.Validate(roboCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// check if the token is valid
+		userAuth := controller.Validate(token)
+		if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+			// feedback
+			return controller.authFailure(token, userAuth)
+		}
 
-				// check the value is valid
-				if roboCom.Value < 0 || 512 < roboCom.Value {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// refuse to move during the emergency stop
+		if controller.CurrentRobotState == Stopped {
+			return controller.stoppedFailure()
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// ack the timer
+		controller.ackUserTimer()
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+		// wake up if sleeping
+		if controller.CurrentRobotState == Sleeping {
+			log.Println("Leubot is sleeping, waking up")
+			controller.InitRobot()
+		}
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.Gripper = roboCom.Value
+		// perform the reset
+		controller.sendExtended(armlink.ExtendedReset)
 
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
+		// reset CurrentRobotPose
+		controller.ResetPose()
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutPosture:
-				// receive the posCom
-				posCom, ok := msg.Value[0].(api.PostureCommand)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
+		// sync with Leubot
+		controller.sendPose(*defaultDelta)
 
-				// check if the token is valid
-				userAuth := controller.Validate(posCom.Token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+		// feedback
+		return api.HandlerMessage{
+			Type: api.TypeActionPerformed,
+		}
+	case api.TypePutSleep:
+		// receive the token
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			return api.HandlerMessage{
+				Type: api.TypeSomethingWentWrong,
+			}
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// check if the token is valid
+		userAuth := controller.Validate(token)
+		if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+			// feedback
+			return controller.authFailure(token, userAuth)
+		}
 
-				// check the value is valid
-				log.Printf("[Posture] %v", posCom)
-				if posCom.Base < 0 || 1023 < posCom.Base ||
-					posCom.Shoulder < 205 || 810 < posCom.Shoulder ||
-					posCom.Elbow < 210 || 900 < posCom.Elbow ||
-					posCom.WristAngle < 200 || 830 < posCom.WristAngle ||
-					posCom.WristRotation < 0 || 1023 < posCom.WristRotation ||
-					posCom.Gripper < 0 || 512 < posCom.Gripper ||
-					posCom.Delta < 0 || 254 < posCom.Delta {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeInvalidCommand,
-					}
-					break
-				}
+		// refuse to move during the emergency stop
+		if controller.CurrentRobotState == Stopped {
+			return controller.stoppedFailure()
+		}
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+		// ack the timer
+		controller.ackUserTimer()
 
-				// set the value to CurrentRobotPose
-				controller.CurrentRobotPose.Base = posCom.Base
-				controller.CurrentRobotPose.Shoulder = posCom.Shoulder
-				controller.CurrentRobotPose.Elbow = posCom.Elbow
-				controller.CurrentRobotPose.WristAngle = posCom.WristAngle
-				controller.CurrentRobotPose.WristRotation = posCom.WristRotation
-				controller.CurrentRobotPose.Gripper = posCom.Gripper
-
-				// perform the move
-				alp := controller.CurrentRobotPose.BuildArmLinkPacket(posCom.Delta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
-
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutReset:
-				// receive the token
-				token, ok := msg.Value[0].(string)
-				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
+		// sleep if it's Ready
+		if controller.CurrentRobotState == Ready {
+			// reset CurrentRobotPose
+			controller.ResetPose()
 
-				// check if the token is valid
-				userAuth := controller.Validate(token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
+			// set the robot in sleep mode
+			controller.SleepRobot()
+		}
 
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
-				}
+		// feedback
+		return api.HandlerMessage{
+			Type: api.TypeActionPerformed,
+		}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
 
-				// wake up if sleeping
-				if controller.CurrentRobotState == Sleeping {
-					log.Println("Leubot is sleeping, waking up")
-					controller.InitRobot()
-				}
+// NewController creates a new instance of Controller
+func NewController(als *armlink.ArmLinkSerial, store *Store, mth string, ver string) *Controller {
+	hmc := make(chan api.HandlerMessage)
+	controller := Controller{
+		ArmLinkSerial:     als,
+		Constraints:       api.DefaultConstraints,
+		CurrentRobotPose:  &api.RobotPose{},
+		CurrentRobotState: Offline,
+		CurrentUser:       &api.User{},
+		Events:            make(chan api.HandlerMessage),
+		HandlerChannel:    hmc,
+		Keys:              []*api.APIKey{},
+		LastArmLinkPacket: &armlink.ArmLinkPacket{},
+		Mailer:            newMailer(),
+		Notifiers:         newNotifiers(),
+		PendingUsers:      map[string]*pendingUser{},
+		QueueChanged:      make(chan struct{}),
+		QueuePromoted:     map[string]string{},
+		ReservationTimer:  time.NewTimer(time.Second * 10),
+		Revoked:           map[string]time.Time{},
+		SoftLimits:        map[string]api.JointRange{},
+		StartTime:         time.Now(),
+		Store:             store,
+		UserActChannel:    make(chan bool),
+		UserTimer:         time.NewTimer(time.Second * 10),
+		UserTimerFinish:   make(chan bool),
+		Version:           ver,
+		Workspace:         api.DefaultWorkspace,
+		ActionSettings:    api.DefaultActionSettings,
+	}
+	controller.ResetPose()
+	controller.UserTimer.Stop()
+	controller.ReservationTimer.Stop()
+	if mth != "" {
+		controller.Keys = append(controller.Keys, newMasterKey(mth))
+	}
 
-				// perform the reset
-				alp := &armlink.ArmLinkPacket{}
-				alp.SetExtended(armlink.ExtendedReset)
-				controller.ArmLinkSerial.Send(alp.Bytes())
+	// the operators are bound to the hard limits until the admins narrow them
+	for joint, jr := range api.JointRanges {
+		controller.SoftLimits[joint] = jr
+	}
 
-				// reset CurrentRobotPose
-				controller.ResetPose()
+	// take over the state before the restart, or set the robot in sleep mode
+	controller.loadTokenSecret()
+	if !controller.restore() {
+		controller.SleepRobot()
+	}
+	controller.scheduleReservations(time.Now())
 
-				// sync with Leubot
-				alp = controller.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta)
-				controller.ArmLinkSerial.Send(alp.Bytes())
-				log.Printf("[ArmLinkPacket] %v", alp.String())
+	// notify the controller at the boundaries of the reservations
+	go func() {
+		for range controller.ReservationTimer.C {
+			controller.Events <- api.HandlerMessage{
+				Type: api.TypeReservationBoundary,
+			}
+		}
+	}()
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
-			case api.TypePutSleep:
-				// receive the token
-				token, ok := msg.Value[0].(string)
+	go func() {
+		for {
+			// the requests from the API, or the events of the controller itself that need no reply
+			var msg api.HandlerMessage
+			select {
+			case req, ok := <-hmc:
 				if !ok {
-					hmc <- api.HandlerMessage{
-						Type: api.TypeSomethingWentWrong,
-					}
-					break
-				}
-
-				// check if the token is valid
-				userAuth := controller.Validate(token)
-				if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
-					// feedback
-					hmc <- api.HandlerMessage{
-						Type: userAuth,
-					}
-					break
-				}
-
-				// ack the timer
-				if *userTimeout != 0 {
-					controller.UserActChannel <- true
+					log.Fatalln("HandlerChannel closed, dying...")
 				}
+				msg = req
+			case msg = <-controller.Events:
+			}
 
-				// sleep if it's Ready
-				if controller.CurrentRobotState == Ready {
-					// reset CurrentRobotPose
-					controller.ResetPose()
+			log.Printf("%v", controller.CurrentRobotPose.String())
+			user := *controller.CurrentUser
+			reply := controller.handle(msg)
+			controller.record(msg, reply)
 
-					// set the robot in sleep mode
-					controller.SleepRobot()
-				}
+			// record the command for the incident review
+			controller.audit(user, msg, reply)
+			controller.history(user, msg)
 
-				// feedback
-				hmc <- api.HandlerMessage{
-					Type: api.TypeActionPerformed,
-				}
+			// feedback to the one who asked only, the events need none
+			if msg.Reply != nil {
+				msg.Reply <- reply
 			}
+
+			// keep the state for the restart
+			controller.persist()
 		}
-		log.Fatalln("HandlerChannel closed, dying...")
 	}()
 
 	return &controller
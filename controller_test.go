@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// TestUserTimeoutUnderLoad checks that the user times out while the others poll the robot,
+// and that the controller loop keeps serving the requests afterwards
+func TestUserTimeoutUnderLoad(t *testing.T) {
+	// the user is kicked before the timeout is restored
+	timeout := *userTimeout
+	t.Cleanup(func() { *userTimeout = timeout })
+	*userTimeout = 1
+	_, h := newTestController(t, nil)
+	addTestUser(t, h, "alice")
+
+	// poll the posture until the user is gone
+	done := make(chan struct{})
+	wg := pollPosture(t, h, 8, done)
+
+	deadline := time.After(5 * time.Second)
+	for currentUser(t, h).Name != "" {
+		select {
+		case <-deadline:
+			close(done)
+			t.Fatal("the user did not time out")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// the next user gets the robot and moves it
+	added := make(chan string)
+	go func() { added <- addTestUser(t, h, "bob") }()
+	select {
+	case token := <-added:
+		if rec := serve(h, http.MethodPut, "/base", token, map[string]interface{}{"value": 400}); rec.Code != http.StatusAccepted {
+			t.Fatalf("PUT /base: %v %v", rec.Code, rec.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the controller loop is stuck after the timeout")
+	}
+}
+
+// TestStatus checks the probes and the status of the robot before and after a user takes it
+func TestStatus(t *testing.T) {
+	timeout := *userTimeout
+	t.Cleanup(func() { *userTimeout = timeout })
+	*userTimeout = 600
+	_, h := newTestController(t, nil)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %v: %v %v", path, rec.Code, rec.Body)
+		}
+	}
+
+	status := func() api.Status {
+		t.Helper()
+		rec := serve(h, http.MethodGet, "/status", "", nil)
+		var status api.Status
+		if err := json.NewDecoder(rec.Body).Decode(&status); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("GET /status: %v %v", rec.Code, err)
+		}
+		return status
+	}
+	if s := status(); s.State != Sleeping.String() || !s.SerialConnected || s.UserActive || s.SessionRemaining != 0 || s.Version != "v1" {
+		t.Errorf("GET /status without a user: %+v", s)
+	}
+	addTestUser(t, h, "alice")
+	if s := status(); !s.UserActive || s.SessionRemaining <= 0 || s.SessionRemaining > 600 {
+		t.Errorf("GET /status with alice: %+v", s)
+	}
+}
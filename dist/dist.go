@@ -0,0 +1,9 @@
+// Package dist bundles the static distribution of Swagger UI
+package dist
+
+import "embed"
+
+// FS holds the Swagger UI assets without the source maps
+//
+//go:embed *.css *.js *.html *.png
+var FS embed.FS
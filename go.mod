@@ -1,14 +1,32 @@
 module github.com/Interactions-HSG/leubot
 
-go 1.15
+go 1.20
 
 require (
-	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751 // indirect
-	github.com/alecthomas/units v0.0.0-20210208195552-ff826a37aa15 // indirect
 	github.com/badoux/checkmail v1.2.1
+	github.com/coreos/go-oidc/v3 v3.9.0
+	github.com/golang-jwt/jwt/v4 v4.5.1
 	github.com/gorilla/mux v1.8.0
 	github.com/jacobsa/go-serial v0.0.0-20180131005756-15cf729a72d4
-	github.com/stretchr/testify v1.7.0 // indirect
-	golang.org/x/sys v0.1.0 // indirect
+	github.com/prometheus/client_golang v1.20.5
+	go.etcd.io/bbolt v1.3.8
+	go.starlark.net v0.0.0-20230525235612-a134d8f9ddca
+	golang.org/x/oauth2 v0.21.0
+	golang.org/x/sys v0.22.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 )
+
+require (
+	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751 // indirect
+	github.com/alecthomas/units v0.0.0-20211218093645-b94a6e3cc137 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-jose/go-jose/v3 v3.0.1 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
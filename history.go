@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// historyBucket is the bucket of the history of the poses in the Store
+const historyBucket = "history"
+
+// history appends the poses sent for the command to the history with the user who was
+// using the robot, keeping the latest historySize
+func (controller *Controller) history(user api.User, msg api.HandlerMessage) {
+	samples := controller.SentPoses
+	controller.SentPoses = nil
+	if len(samples) == 0 {
+		return
+	}
+
+	// the one who started the session with the command
+	if user.Name == "" && user.Email == "" {
+		user = *controller.CurrentUser
+	}
+	var keyID string
+	if key := controller.findKey(messageToken(msg)); key != nil {
+		keyID = key.ID
+	}
+	for _, s := range samples {
+		s.Name, s.Email, s.Key = user.Name, user.Email, keyID
+		s.Command = msg.Type.String()
+		js, err := json.Marshal(s)
+		if err != nil {
+			log.Printf("[History] %v", err)
+			return
+		}
+		if err := controller.Store.Append(historyBucket, s.Time, js); err != nil {
+			log.Printf("[History] %v", err)
+			return
+		}
+	}
+	if err := controller.Store.Trim(historyBucket, *historySize); err != nil {
+		log.Printf("[History] %v", err)
+	}
+}
+
+// queryHistory returns the poses in the history matching the query
+func (controller *Controller) queryHistory(q *api.AuditQuery) []api.PoseSample {
+	samples := []api.PoseSample{}
+	err := controller.Store.Scan(historyBucket, q.From, func(js []byte) bool {
+		var s api.PoseSample
+		if err := json.Unmarshal(js, &s); err != nil {
+			log.Printf("[History] %v", err)
+			return true
+		}
+		if !q.To.IsZero() && !s.Time.Before(q.To) {
+			return false
+		}
+		if q.MatchesSample(&s) {
+			samples = append(samples, s)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("[History] %v", err)
+	}
+	return samples
+}
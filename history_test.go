@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// getHistory returns the history of the poses with the query
+func getHistory(t *testing.T, h http.Handler, query string) []api.PoseSample {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/posture/history"+query, testMasterKey, nil)
+	var samples []api.PoseSample
+	if err := json.NewDecoder(rec.Body).Decode(&samples); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /posture/history%v: %v %v", query, rec.Code, err)
+	}
+	return samples
+}
+
+func TestHistory(t *testing.T) {
+	size := *historySize
+	t.Cleanup(func() { *historySize = size })
+	*historySize = 3
+	_, h := newTestController(t, openTestStore(t))
+
+	alice := addTestUser(t, h, "alice")
+	for _, base := range []int{410, 420, 430} {
+		moveBase(h, alice, base)
+	}
+	serve(h, http.MethodDelete, "/user/"+alice, "", nil)
+	bob := addTestUser(t, h, "bob")
+	moveBase(h, bob, 440)
+
+	// only the latest are kept, waking the robot up for bob included
+	samples := getHistory(t, h, "")
+	if len(samples) != 3 {
+		t.Fatalf("GET /posture/history: %+v", samples)
+	}
+	last := samples[2]
+	if samples[0].Name != "alice" || samples[0].Pose.Base != 430 || samples[1].Command != "TypeAddUser" ||
+		last.Name != "bob" || last.Email != "bob@example.com" || last.Command != "TypePutBase" || last.Pose.Base != 440 {
+		t.Errorf("GET /posture/history: %+v", samples)
+	}
+	if bobs := getHistory(t, h, "?user=bob"); len(bobs) != 2 || bobs[1] != last {
+		t.Errorf("GET /posture/history?user=bob: %+v", bobs)
+	}
+
+	rec := serve(h, http.MethodGet, "/posture/history?format=csv&user=alice@example.com", testMasterKey, nil)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if rec.Code != http.StatusOK || err != nil || rec.Header().Get("Content-Type") != "text/csv; charset=UTF-8" {
+		t.Fatalf("GET /posture/history?format=csv: %v %v", rec.Code, err)
+	}
+	if len(rows) != 2 || strings.Join(rows[0], ",") != "time,name,email,key,command,delta,base,shoulder,elbow,wristAngle,wristRotation,gripper" ||
+		rows[1][1] != "alice" || rows[1][4] != "TypePutBase" || rows[1][6] != "430" {
+		t.Errorf("GET /posture/history?format=csv: %v", rows)
+	}
+
+	rec = serve(h, http.MethodGet, "/posture/history?format=jsonl", testMasterKey, nil)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var sample api.PoseSample
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &sample); rec.Code != http.StatusOK || len(lines) != 3 || err != nil || sample != last {
+		t.Errorf("GET /posture/history?format=jsonl: %v %v", rec.Code, rec.Body)
+	}
+}
+
+func TestHistoryWithoutStore(t *testing.T) {
+	_, h := newTestController(t, nil)
+	moveBase(h, addTestUser(t, h, "alice"), 450)
+	rec := serve(h, http.MethodGet, "/posture/history?format=csv", testMasterKey, nil)
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); rec.Code != http.StatusServiceUnavailable || err != nil || !strings.HasSuffix(p.Type, "store-unavailable") {
+		t.Errorf("GET /posture/history without the store: %v %+v", rec.Code, p)
+	}
+}
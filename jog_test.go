@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// jogJoint moves the joint by the offset and returns the value it moves to, or the problem
+func jogJoint(t *testing.T, h http.Handler, token string, joint string, offset int, clamp bool) (uint16, *api.Problem) {
+	t.Helper()
+	rec := serve(h, http.MethodPut, "/"+joint, token, map[string]interface{}{"offset": offset, "clamp": clamp})
+	if rec.Code == http.StatusAccepted {
+		var jm api.JointMotion
+		if err := json.NewDecoder(rec.Body).Decode(&jm); err != nil {
+			t.Fatalf("PUT /%v: %v", joint, err)
+		}
+		return jm.Value, nil
+	}
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("PUT /%v: %v %v", joint, rec.Code, err)
+	}
+	return 0, &p
+}
+
+// patchPosture moves the joints by the offsets in the body and returns where they move to, or the problem
+func patchPosture(t *testing.T, h http.Handler, token string, body map[string]interface{}) (api.PoseMotion, *api.Problem) {
+	t.Helper()
+	rec := serve(h, http.MethodPatch, "/posture", token, body)
+	if rec.Code == http.StatusAccepted {
+		var pm api.PoseMotion
+		if err := json.NewDecoder(rec.Body).Decode(&pm); err != nil {
+			t.Fatalf("PATCH /posture: %v", err)
+		}
+		return pm, nil
+	}
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("PATCH /posture: %v %v", rec.Code, err)
+	}
+	return api.PoseMotion{}, &p
+}
+
+func TestJog(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+
+	// the robot sleeps at first, the jog starts from the home pose it wakes up at
+	if v, p := jogJoint(t, h, token, "base", -10, false); p != nil || v != homePose.Base-10 {
+		t.Fatalf("PUT /base by -10 from the sleep: %v %+v, want %v", v, p, homePose.Base-10)
+	}
+	if rp := currentPose(t, h); rp.Base != homePose.Base-10 || rp.Shoulder != homePose.Shoulder {
+		t.Errorf("the posture after the jog: %v", rp.String())
+	}
+
+	// beyond the soft limits, refused or clamped
+	shoulder := api.JointRanges["shoulder"]
+	if _, p := jogJoint(t, h, token, "shoulder", -1000, false); p == nil || p.Field != "shoulder" {
+		t.Errorf("PUT /shoulder beyond the limits: %+v", p)
+	}
+	if rp := currentPose(t, h); rp.Shoulder != homePose.Shoulder {
+		t.Errorf("the shoulder moved on a refused jog: %v", rp.Shoulder)
+	}
+	if v, p := jogJoint(t, h, token, "shoulder", -1000, true); p != nil || v != shoulder.Min {
+		t.Errorf("PUT /shoulder clamped: %v %+v, want %v", v, p, shoulder.Min)
+	}
+
+	// beyond the profile of the user, refused or clamped
+	admin := addTestKey(t, h, "admin", api.RoleAdmin)
+	narrow := api.SafetyProfile{Limits: map[string]api.JointRange{"elbow": {Min: 300, Max: 500}}, Users: []string{"alice@example.com"}}
+	if rec := serve(h, http.MethodPut, "/profiles/narrow", admin.Token, narrow); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /profiles/narrow: %v %v", rec.Code, rec.Body)
+	}
+	if _, p := jogJoint(t, h, token, "elbow", 200, false); p == nil || p.Profile != "narrow" {
+		t.Errorf("PUT /elbow beyond the profile: %+v", p)
+	}
+	if v, p := jogJoint(t, h, token, "elbow", 200, true); p != nil || v != 500 {
+		t.Errorf("PUT /elbow clamped to the profile: %v %+v, want 500", v, p)
+	}
+}
+
+func TestPatchPosture(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+
+	// the robot sleeps at first, the jog starts from the home pose it wakes up at
+	pm, p := patchPosture(t, h, token, map[string]interface{}{"base": 20, "gripper": -28, "delta": 50})
+	want := homePose
+	want.Base += 20
+	want.Gripper -= 28
+	if p != nil || pm.RobotPose != want || pm.Delta != 50 {
+		t.Fatalf("PATCH /posture from the sleep: %+v %+v, want %v with the delta 50", pm, p, want.String())
+	}
+
+	// all or nothing: a joint beyond its limits refuses the whole jog
+	before := currentPose(t, h)
+	if _, p := patchPosture(t, h, token, map[string]interface{}{"base": 10, "elbow": 1000}); p == nil || p.Field != "elbow" {
+		t.Errorf("PATCH /posture with the elbow beyond the limits: %+v", p)
+	}
+	if rp := currentPose(t, h); rp != before {
+		t.Errorf("the joints moved on a refused jog: %v, want %v", rp.String(), before.String())
+	}
+
+	// clamped at the soft limits and at the profile
+	admin := addTestKey(t, h, "admin", api.RoleAdmin)
+	narrow := api.SafetyProfile{Limits: map[string]api.JointRange{"wristAngle": {Min: 500, Max: 600}}, Users: []string{"alice@example.com"}}
+	if rec := serve(h, http.MethodPut, "/profiles/narrow", admin.Token, narrow); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /profiles/narrow: %v %v", rec.Code, rec.Body)
+	}
+	if _, p := patchPosture(t, h, token, map[string]interface{}{"base": 2000}); p == nil || p.Field != "base" {
+		t.Errorf("PATCH /posture beyond the soft limits: %+v", p)
+	}
+	if _, p := patchPosture(t, h, token, map[string]interface{}{"wristAngle": -100}); p == nil || p.Profile != "narrow" {
+		t.Errorf("PATCH /posture beyond the profile: %+v", p)
+	}
+	pm, p = patchPosture(t, h, token, map[string]interface{}{"base": 2000, "wristAngle": -100, "clamp": true})
+	if p != nil || pm.Base != api.JointRanges["base"].Max || pm.WristAngle != 500 || pm.Elbow != before.Elbow {
+		t.Errorf("PATCH /posture clamped: %+v %+v", pm, p)
+	}
+}
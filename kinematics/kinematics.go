@@ -0,0 +1,122 @@
+// Package kinematics models the geometry of the PhantomX AX-12 Reactor Robot Arm
+// in millimeters, with the origin at the center of the base on the table,
+// x to the front, y to the left and z up.
+package kinematics
+
+import (
+	"fmt"
+	"math"
+)
+
+// The lengths of the links of the Reactor Arm in mm
+const (
+	BaseHeight   = 95.0  // from the table to the shoulder axis
+	BaseRadius   = 60.0  // of the base housing the base servo
+	UpperArm     = 146.0 // from the shoulder to the elbow axis
+	Forearm      = 187.0 // from the elbow to the wrist axis
+	Hand         = 86.0  // from the wrist axis to the tip of the gripper
+	TicksPerTurn = 1024.0 / 300.0 * 360.0
+)
+
+// Point is a position in mm
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// String returns a string rep for the p
+func (p Point) String() string {
+	return fmt.Sprintf("(%.0f, %.0f, %.0f)", p.X, p.Y, p.Z)
+}
+
+// Lerp returns the point at t in [0, 1] on the way from p to q
+func (p Point) Lerp(q Point, t float64) Point {
+	return Point{p.X + (q.X-p.X)*t, p.Y + (q.Y-p.Y)*t, p.Z + (q.Z-p.Z)*t}
+}
+
+// Distance returns the distance between p and q
+func (p Point) Distance(q Point) float64 {
+	return math.Sqrt((p.X-q.X)*(p.X-q.X) + (p.Y-q.Y)*(p.Y-q.Y) + (p.Z-q.Z)*(p.Z-q.Z))
+}
+
+// Link is a straight segment of the arm between two joints
+type Link struct {
+	Name string
+	From Point
+	To   Point
+}
+
+// Angle returns the angle in radians of the AX-12 servo at the position, 512 being 0
+func Angle(position uint16) float64 {
+	return (float64(position) - 512) / TicksPerTurn * 2 * math.Pi
+}
+
+// Forward returns the links of the arm for the positions of the servos, from the base to the
+// tip of the gripper; the shoulder tilts the upper arm from the vertical, and the elbow and
+// the wrist angle bend the next link relative to the previous one, the elbow at 512 being square
+func Forward(base, shoulder, elbow, wristAngle uint16) []Link {
+	yaw := Angle(base)
+	// the pitches of the links from the vertical, forward is positive
+	upperArm := -Angle(shoulder)
+	forearm := upperArm + math.Pi/2 + Angle(elbow)
+	hand := forearm + Angle(wristAngle)
+
+	at := func(from Point, pitch float64, length float64) Point {
+		r := length * math.Sin(pitch)
+		return Point{
+			X: from.X + r*math.Cos(yaw),
+			Y: from.Y + r*math.Sin(yaw),
+			Z: from.Z + length*math.Cos(pitch),
+		}
+	}
+	shoulderAxis := Point{0, 0, BaseHeight}
+	elbowAxis := at(shoulderAxis, upperArm, UpperArm)
+	wristAxis := at(elbowAxis, forearm, Forearm)
+	tip := at(wristAxis, hand, Hand)
+	return []Link{
+		{"upperArm", shoulderAxis, elbowAxis},
+		{"forearm", elbowAxis, wristAxis},
+		{"hand", wristAxis, tip},
+	}
+}
+
+// Down is the pitch of the hand pointing straight down at the table
+const Down = math.Pi
+
+// Position returns the position of the AX-12 servo at the angle in radians, the inverse of Angle
+func Position(angle float64) (uint16, error) {
+	p := math.Round(512 + angle/(2*math.Pi)*TicksPerTurn)
+	if p < 0 || p > 1023 {
+		return 0, fmt.Errorf("the angle %.0f° is beyond the servo", angle*180/math.Pi)
+	}
+	return uint16(p), nil
+}
+
+// Inverse returns the positions of the servos putting the tip of the gripper at the point with the
+// hand at the pitch from the vertical, the inverse of Forward with the elbow above the wrist
+func Inverse(tip Point, pitch float64) (base, shoulder, elbow, wristAngle uint16, err error) {
+	yaw := math.Atan2(tip.Y, tip.X)
+	// the wrist axis in the vertical plane of the arm, from the shoulder axis
+	r := math.Hypot(tip.X, tip.Y) - Hand*math.Sin(pitch)
+	z := tip.Z - Hand*math.Cos(pitch) - BaseHeight
+	d := math.Hypot(r, z)
+	if d > UpperArm+Forearm || d < math.Abs(UpperArm-Forearm) {
+		return 0, 0, 0, 0, fmt.Errorf("%v is out of reach", tip)
+	}
+	// the angle between the upper arm and the line from the shoulder to the wrist
+	a := math.Acos((UpperArm*UpperArm + d*d - Forearm*Forearm) / (2 * UpperArm * d))
+	upperArm := math.Atan2(r, z) - a
+	forearm := math.Atan2(r-UpperArm*math.Sin(upperArm), z-UpperArm*math.Cos(upperArm))
+
+	angles := []float64{yaw, -upperArm, forearm - upperArm - math.Pi/2, pitch - forearm}
+	positions := make([]uint16, len(angles))
+	for i, angle := range angles {
+		// within a turn around 0
+		angle = math.Remainder(angle, 2*math.Pi)
+		if positions[i], err = Position(angle); err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("%v is out of reach: %v", tip, err)
+		}
+	}
+	return positions[0], positions[1], positions[2], positions[3], nil
+}
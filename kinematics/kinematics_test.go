@@ -0,0 +1,69 @@
+package kinematics
+
+import (
+	"math"
+	"testing"
+)
+
+// near checks if the points are within a mm
+func near(p, q Point) bool {
+	return p.Distance(q) < 1
+}
+
+func TestForward(t *testing.T) {
+	for _, tc := range []struct {
+		base, shoulder, elbow, wristAngle uint16
+		elbowAxis, wristAxis, tip         Point
+	}{
+		// the upper arm upright, the forearm and the hand to the front
+		{512, 512, 512, 512, Point{0, 0, 241}, Point{187, 0, 241}, Point{273, 0, 241}},
+		// turned to the left, the hand pointing down
+		{819, 512, 512, 819, Point{0, 0, 241}, Point{0, 187, 241}, Point{0, 187, 155}},
+		// the arm stretched out to the front
+		{512, 205, 205, 512, Point{146, 0, 95}, Point{333, 0, 95}, Point{419, 0, 95}},
+	} {
+		links := Forward(tc.base, tc.shoulder, tc.elbow, tc.wristAngle)
+		if len(links) != 3 || links[0].From != (Point{0, 0, BaseHeight}) {
+			t.Fatalf("Forward(%v, %v, %v, %v) = %v", tc.base, tc.shoulder, tc.elbow, tc.wristAngle, links)
+		}
+		for i, want := range []Point{tc.elbowAxis, tc.wristAxis, tc.tip} {
+			if !near(links[i].To, want) || (i > 0 && links[i].From != links[i-1].To) {
+				t.Errorf("Forward(%v, %v, %v, %v): the %v ends at %v, want %v", tc.base, tc.shoulder, tc.elbow, tc.wristAngle, links[i].Name, links[i].To, want)
+			}
+		}
+	}
+}
+
+func TestInverse(t *testing.T) {
+	for _, tip := range []Point{{200, 50, 40}, {150, -100, 0}, {250, 0, 150}, {0, 220, 60}} {
+		base, shoulder, elbow, wristAngle, err := Inverse(tip, Down)
+		if err != nil {
+			t.Errorf("Inverse(%v): %v", tip, err)
+			continue
+		}
+		links := Forward(base, shoulder, elbow, wristAngle)
+		// within the rounding of the positions
+		if got := links[2].To; got.Distance(tip) > 3 {
+			t.Errorf("Forward(Inverse(%v)) = %v", tip, got)
+		}
+		if hand := links[2]; math.Abs(hand.From.X-hand.To.X) > 2 || math.Abs(hand.From.Y-hand.To.Y) > 2 {
+			t.Errorf("Inverse(%v): the hand points from %v to %v, want down", tip, hand.From, hand.To)
+		}
+	}
+	for _, tip := range []Point{{500, 0, 0}, {0, 0, 500}} {
+		if _, _, _, _, err := Inverse(tip, Down); err == nil {
+			t.Errorf("Inverse(%v) reaches it", tip)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	for _, position := range []uint16{0, 205, 512, 819, 1023} {
+		if p, err := Position(Angle(position)); err != nil || p != position {
+			t.Errorf("Position(Angle(%v)) = %v, %v", position, p, err)
+		}
+	}
+	if _, err := Position(math.Pi); err == nil {
+		t.Error("Position(π) is within the servo")
+	}
+}
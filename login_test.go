@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testProvider stands in for an OpenID Connect provider, issuing the ID token
+// with the claims given for the code
+type testProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	claims map[string]jwt.MapClaims
+}
+
+// newTestProvider serves the discovery, the keys and the token endpoint of the provider
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := &testProvider{key: key, claims: map[string]jwt.MapClaims{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                tp.URL,
+			"authorization_endpoint":                tp.URL + "/auth",
+			"token_endpoint":                        tp.URL + "/token",
+			"jwks_uri":                              tp.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   b64(key.N.Bytes()),
+				"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tp.mu.Lock()
+		claims, ok := tp.claims[r.FormValue("code")]
+		tp.mu.Unlock()
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = "test"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+	tp.Server = httptest.NewServer(mux)
+	t.Cleanup(tp.Close)
+	return tp
+}
+
+// issue lets the code be exchanged for the ID token of the email with the nonce
+func (tp *testProvider) issue(code string, email string, verified bool, nonce string) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.claims[code] = jwt.MapClaims{
+		"iss":            tp.URL,
+		"aud":            "leubot",
+		"sub":            email,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"nonce":          nonce,
+		"name":           "Alice Liddell",
+		"email":          email,
+		"email_verified": verified,
+	}
+}
+
+// startLogin opens the login and returns the state cookie, the state and the nonce sent to the provider
+func startLogin(t *testing.T, h http.Handler) (*http.Cookie, string, string) {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/login", "", nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("GET /login: %v %v", rec.Code, rec.Body)
+	}
+	auth, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("GET /login set the cookies %v", cookies)
+	}
+	return cookies[0], auth.Query().Get("state"), auth.Query().Get("nonce")
+}
+
+// loginCallback returns from the provider with the code and the state
+func loginCallback(h http.Handler, cookie *http.Cookie, code string, state string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/leubot/v1/login/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLogin(t *testing.T) {
+	tp := newTestProvider(t)
+	_, h := newTestController(t, nil)
+	oc, err := api.NewOIDCConfig(context.Background(), tp.URL, "leubot", "secret", "http://localhost/leubot/v1/login/callback", []string{"example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.OIDC = oc
+	t.Cleanup(func() { api.OIDC = nil })
+
+	// a valid code returned with another state than the one of the login
+	cookie, state, nonce := startLogin(t, h)
+	tp.issue("state", "alice@example.com", true, nonce)
+	if rec := loginCallback(h, cookie, "state", "forged"); rec.Code != http.StatusBadRequest {
+		t.Errorf("the callback with another state: %v, want 400", rec.Code)
+	}
+	cookie, state, _ = startLogin(t, h)
+	tp.issue("nonce", "alice@example.com", true, "replayed")
+	if rec := loginCallback(h, cookie, "nonce", state); rec.Code != http.StatusUnauthorized {
+		t.Errorf("the ID token with another nonce: %v, want 401", rec.Code)
+	}
+	cookie, state, nonce = startLogin(t, h)
+	tp.issue("unverified", "alice@example.com", false, nonce)
+	if rec := loginCallback(h, cookie, "unverified", state); rec.Code != http.StatusForbidden {
+		t.Errorf("the unverified email: %v, want 403", rec.Code)
+	}
+	cookie, state, nonce = startLogin(t, h)
+	tp.issue("domain", "alice@example.org", true, nonce)
+	if rec := loginCallback(h, cookie, "domain", state); rec.Code != http.StatusForbidden {
+		t.Errorf("the email at another domain: %v, want 403", rec.Code)
+	}
+	if user := currentUser(t, h); user != (api.UserInfo{}) {
+		t.Fatalf("%v was added by a failed login", user)
+	}
+
+	// the user is added with the claims of the provider
+	cookie, state, nonce = startLogin(t, h)
+	tp.issue("ok", "alice@example.com", true, nonce)
+	rec := loginCallback(h, cookie, "ok", state)
+	var token api.Token
+	if err := json.NewDecoder(rec.Body).Decode(&token); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("the login: %v %v", rec.Code, err)
+	}
+	if user := currentUser(t, h); user.Name != "Alice Liddell" || user.Email != "alice@example.com" {
+		t.Errorf("the user added by the login: %+v", user)
+	}
+	if code := moveBase(h, token.Token, 450); code != http.StatusAccepted {
+		t.Errorf("PUT /base with the token of the login: %v", code)
+	}
+}
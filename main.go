@@ -7,16 +7,24 @@
  * Contact: iori.mizutani@unisg.ch
  */
 
+//go:generate go run ./cmd/leubot-openapi --out openapi.json
+
 package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/Interactions-HSG/leubot/api"
 	"github.com/Interactions-HSG/leubot/armlink"
@@ -25,22 +33,49 @@ import (
 
 // Environmental variables
 var (
-	app             = kingpin.New("leubot", "Provide a Web API for the PhantomX AX-12 Reactor Robot Arm.")
-	apiHost         = app.Flag("apiHost", "The hostname for the API.").Default("api.interactions.ics.unisg.ch").String()
-	apiPath         = app.Flag("apiPath", "The name for the path.").Default("leubot").String()
-	apiProto        = app.Flag("apiProto", "The protocol for the API.").Default("https://").String()
-	apiVersion      = app.Flag("apiVersion", "The custom API version for the API.").Default("").String()
-	defaultDelta    = app.Flag("defaultDelta", "The default value for displacement delta.").Default("128").Uint8()
-	masterToken     = app.Flag("masterToken", "The master token for debug.").Default("sometoken").String()
-	miioEnabled     = app.Flag("miioEnabled", "Enable Xiaomi yeelight device.").Default("false").Bool()
-	miiocliPath     = app.Flag("miiocliPath", "The path to miio cli.").Default("/opt/bin/miiocli").String()
-	miioToken       = app.Flag("miioToken", "The token for Xiaomi yeelight device.").Default("0000000000000000000000000000").String()
-	miioIP          = app.Flag("miioIP", "The IP address for Xiaomi yeelight device.").Default("192.168.1.2").String()
-	serverIP        = app.Flag("ip", "The IP address of the Leubot server.").Default("172.0.0.1").String()
-	serverPort      = app.Flag("port", "The serving port of the Leubot server.").Default("6789").String()
-	slackAppEnabled = app.Flag("slackAppEnabled", "Enable Slack app for user previleges.").Default("false").Bool()
-	slackWebHookURL = app.Flag("slackWebHookURL", "The webhook url for posting the json payloads.").Default("https://hooks.slack.com/services/...").String()
-	userTimeout     = app.Flag("userTimeout", "The timeout duration for users in seconds.").Default("900").Int()
+	app                   = kingpin.New("leubot", "Provide a Web API for the PhantomX AX-12 Reactor Robot Arm.")
+	apiHost               = app.Flag("apiHost", "The hostname for the API.").Default("api.interactions.ics.unisg.ch").String()
+	apiPath               = app.Flag("apiPath", "The name for the path.").Default("leubot").String()
+	apiProto              = app.Flag("apiProto", "The protocol for the API.").Default("https://").String()
+	apiVersion            = app.Flag("apiVersion", "The custom API version for the API.").Default("").String()
+	callbackHost          = app.Flag("callbackHost", "The host allowed in the callback URLs of the users waiting in the queue, repeat for more; any if none.").Strings()
+	defaultDelta          = app.Flag("defaultDelta", "The default value for displacement delta.").Default("128").Uint8()
+	masterToken           = app.Flag("masterToken", "The master token in plaintext, deprecated in favor of --masterTokenHash.").Default("").String()
+	masterTokenHash       = app.Flag("masterTokenHash", "The SHA-256 in hex of the master token, an admin key which cannot be revoked.").Default("").String()
+	historySize           = app.Flag("historySize", "The number of the poses commanded to the robot kept in the history in the store, the oldest are dropped.").Default("100000").Int()
+	miioEnabled           = app.Flag("miioEnabled", "Enable Xiaomi yeelight device.").Default("false").Bool()
+	miiocliPath           = app.Flag("miiocliPath", "The path to miio cli.").Default("/opt/bin/miiocli").String()
+	miioToken             = app.Flag("miioToken", "The token for Xiaomi yeelight device.").Default("0000000000000000000000000000").String()
+	miioIP                = app.Flag("miioIP", "The IP address for Xiaomi yeelight device.").Default("192.168.1.2").String()
+	notifyWebhookURL      = app.Flag("notifyWebhookURL", "The webhook url notified with {\"name\", \"email\", \"text\"} when a user is removed by an admin.").Default("").String()
+	oidcClientID          = app.Flag("oidcClientID", "The client ID at the OpenID Connect provider.").Default("leubot").String()
+	oidcClientSecret      = app.Flag("oidcClientSecret", "The client secret at the OpenID Connect provider.").Default("").Envar("LEUBOT_OIDC_CLIENT_SECRET").String()
+	oidcDomain            = app.Flag("oidcDomain", "The email domain allowed to log in, repeat for more; any if none.").Strings()
+	oidcIssuer            = app.Flag("oidcIssuer", "The issuer URL of the OpenID Connect provider to log in with, empty to disable the login.").Default("").String()
+	oidcRedirectURL       = app.Flag("oidcRedirectURL", "The URL of the login callback registered at the provider, derived from the API URL if empty.").Default("").String()
+	oidcRequired          = app.Flag("oidcRequired", "Refuse adding users without logging in with the OpenID Connect provider.").Default("false").Bool()
+	reservationLead       = app.Flag("reservationLeadMinutes", "How many minutes ahead the reservations of the non-admins must start, not to take the robot from the current user at once.").Default("5").Int()
+	reservationMaxMinutes = app.Flag("reservationMaxMinutes", "The maximum length of a reservation in minutes.").Default("60").Int()
+	reservationQuota      = app.Flag("reservationQuotaMinutes", "The maximum total length of the upcoming reservations of a user in minutes, 0 for no limit.").Default("180").Int()
+	serverIP              = app.Flag("ip", "The IP address of the Leubot server.").Default("172.0.0.1").String()
+	serverPort            = app.Flag("port", "The serving port of the Leubot server.").Default("6789").String()
+	slackAppEnabled       = app.Flag("slackAppEnabled", "Enable Slack app for user previleges.").Default("false").Bool()
+	slackWebHookURL       = app.Flag("slackWebHookURL", "The webhook url for posting the json payloads.").Default("https://hooks.slack.com/services/...").String()
+	smtpAddr              = app.Flag("smtpAddr", "The host:port of the SMTP relay to email the users, empty not to email.").Default("").String()
+	smtpFrom              = app.Flag("smtpFrom", "The sender address of the emails.").Default("leubot@interactions.ics.unisg.ch").String()
+	smtpPassword          = app.Flag("smtpPassword", "The password for the SMTP relay.").Default("").Envar("LEUBOT_SMTP_PASSWORD").String()
+	smtpUser              = app.Flag("smtpUser", "The user for the SMTP relay, empty not to authenticate.").Default("").String()
+	storePath             = app.Flag("storePath", "The BoltDB file to keep the session, the pose, the keys and the reservations across restarts, empty not to keep them.").Default("leubot.db").String()
+	tokenTTL              = app.Flag("tokenTTL", "The lifetime of the session tokens in seconds, until they are refreshed.").Default("3600").Int()
+	userQueue             = app.Flag("userQueue", "Let users wait in a queue while the robot is used instead of rejecting them.").Default("true").Bool()
+	userTimeout           = app.Flag("userTimeout", "The timeout duration for users in seconds.").Default("900").Int()
+	verifyEmail           = app.Flag("verifyEmail", "Email the link to start the session instead of responding with the token, requires --smtpAddr.").Default("false").Bool()
+
+	serveCmd  = app.Command("serve", "Serve the API for the robot.").Default()
+	runCmd    = app.Command("run", "Run a Starlark motion script against a simulated arm to validate it offline.")
+	runScript = runCmd.Arg("script", "The file of the script.").Required().ExistingFile()
+	runRole   = runCmd.Flag("role", "The role to run the script with, bound to the limits, the profiles, the workspace and the constraints in --storePath.").Default(string(api.RoleOperator)).Enum(string(api.RoleObserver), string(api.RoleOperator), string(api.RoleAdmin))
+	runEmail  = runCmd.Flag("email", "The email to run the script for, to apply the safety profile of the user.").String()
 )
 
 // postToSlack posts the status to Slack if slackAppEnabled
@@ -74,7 +109,7 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 	// parse the options
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	command := kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	// set the version
 	var version string
@@ -88,16 +123,77 @@ func main() {
 	}
 	app.Version(version)
 
+	if command == runCmd.FullCommand() {
+		// keep the output for the commands of the script
+		log.SetOutput(io.Discard)
+		// check against the envelope kept by Leubot if any
+		var store *Store
+		if _, err := os.Stat(*storePath); *storePath != "" && err == nil {
+			if store, err = OpenStoreReadOnly(*storePath); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open the store at %v, stop Leubot or copy it: %v\n", *storePath, err)
+				os.Exit(1)
+			}
+			defer store.Close()
+		}
+		if err := runFile(*runScript, os.Stdout, version, store, api.Role(*runRole), *runEmail); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Printf("Leubot (%v) started", version)
 
+	if *verifyEmail && *smtpAddr == "" {
+		log.Fatal("--verifyEmail requires --smtpAddr to send the emails")
+	}
+
 	// initialize ArmLink serial interface to control the robot
 	als := armlink.NewArmLinkSerial()
 	defer als.Close()
 
+	// open the store to keep the state across restarts
+	var store *Store
+	if *storePath != "" {
+		var err error
+		if store, err = OpenStore(*storePath); err != nil {
+			log.Printf("[Store] Not persisting the state, the audit log or the history: %v", err)
+		}
+		defer store.Close()
+	}
+
+	// keep only the hash of the master token
+	mth := strings.ToLower(*masterTokenHash)
+	if mth == "" && *masterToken != "" {
+		log.Println("[Key] --masterToken is deprecated, pass its SHA-256 in --masterTokenHash instead")
+		mth = api.HashToken(*masterToken)
+	}
+	if mth != "" {
+		if b, err := hex.DecodeString(mth); err != nil || len(b) != sha256.Size {
+			log.Fatalf("[Key] --masterTokenHash must be a SHA-256 in hex: %v", mth)
+		}
+	}
+	api.TokenTTL = time.Duration(*tokenTTL) * time.Second
+
 	// create the controller with the serial
-	controller := NewController(als, *masterToken, version)
+	controller := NewController(als, store, mth, version)
 	defer controller.Shutdown()
 
 	router := api.NewRouter(*apiHost, *apiPath, *apiProto, controller.HandlerChannel, version)
+
+	// let the users log in with the OpenID Connect provider
+	if *oidcIssuer != "" {
+		redirectURL := *oidcRedirectURL
+		if redirectURL == "" {
+			redirectURL = api.APIProto + api.APIHost + api.APIBasePath + "/login/callback"
+		}
+		oc, err := api.NewOIDCConfig(context.Background(), *oidcIssuer, *oidcClientID, *oidcClientSecret, redirectURL, *oidcDomain)
+		if err != nil {
+			log.Fatalf("[Login] %v", err)
+		}
+		oc.Required = *oidcRequired
+		api.OIDC = oc
+		log.Printf("[Login] Logging in at %v, redirected to %v", *oidcIssuer, redirectURL)
+	}
 	log.Fatal(http.ListenAndServe(fmt.Sprintf("%v:%v", *serverIP, *serverPort), router))
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/armlink"
+)
+
+// testMasterKey is the master key of the test controllers
+const testMasterKey = "master"
+
+// TestMain parses the flags with their defaults, keeping nothing on the disk
+func TestMain(m *testing.M) {
+	if _, err := app.Parse([]string{"--storePath="}); err != nil {
+		log.Fatalln(err)
+	}
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestController returns a controller on the simulated arm with the store if any,
+// and the router of the API in front of it; the user left is kicked at the end of the test
+func newTestController(t *testing.T, store *Store) (*Controller, http.Handler) {
+	t.Helper()
+	controller := NewController(armlink.NewSimulatedArmLinkSerial(), store, api.HashToken(testMasterKey), "v1")
+	h := api.NewRouter("localhost", "leubot", "http://", controller.HandlerChannel, "v1")
+	t.Cleanup(func() { serve(h, http.MethodDelete, "/user", testMasterKey, nil) })
+	return controller, h
+}
+
+// openTestStore opens a store in the temporary directory of the test
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := OpenStore(t.TempDir() + "/leubot.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// serve sends the request with the body in JSON if any and the token in X-API-Key if any
+func serve(h http.Handler, method string, path string, token string, body interface{}) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != nil {
+		js, _ := json.Marshal(body)
+		r = bytes.NewReader(js)
+	}
+	req := httptest.NewRequest(method, "/leubot/v1"+path, r)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-API-Key", token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// addTestUser adds the user and returns the token
+func addTestUser(t *testing.T, h http.Handler, name string) string {
+	t.Helper()
+	rec := serve(h, http.MethodPost, "/user", "", api.UserRequest{UserInfo: api.UserInfo{Name: name, Email: name + "@example.com"}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /user: %v %v", rec.Code, rec.Body)
+	}
+	var token api.Token
+	if err := json.NewDecoder(rec.Body).Decode(&token); err != nil {
+		t.Fatal(err)
+	}
+	return token.Token
+}
+
+// currentUser returns the user of the robot, empty if there's none
+func currentUser(t *testing.T, h http.Handler) api.UserInfo {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/user", "", nil)
+	var userInfo api.UserInfo
+	if err := json.NewDecoder(rec.Body).Decode(&userInfo); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /user: %v %v", rec.Code, err)
+	}
+	return userInfo
+}
+
+// pollPosture polls the posture from the goroutines until done is closed
+func pollPosture(t *testing.T, h http.Handler, n int, done chan struct{}) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if rec := serve(h, http.MethodGet, "/posture", "", nil); rec.Code != http.StatusOK {
+					t.Errorf("GET /posture: %v %v", rec.Code, rec.Body)
+					return
+				}
+				// leave the others a turn on a single CPU, the pollers and the controller hand over to each other
+				runtime.Gosched()
+			}
+		}()
+	}
+	return &wg
+}
+
+// currentPose returns the posture of the robot
+func currentPose(t *testing.T, h http.Handler) api.RobotPose {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/posture", "", nil)
+	var rp api.RobotPose
+	if err := json.NewDecoder(rec.Body).Decode(&rp); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /posture: %v %v", rec.Code, err)
+	}
+	return rp
+}
+
+// postureCommand returns the command moving to the pose
+func postureCommand(rp api.RobotPose) api.PostureCommand {
+	return api.PostureCommand{Base: rp.Base, Shoulder: rp.Shoulder, Elbow: rp.Elbow, WristAngle: rp.WristAngle, WristRotation: rp.WristRotation, Gripper: rp.Gripper, Delta: 1}
+}
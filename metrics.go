@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// packetsSent counts the ArmLinkPackets sent to the robot
+	packetsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "leubot_armlink_packets_sent_total",
+		Help: "The number of ArmLink packets sent to the robot.",
+	})
+
+	// serialErrors counts the failed writes to the serial
+	serialErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "leubot_serial_errors_total",
+		Help: "The number of failed writes to the ArmLink serial.",
+	})
+
+	// sessionsStarted counts the users who started using the robot
+	sessionsStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "leubot_user_sessions_started_total",
+		Help: "The number of user sessions started.",
+	})
+
+	// sessionsEnded counts the users who stopped using the robot by the reason
+	sessionsEnded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "leubot_user_sessions_ended_total",
+		Help: "The number of user sessions ended, by the reason (deleted or timeout).",
+	}, []string{"reason"})
+
+	// jointTravel accumulates the distance commanded to each joint
+	jointTravel = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "leubot_joint_travel_ticks_total",
+		Help: "The distance in servo ticks commanded to each joint.",
+	}, []string{"joint"})
+
+	// robotStateClock accounts the time spent in each RobotState
+	robotStateClock = newStateClock()
+)
+
+// stateClock accumulates the time spent in each RobotState
+type stateClock struct {
+	mu    sync.Mutex
+	state RobotState
+	since time.Time
+	spent map[RobotState]time.Duration
+}
+
+// newStateClock creates a stateClock starting in Offline and registers its metrics
+func newStateClock() *stateClock {
+	sc := &stateClock{
+		state: Offline,
+		since: time.Now(),
+		spent: map[RobotState]time.Duration{},
+	}
+	for _, state := range []RobotState{Offline, Ready, Busy, Sleeping, Stopped} {
+		state := state
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "leubot_robot_state_seconds_total",
+			Help:        "The time spent in each robot state.",
+			ConstLabels: prometheus.Labels{"state": state.String()},
+		}, func() float64 {
+			return sc.Seconds(state)
+		})
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "leubot_robot_state",
+			Help:        "Whether the robot is in the state (1) or not (0).",
+			ConstLabels: prometheus.Labels{"state": state.String()},
+		}, func() float64 {
+			if sc.Current() == state {
+				return 1
+			}
+			return 0
+		})
+	}
+	return sc
+}
+
+// Set switches the clock to the state
+func (sc *stateClock) Set(state RobotState) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	now := time.Now()
+	sc.spent[sc.state] += now.Sub(sc.since)
+	sc.state = state
+	sc.since = now
+}
+
+// Current returns the current state
+func (sc *stateClock) Current() RobotState {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.state
+}
+
+// Seconds returns the total time spent in the state including the ongoing one
+func (sc *stateClock) Seconds(state RobotState) float64 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	spent := sc.spent[state]
+	if sc.state == state {
+		spent += time.Since(sc.since)
+	}
+	return spent.Seconds()
+}
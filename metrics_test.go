@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrape reads the value of the metric in /metrics, 0 if it is not exported yet
+func scrape(t *testing.T, h http.Handler, metric string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: %v %v", rec.Code, rec.Body)
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), metric+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("%v: %v", metric, err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	_, h := newTestController(t, nil)
+	requests := `leubot_http_requests_total{code="202",method="PUT",route="/base"}`
+	before := map[string]float64{}
+	for _, metric := range []string{requests, "leubot_armlink_packets_sent_total", "leubot_user_sessions_started_total"} {
+		before[metric] = scrape(t, h, metric)
+	}
+
+	token := addTestUser(t, h, "alice")
+	if code := moveBase(h, token, 400); code != http.StatusAccepted {
+		t.Fatalf("PUT /base: %v", code)
+	}
+
+	for metric, at := range before {
+		if v := scrape(t, h, metric); v <= at {
+			t.Errorf("%v = %v after the move, %v before", metric, v, at)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// Notifier delivers a message to or about a user
+type Notifier interface {
+	Notify(user api.User, text string) error
+}
+
+// slackNotifier posts the messages to the Slack webhook
+type slackNotifier struct {
+	url string
+}
+
+// Notify posts the message mentioning the user to Slack
+func (sn *slackNotifier) Notify(user api.User, text string) error {
+	js, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("User %v (%v): %v", user.Name, user.Email, text),
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(sn.url, js)
+}
+
+// webhookNotifier posts the messages as JSON to a generic webhook
+type webhookNotifier struct {
+	url string
+}
+
+// Notify posts the message with the user to the webhook
+func (wn *webhookNotifier) Notify(user api.User, text string) error {
+	js, err := json.Marshal(map[string]string{
+		"name":  user.Name,
+		"email": user.Email,
+		"text":  text,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(wn.url, js)
+}
+
+// emailNotifier sends the messages to the users through the SMTP relay
+type emailNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// Notify emails the message to the user
+func (en *emailNotifier) Notify(user api.User, text string) error {
+	msg := fmt.Sprintf("From: Leubot <%v>\r\nTo: %v\r\nSubject: Leubot\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%v\r\n", en.from, user.Email, text)
+	return smtp.SendMail(en.addr, en.auth, en.from, []string{user.Email}, []byte(msg))
+}
+
+// postJSON posts the JSON payload, failing on the error status
+func postJSON(url string, js []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	r, err := client.Post(url, "application/json", bytes.NewBuffer(js))
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode >= 400 {
+		return fmt.Errorf("%v responded %v", url, r.Status)
+	}
+	return nil
+}
+
+// newNotifiers creates the notifiers enabled by the flags
+func newNotifiers() []Notifier {
+	notifiers := []Notifier{}
+	if *slackAppEnabled {
+		notifiers = append(notifiers, &slackNotifier{*slackWebHookURL})
+	}
+	if *notifyWebhookURL != "" {
+		notifiers = append(notifiers, &webhookNotifier{*notifyWebhookURL})
+	}
+	return notifiers
+}
+
+// newMailer creates the notifier emailing the users through --smtpAddr, nil if there's none
+func newMailer() Notifier {
+	if *smtpAddr == "" {
+		return nil
+	}
+	en := &emailNotifier{addr: *smtpAddr, from: *smtpFrom}
+	if *smtpUser != "" {
+		host, _, _ := net.SplitHostPort(*smtpAddr)
+		en.auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+	}
+	return en
+}
+
+// notify delivers the message about the user by all the notifiers, and to the user by email
+// through the Mailer if any, without blocking the controller
+func (controller *Controller) notify(user api.User, text string) {
+	notifiers := controller.Notifiers
+	if controller.Mailer != nil && user.Email != "" {
+		notifiers = append(notifiers[:len(notifiers):len(notifiers)], controller.Mailer)
+	}
+	for _, n := range notifiers {
+		go func(n Notifier) {
+			if err := n.Notify(user, text); err != nil {
+				log.Printf("[Notifier] %v", err)
+			}
+		}(n)
+	}
+}
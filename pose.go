@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// builtinPoses are the read-only named poses; sleep is the pose reported while the robot
+// sleeps, and moving to it puts the robot to sleep
+var builtinPoses = []api.NamedPose{
+	{Name: "home", Pose: &homePose, ReadOnly: true},
+	{Name: "sleep", Pose: &api.RobotPose{}, ReadOnly: true},
+}
+
+// requireUser returns the email of the user or the key for the token, or the feedback
+// if the token is invalid or of an observer key
+func (controller *Controller) requireUser(token string) (string, *api.HandlerMessage) {
+	if key := controller.findKey(token); key != nil {
+		if key.Role == api.RoleObserver {
+			return "", &api.HandlerMessage{
+				Type:  api.TypeForbidden,
+				Value: []interface{}{api.Problem{Detail: "Observer keys may only read"}},
+			}
+		}
+		return key.Email, nil
+	}
+	claims, err := controller.verifyToken(token)
+	if err != nil {
+		return "", &api.HandlerMessage{
+			Type:  api.TypeInvalidToken,
+			Value: []interface{}{api.Problem{Detail: "The token is invalid: " + err.Error()}},
+		}
+	}
+	return claims.Email, nil
+}
+
+// findPose returns the named pose, nil if there's none
+func (controller *Controller) findPose(name string) *api.NamedPose {
+	for i := range builtinPoses {
+		if builtinPoses[i].Name == name {
+			return &builtinPoses[i]
+		}
+	}
+	for _, np := range controller.Poses {
+		if np.Name == name {
+			return np
+		}
+	}
+	return nil
+}
+
+// readOnlyFailure creates the feedback for changing a built-in pose
+func readOnlyFailure(name string) api.HandlerMessage {
+	return api.HandlerMessage{
+		Type:  api.TypeInvalidCommand,
+		Value: []interface{}{api.Problem{Detail: fmt.Sprintf("The pose %v is built in and read-only", name), Field: "name", Value: name}},
+	}
+}
+
+// applyPose moves to the named pose through the posture, or the sleep, under the same checks
+func (controller *Controller) applyPose(poseCom api.PoseCommand) api.HandlerMessage {
+	np := controller.findPose(poseCom.Name)
+	if np == nil {
+		return api.HandlerMessage{Type: api.TypePoseNotFound}
+	}
+	if np.Name == "sleep" {
+		return controller.handle(api.HandlerMessage{
+			Type:  api.TypePutSleep,
+			Value: []interface{}{poseCom.Token},
+		})
+	}
+	delta := *defaultDelta
+	if poseCom.Delta != nil {
+		delta = *poseCom.Delta
+	}
+	log.Printf("[Pose] Moving to %v", np.Name)
+	return controller.handle(api.HandlerMessage{
+		Type: api.TypePutPosture,
+		Value: []interface{}{api.PostureCommand{
+			Token:         poseCom.Token,
+			Base:          np.Pose.Base,
+			Shoulder:      np.Pose.Shoulder,
+			Elbow:         np.Pose.Elbow,
+			WristAngle:    np.Pose.WristAngle,
+			WristRotation: np.Pose.WristRotation,
+			Gripper:       np.Pose.Gripper,
+			Delta:         delta,
+			Speed:         poseCom.Speed,
+		}},
+	})
+}
+
+// handlePose processes the messages on the named poses, saving and deleting them
+// is for the users and the operator or admin keys
+func (controller *Controller) handlePose(msg api.HandlerMessage) api.HandlerMessage {
+	switch msg.Type {
+	case api.TypeGetPoses:
+		poses := append([]api.NamedPose{}, builtinPoses...)
+		for _, np := range controller.Poses {
+			poses = append(poses, *np)
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentPoses,
+			Value: []interface{}{poses},
+		}
+	case api.TypeGetPose:
+		name, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		np := controller.findPose(name)
+		if np == nil {
+			return api.HandlerMessage{Type: api.TypePoseNotFound}
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentPose,
+			Value: []interface{}{*np},
+		}
+	case api.TypeApplyPose:
+		poseCom, ok := msg.Value[0].(api.PoseCommand)
+		if !ok {
+			break
+		}
+		return controller.applyPose(poseCom)
+	case api.TypePutPose:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		email, failure := controller.requireUser(token)
+		if failure != nil {
+			return *failure
+		}
+		np, ok := msg.Value[1].(api.NamedPose)
+		if !ok {
+			break
+		}
+		old := controller.findPose(np.Name)
+		if old != nil && old.ReadOnly {
+			return readOnlyFailure(np.Name)
+		}
+		if np.Pose == nil {
+			// take over the current pose, which is unknown while sleeping
+			if controller.CurrentRobotState == Sleeping {
+				return api.HandlerMessage{
+					Type:  api.TypeInvalidCommand,
+					Value: []interface{}{api.Problem{Detail: "Leubot is sleeping, give the pose to save", Field: "pose"}},
+				}
+			}
+			pose := *controller.CurrentRobotPose
+			np.Pose = &pose
+		}
+		hard := &envelope{limits: api.JointRanges}
+		for _, joint := range api.JointNames {
+			if p := checkRange(hard, joint, np.Pose.Get(joint)); p != nil {
+				return api.HandlerMessage{
+					Type:  api.TypeInvalidCommand,
+					Value: []interface{}{*p},
+				}
+			}
+		}
+		np.ReadOnly = false
+		np.SavedBy = email
+		saved := time.Now().UTC()
+		np.Saved = &saved
+		log.Printf("[Pose] Saved %v: %v", np.Name, np.Pose)
+		if old != nil {
+			*old = np
+			return api.HandlerMessage{Type: api.TypePoseUpdated}
+		}
+		controller.Poses = append(controller.Poses, &np)
+		return api.HandlerMessage{Type: api.TypePoseAdded}
+	case api.TypeDeletePose:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		if _, failure := controller.requireUser(token); failure != nil {
+			return *failure
+		}
+		name, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		if np := controller.findPose(name); np != nil && np.ReadOnly {
+			return readOnlyFailure(name)
+		}
+		for i, np := range controller.Poses {
+			if np.Name == name {
+				controller.Poses = append(controller.Poses[:i], controller.Poses[i+1:]...)
+				log.Printf("[Pose] Deleted %v", name)
+				return api.HandlerMessage{Type: api.TypePoseDeleted}
+			}
+		}
+		return api.HandlerMessage{Type: api.TypePoseNotFound}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
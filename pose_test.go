@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// getPose returns the named pose, failing the test if it can't be read
+func getPose(t *testing.T, h http.Handler, name string) api.NamedPose {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/poses/"+name, "", nil)
+	var np api.NamedPose
+	if err := json.NewDecoder(rec.Body).Decode(&np); rec.Code != http.StatusOK || err != nil || np.Pose == nil {
+		t.Fatalf("GET /poses/%v: %v %v", name, rec.Code, err)
+	}
+	return np
+}
+
+func TestPoses(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	observer := addTestKey(t, h, "observer", api.RoleObserver)
+
+	// the current pose is unknown while sleeping
+	if rec := serve(h, http.MethodPut, "/sleep", token, nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("PUT /sleep: %v %v", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPut, "/poses/reach", token, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /poses/reach while sleeping: %v, want 400", rec.Code)
+	}
+
+	// save the current pose
+	if code := moveBase(h, token, 450); code != http.StatusAccepted {
+		t.Fatalf("PUT /base: %v", code)
+	}
+	rec := serve(h, http.MethodPut, "/poses/reach", token, nil)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "http://localhost/leubot/v1/poses/reach" {
+		t.Fatalf("PUT /poses/reach: %v %q %v", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	if np := getPose(t, h, "reach"); *np.Pose != currentPose(t, h) || np.SavedBy != "alice@example.com" || np.ReadOnly || np.Saved == nil {
+		t.Errorf("GET /poses/reach: %+v", np)
+	}
+
+	// save an explicit pose, then update it
+	tuck := homePose
+	tuck.Base, tuck.Gripper = 600, 200
+	if rec := serve(h, http.MethodPut, "/poses/tuck", token, api.NamedPose{Pose: &tuck}); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT /poses/tuck: %v %v", rec.Code, rec.Body)
+	}
+	tuck.WristRotation = 300
+	if rec := serve(h, http.MethodPut, "/poses/tuck", token, api.NamedPose{Pose: &tuck}); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /poses/tuck again: %v %v", rec.Code, rec.Body)
+	}
+	if np := getPose(t, h, "tuck"); *np.Pose != tuck {
+		t.Errorf("GET /poses/tuck after the update: %v, want %v", np.Pose.String(), tuck.String())
+	}
+	beyond := tuck
+	beyond.Shoulder = 1000
+	if rec := serve(h, http.MethodPut, "/poses/tuck", token, api.NamedPose{Pose: &beyond}); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /poses/tuck beyond the hard limits: %v, want 400", rec.Code)
+	}
+
+	// only the users and the keys beyond observers save and delete
+	for _, tc := range []struct {
+		token string
+		code  int
+	}{{"", http.StatusUnauthorized}, {"bogus", http.StatusUnauthorized}, {observer.Token, http.StatusForbidden}} {
+		if rec := serve(h, http.MethodPut, "/poses/tuck", tc.token, api.NamedPose{Pose: &homePose}); rec.Code != tc.code {
+			t.Errorf("PUT /poses/tuck with %q: %v, want %v", tc.token, rec.Code, tc.code)
+		}
+		if rec := serve(h, http.MethodDelete, "/poses/tuck", tc.token, nil); rec.Code != tc.code {
+			t.Errorf("DELETE /poses/tuck with %q: %v, want %v", tc.token, rec.Code, tc.code)
+		}
+	}
+
+	// the built-in poses are read-only
+	for _, name := range []string{"home", "sleep"} {
+		if rec := serve(h, http.MethodPut, "/poses/"+name, token, api.NamedPose{Pose: &tuck}); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT /poses/%v: %v, want 400", name, rec.Code)
+		}
+		if rec := serve(h, http.MethodDelete, "/poses/"+name, token, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("DELETE /poses/%v: %v, want 400", name, rec.Code)
+		}
+		if np := getPose(t, h, name); !np.ReadOnly {
+			t.Errorf("GET /poses/%v is not read-only", name)
+		}
+	}
+	if np := getPose(t, h, "home"); *np.Pose != homePose {
+		t.Errorf("the home pose changed: %v", np.Pose.String())
+	}
+	rec = serve(h, http.MethodGet, "/poses", "", nil)
+	var poses []api.NamedPose
+	if err := json.NewDecoder(rec.Body).Decode(&poses); err != nil || len(poses) != 4 {
+		t.Errorf("GET /poses: %+v %v", poses, err)
+	}
+
+	// apply the pose with the delta
+	rec = serve(h, http.MethodPut, "/poses/tuck/apply", token, map[string]interface{}{"delta": 60})
+	var c api.Completion
+	if err := json.NewDecoder(rec.Body).Decode(&c); rec.Code != http.StatusAccepted || err != nil || c.Delta != 60 {
+		t.Fatalf("PUT /poses/tuck/apply: %v %+v %v", rec.Code, c, err)
+	}
+	if rp := currentPose(t, h); rp != tuck {
+		t.Errorf("the posture after applying tuck: %v, want %v", rp.String(), tuck.String())
+	}
+	if rec := serve(h, http.MethodPut, "/poses/missing/apply", token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("PUT /poses/missing/apply: %v, want 404", rec.Code)
+	}
+
+	// delete the pose
+	if rec := serve(h, http.MethodDelete, "/poses/tuck", token, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /poses/tuck: %v %v", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodGet, "/poses/tuck", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /poses/tuck after the deletion: %v, want 404", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/poses/tuck", token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE /poses/tuck again: %v, want 404", rec.Code)
+	}
+}
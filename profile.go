@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/badoux/checkmail"
+)
+
+// envelope is what the commands of a user are checked against: the limits for the role
+// narrowed by the safety profile of the user if any
+type envelope struct {
+	profile   string
+	limits    map[string]api.JointRange
+	forbidden map[string][]api.JointRange
+}
+
+// profileFor returns the safety profile attached to the email, or else to the role, nil if there's none
+func (controller *Controller) profileFor(email string, role api.Role) *api.SafetyProfile {
+	for _, byRole := range []bool{false, true} {
+		for _, sp := range controller.Profiles {
+			if sp.AppliesTo(email, role, byRole) {
+				return sp
+			}
+		}
+	}
+	return nil
+}
+
+// envelopeFor returns the envelope for the token which passed Validate
+func (controller *Controller) envelopeFor(token string) *envelope {
+	email, role := controller.CurrentUser.Email, controller.CurrentUser.Role
+	if key := controller.findKey(token); key != nil {
+		email, role = key.Email, key.Role
+	}
+	env := &envelope{limits: controller.limitsFor(role)}
+	sp := controller.profileFor(email, role)
+	if sp == nil {
+		return env
+	}
+
+	// the profile only narrows the limits
+	limits := map[string]api.JointRange{}
+	for joint, jr := range env.limits {
+		if pjr, ok := sp.Limits[joint]; ok {
+			if pjr.Min > jr.Min {
+				jr.Min = pjr.Min
+			}
+			if pjr.Max < jr.Max {
+				jr.Max = pjr.Max
+			}
+		}
+		limits[joint] = jr
+	}
+	env.profile = sp.Name
+	env.limits = limits
+	env.forbidden = sp.Forbidden
+	return env
+}
+
+// checkProfile returns a Problem if the safety profile is invalid
+func checkProfile(sp *api.SafetyProfile) *api.Problem {
+	if sp.Name == "" {
+		return &api.Problem{Detail: "The name is required", Field: "name"}
+	}
+	if p := checkLimits(sp.Limits); p != nil {
+		return p
+	}
+	for joint, frs := range sp.Forbidden {
+		if _, ok := api.JointRanges[joint]; !ok {
+			return &api.Problem{Detail: fmt.Sprintf("No such joint: %v", joint), Field: joint}
+		}
+		for _, fr := range frs {
+			if fr.Min > fr.Max {
+				return &api.Problem{Detail: fmt.Sprintf("The forbidden range for %v must have min <= max", joint), Field: joint, Value: fr}
+			}
+		}
+	}
+	for _, role := range sp.Roles {
+		if !role.Valid() {
+			return &api.Problem{Detail: "The roles must be admin, operator or observer", Field: "roles", Value: role}
+		}
+	}
+	for _, email := range sp.Users {
+		if err := checkmail.ValidateFormat(email); err != nil {
+			return &api.Problem{Detail: err.Error(), Field: "users", Value: email}
+		}
+	}
+	return nil
+}
+
+// handleProfile processes the messages on the safety profiles, only for the admins
+func (controller *Controller) handleProfile(msg api.HandlerMessage) api.HandlerMessage {
+	token, ok := msg.Value[0].(string)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	if failure := controller.requireAdmin(token); failure != nil {
+		return *failure
+	}
+	switch msg.Type {
+	case api.TypeGetProfiles:
+		profiles := []api.SafetyProfile{}
+		for _, sp := range controller.Profiles {
+			profiles = append(profiles, *sp)
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentProfiles,
+			Value: []interface{}{profiles},
+		}
+	case api.TypePutProfile:
+		sp, ok := msg.Value[1].(api.SafetyProfile)
+		if !ok {
+			break
+		}
+		if p := checkProfile(&sp); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
+		log.Printf("[Profile] Saved the safety profile %v for the roles %v and the users %v", sp.Name, sp.Roles, sp.Users)
+		for i, old := range controller.Profiles {
+			if old.Name == sp.Name {
+				controller.Profiles[i] = &sp
+				return api.HandlerMessage{Type: api.TypeProfileUpdated}
+			}
+		}
+		controller.Profiles = append(controller.Profiles, &sp)
+		return api.HandlerMessage{Type: api.TypeProfileUpdated}
+	case api.TypeDeleteProfile:
+		name, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		for i, sp := range controller.Profiles {
+			if sp.Name == name {
+				controller.Profiles = append(controller.Profiles[:i], controller.Profiles[i+1:]...)
+				log.Printf("[Profile] Deleted the safety profile %v", name)
+				return api.HandlerMessage{Type: api.TypeProfileDeleted}
+			}
+		}
+		return api.HandlerMessage{Type: api.TypeProfileNotFound}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
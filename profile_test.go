@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// moveJoint moves the joint with the token and returns the problem, nil if the move was accepted
+func moveJoint(t *testing.T, h http.Handler, token string, joint string, value int) *api.Problem {
+	t.Helper()
+	rec := serve(h, http.MethodPut, "/"+joint, token, map[string]interface{}{"value": value})
+	if rec.Code == http.StatusAccepted {
+		return nil
+	}
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("PUT /%v: %v %v", joint, rec.Code, err)
+	}
+	return &p
+}
+
+func TestProfiles(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	admin := addTestKey(t, h, "admin", api.RoleAdmin)
+
+	beginners := api.SafetyProfile{
+		Limits:    map[string]api.JointRange{"elbow": {Min: 300, Max: 500}, "delta": {Min: 64, Max: 254}},
+		Forbidden: map[string][]api.JointRange{"gripper": {{Min: 0, Max: 100}}},
+		Roles:     []api.Role{api.RoleOperator},
+	}
+	if rec := serve(h, http.MethodPut, "/profiles/beginners", token, beginners); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /profiles as the user: %v, want 401", rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, "/profiles/beginners", admin.Token, beginners); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /profiles/beginners: %v %v", rec.Code, rec.Body)
+	}
+
+	// the profile of the role narrows the soft limits and forbids the ranges within them
+	if p := moveJoint(t, h, token, "elbow", 520); p == nil || p.Profile != "beginners" || p.Range == nil || *p.Range != beginners.Limits["elbow"] {
+		t.Errorf("PUT /elbow beyond the profile: %+v", p)
+	}
+	if p := moveJoint(t, h, token, "gripper", 50); p == nil || p.Profile != "beginners" || p.Range == nil || *p.Range != beginners.Forbidden["gripper"][0] {
+		t.Errorf("PUT /gripper in the forbidden range: %+v", p)
+	}
+	if p := moveJoint(t, h, token, "gripper", 200); p != nil {
+		t.Errorf("PUT /gripper: %+v", p)
+	}
+
+	// the least delta caps the speed, a larger delta being a slower move
+	posCom := postureCommand(currentPose(t, h))
+	posCom.Delta = 32
+	if rec := serve(h, http.MethodPut, "/posture", token, posCom); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /posture faster than the profile: %v, want 400", rec.Code)
+	}
+	posCom.Delta = 100
+	if rec := serve(h, http.MethodPut, "/posture", token, posCom); rec.Code != http.StatusAccepted {
+		t.Errorf("PUT /posture slower than the cap of the profile: %v", rec.Code)
+	}
+	if p := moveJoint(t, h, admin.Token, "elbow", 520); p != nil {
+		t.Errorf("PUT /elbow as an admin: %+v", p)
+	}
+
+	// a profile naming the user wins over the one for the role
+	alice := api.SafetyProfile{Limits: map[string]api.JointRange{"elbow": {Min: 300, Max: 550}}, Users: []string{"alice@example.com"}}
+	if rec := serve(h, http.MethodPut, "/profiles/alice", admin.Token, alice); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /profiles/alice: %v %v", rec.Code, rec.Body)
+	}
+	if p := moveJoint(t, h, token, "elbow", 530); p != nil {
+		t.Errorf("PUT /elbow within the profile of the user: %+v", p)
+	}
+	if p := moveJoint(t, h, token, "gripper", 50); p != nil {
+		t.Errorf("PUT /gripper without the profile of the role: %+v", p)
+	}
+
+	rec := serve(h, http.MethodGet, "/profiles", admin.Token, nil)
+	var profiles []api.SafetyProfile
+	if err := json.NewDecoder(rec.Body).Decode(&profiles); rec.Code != http.StatusOK || err != nil || len(profiles) != 2 {
+		t.Errorf("GET /profiles: %v %v %v", rec.Code, profiles, err)
+	}
+	if rec := serve(h, http.MethodDelete, "/profiles/alice", admin.Token, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /profiles/alice: %v", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/profiles/alice", admin.Token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE /profiles/alice again: %v, want 404", rec.Code)
+	}
+	if p := moveJoint(t, h, token, "elbow", 540); p == nil || p.Profile != "beginners" {
+		t.Errorf("PUT /elbow after the profile of the user is gone: %+v", p)
+	}
+}
+
+func TestProfileSpeed(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	admin := addTestKey(t, h, "admin", api.RoleAdmin)
+
+	// slower than the default delta
+	slow := api.SafetyProfile{Limits: map[string]api.JointRange{"delta": {Min: 200, Max: 254}}, Roles: []api.Role{api.RoleOperator}}
+	if rec := serve(h, http.MethodPut, "/profiles/slow", admin.Token, slow); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /profiles/slow: %v %v", rec.Code, rec.Body)
+	}
+	if p := moveJoint(t, h, token, "gripper", 200); p == nil || p.Field != "delta" || p.Profile != "slow" {
+		t.Errorf("PUT /gripper at the default delta: %+v", p)
+	}
+	if rec := serve(h, http.MethodPut, "/gripper", token, map[string]interface{}{"value": 200, "durationMs": 100}); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /gripper in 100 ms: %v, want 400", rec.Code)
+	}
+	rec := serve(h, http.MethodPut, "/gripper", token, map[string]interface{}{"value": 200, "durationMs": 4000})
+	var c api.Completion
+	if err := json.NewDecoder(rec.Body).Decode(&c); rec.Code != http.StatusAccepted || err != nil || c.Delta != 250 {
+		t.Errorf("PUT /gripper in 4 s: %v %+v %v", rec.Code, c, err)
+	}
+}
+
+func TestCheckProfile(t *testing.T) {
+	for name, tc := range map[string]struct {
+		sp api.SafetyProfile
+		ok bool
+	}{
+		"limits":          {api.SafetyProfile{Name: "p", Limits: map[string]api.JointRange{"elbow": {Min: 300, Max: 500}}}, true},
+		"no name":         {api.SafetyProfile{}, false},
+		"beyond the hard": {api.SafetyProfile{Name: "p", Limits: map[string]api.JointRange{"elbow": {Min: 0, Max: 500}}}, false},
+		"no such joint":   {api.SafetyProfile{Name: "p", Forbidden: map[string][]api.JointRange{"knee": {{Min: 0, Max: 10}}}}, false},
+		"min above max":   {api.SafetyProfile{Name: "p", Forbidden: map[string][]api.JointRange{"base": {{Min: 10, Max: 0}}}}, false},
+		"no such role":    {api.SafetyProfile{Name: "p", Roles: []api.Role{"root"}}, false},
+		"invalid email":   {api.SafetyProfile{Name: "p", Users: []string{"alice"}}, false},
+	} {
+		if p := checkProfile(&tc.sp); (p == nil) != tc.ok {
+			t.Errorf("checkProfile with %v: %+v", name, p)
+		}
+	}
+}
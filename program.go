@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// recording is the program being recorded from the successful commands of the session
+type recording struct {
+	program api.Program
+	session string
+	last    time.Time
+}
+
+// replaying is the program being replayed, until stop is closed
+type replaying struct {
+	program string
+	stop    chan struct{}
+}
+
+// recordStep returns the step for the command moving the robot, false for any other message;
+// the jogs are recorded with where the reply says the joints moved to, and the moves with
+// the delta the reply says they were sent with
+func recordStep(msg api.HandlerMessage, reply api.HandlerMessage) (api.Step, bool) {
+	c, sent := replyCompletion(reply)
+	switch msg.Type {
+	case api.TypePutBase, api.TypePutShoulder, api.TypePutElbow, api.TypePutWristAngle, api.TypePutWristRotation, api.TypePutGripper:
+		value := reply.Value[0].(uint16)
+		step := api.Step{Command: putJoints[msg.Type], Value: &value}
+		// the joints move with the default delta unless the speed is given
+		if msg.Value[0].(api.RobotCommand).Speed != (api.Speed{}) {
+			step.Delta = &c.Delta
+		}
+		return step, true
+	case api.TypePatchPosture:
+		rp := reply.Value[0].(api.RobotPose)
+		return api.Step{Command: api.StepPosture, Posture: &rp, Delta: &c.Delta}, true
+	case api.TypePutPosture:
+		posCom := msg.Value[0].(api.PostureCommand)
+		rp := posCom.RobotPose()
+		return api.Step{Command: api.StepPosture, Posture: &rp, Delta: &c.Delta}, true
+	case api.TypeApplyPose:
+		poseCom := msg.Value[0].(api.PoseCommand)
+		step := api.Step{Command: api.StepPose, Pose: poseCom.Name, Delta: poseCom.Delta}
+		if sent {
+			step.Delta = &c.Delta
+		}
+		return step, true
+	case api.TypePutReset:
+		return api.Step{Command: api.StepReset}, true
+	case api.TypePutSleep:
+		return api.Step{Command: api.StepSleep}, true
+	}
+	return api.Step{}, false
+}
+
+// record appends the command to the recording if it succeeded for the session being recorded
+func (controller *Controller) record(msg api.HandlerMessage, reply api.HandlerMessage) {
+	rec := controller.Recording
+	if rec == nil || reply.Type != api.TypeActionPerformed {
+		return
+	}
+	step, ok := recordStep(msg, reply)
+	if !ok || controller.sessionOf(messageToken(msg)) != rec.session {
+		return
+	}
+	now := time.Now()
+	if len(rec.program.Steps) > 0 {
+		step.AfterMs = now.Sub(rec.last).Milliseconds()
+	}
+	rec.last = now
+	rec.program.Steps = append(rec.program.Steps, step)
+}
+
+// finishRecording saves the recorded program, replacing the one with the same name
+func (controller *Controller) finishRecording() api.Program {
+	program := controller.Recording.program
+	controller.Recording = nil
+	recorded := time.Now().UTC()
+	program.Recorded = &recorded
+	controller.saveProgram(&program)
+	log.Printf("[Recording] Saved %v with %v steps", program.Name, len(program.Steps))
+	return program
+}
+
+// saveProgram adds the program or replaces the one with the same name, returns true if replaced
+func (controller *Controller) saveProgram(program *api.Program) bool {
+	for i, old := range controller.Programs {
+		if old.Name == program.Name {
+			controller.Programs[i] = program
+			return true
+		}
+	}
+	controller.Programs = append(controller.Programs, program)
+	return false
+}
+
+// findProgram returns the program, nil if there's none
+func (controller *Controller) findProgram(name string) *api.Program {
+	for _, program := range controller.Programs {
+		if program.Name == name {
+			return program
+		}
+	}
+	return nil
+}
+
+// scaleDelta divides the delta by the speed of the replay, within the limits of ArmLink
+func scaleDelta(delta uint8, speed float64) uint8 {
+	return uint8(math.Min(math.Round(float64(delta)/speed), float64(api.JointRanges["delta"].Max)))
+}
+
+// stepMessage returns the command of the step with the token
+func stepMessage(step api.Step, token string, speed float64) api.HandlerMessage {
+	switch step.Command {
+	case api.StepPosture:
+		delta := *defaultDelta
+		if step.Delta != nil {
+			delta = *step.Delta
+		}
+		return api.HandlerMessage{
+			Type: api.TypePutPosture,
+			Value: []interface{}{api.PostureCommand{
+				Token:         token,
+				Base:          step.Posture.Base,
+				Shoulder:      step.Posture.Shoulder,
+				Elbow:         step.Posture.Elbow,
+				WristAngle:    step.Posture.WristAngle,
+				WristRotation: step.Posture.WristRotation,
+				Gripper:       step.Posture.Gripper,
+				Delta:         scaleDelta(delta, speed),
+			}},
+		}
+	case api.StepPose:
+		poseCom := api.PoseCommand{Token: token, Name: step.Pose}
+		if step.Delta != nil {
+			delta := scaleDelta(*step.Delta, speed)
+			poseCom.Delta = &delta
+		}
+		return api.HandlerMessage{Type: api.TypeApplyPose, Value: []interface{}{poseCom}}
+	case api.StepReset:
+		return api.HandlerMessage{Type: api.TypePutReset, Value: []interface{}{token}}
+	case api.StepSleep:
+		return api.HandlerMessage{Type: api.TypePutSleep, Value: []interface{}{token}}
+	}
+	for msgType, joint := range putJoints {
+		if joint == step.Command {
+			roboCom := api.RobotCommand{Token: token, Value: *step.Value}
+			if step.Delta != nil {
+				// move in the time of the delta
+				ms := moveTime(scaleDelta(*step.Delta, speed)).Milliseconds()
+				roboCom.DurationMs = &ms
+			}
+			return api.HandlerMessage{Type: msgType, Value: []interface{}{roboCom}}
+		}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
+
+// checkProgram returns a Problem if a step or the script of the program is invalid
+func checkProgram(program *api.Program) *api.Problem {
+	if program.Script != "" {
+		if len(program.Steps) > 0 {
+			return &api.Problem{Detail: "A program has either the steps or a script", Field: "script"}
+		}
+		return checkScript(program.Name, program.Script)
+	}
+	for i, step := range program.Steps {
+		field := fmt.Sprintf("steps[%v]", i)
+		switch {
+		case step.AfterMs < 0:
+			return &api.Problem{Detail: "The wait before a step must not be negative", Field: field, Value: step.AfterMs}
+		case step.Command == api.StepPosture && step.Posture == nil:
+			return &api.Problem{Detail: "The posture step needs the posture", Field: field}
+		case step.Command == api.StepPose && step.Pose == "":
+			return &api.Problem{Detail: "The pose step needs the name of the pose", Field: field}
+		case step.Command == api.StepPosture, step.Command == api.StepPose, step.Command == api.StepReset, step.Command == api.StepSleep:
+		case step.Command == "delta":
+			return &api.Problem{Detail: "No such command: delta", Field: field, Value: step.Command}
+		default:
+			if _, ok := api.JointRanges[step.Command]; !ok {
+				return &api.Problem{Detail: fmt.Sprintf("No such command: %v", step.Command), Field: field, Value: step.Command}
+			}
+			if step.Value == nil {
+				return &api.Problem{Detail: fmt.Sprintf("The %v step needs the value", step.Command), Field: field}
+			}
+		}
+	}
+	return nil
+}
+
+// replay sends the steps of the program through HandlerChannel like the requests, until a step fails
+// or the stop is closed, and tells the controller through Events when it's over
+func (controller *Controller) replay(program api.Program, token string, rp api.Replay, stop chan struct{}) {
+	defer func() {
+		controller.Events <- api.HandlerMessage{
+			Type:  api.TypeReplayFinished,
+			Value: []interface{}{stop},
+		}
+	}()
+	for i := 0; rp.Loops < 0 || i < rp.Loops; i++ {
+		if program.Script != "" {
+			sr := &scriptRun{controller: controller, token: token, speed: rp.Speed, stop: stop, out: log.Writer()}
+			if err := sr.run(program.Name, program.Script); err != nil {
+				log.Printf("[Replay] %v stopped: %v", program.Name, err)
+				return
+			}
+			continue
+		}
+		for _, step := range program.Steps {
+			select {
+			case <-stop:
+				log.Printf("[Replay] Stopped %v", program.Name)
+				return
+			case <-time.After(time.Duration(float64(step.AfterMs)/rp.Speed) * time.Millisecond):
+			}
+			if reply, _ := api.Request(controller.HandlerChannel, stepMessage(step, token, rp.Speed)); reply.Type != api.TypeActionPerformed {
+				log.Printf("[Replay] %v stopped at the %v step: %v", program.Name, step.Command, reply.Type)
+				return
+			}
+		}
+	}
+	log.Printf("[Replay] Finished %v", program.Name)
+}
+
+// stopReplay stops the replay if any
+func (controller *Controller) stopReplay() {
+	if controller.Replaying != nil {
+		close(controller.Replaying.stop)
+		controller.Replaying = nil
+	}
+}
+
+// handleProgram processes the messages on the recording, the programs and the replay;
+// recording and replaying are for the current user, importing and deleting for any user
+func (controller *Controller) handleProgram(msg api.HandlerMessage) api.HandlerMessage {
+	switch msg.Type {
+	case api.TypeGetPrograms:
+		programs := []api.Program{}
+		for _, program := range controller.Programs {
+			programs = append(programs, *program)
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentPrograms,
+			Value: []interface{}{programs},
+		}
+	case api.TypeGetProgram:
+		name, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		program := controller.findProgram(name)
+		if program == nil {
+			return api.HandlerMessage{Type: api.TypeProgramNotFound}
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentProgram,
+			Value: []interface{}{*program},
+		}
+	case api.TypeReplayFinished:
+		if stop, ok := msg.Value[0].(chan struct{}); ok && controller.Replaying != nil && controller.Replaying.stop == stop {
+			controller.Replaying = nil
+		}
+		return api.HandlerMessage{Type: api.TypeReplayStopped}
+	}
+
+	token, ok := msg.Value[0].(string)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	switch msg.Type {
+	case api.TypeAddProgram, api.TypePutProgram:
+		email, failure := controller.requireUser(token)
+		if failure != nil {
+			return *failure
+		}
+		program, ok := msg.Value[1].(api.Program)
+		if !ok {
+			break
+		}
+		if msg.Type == api.TypeAddProgram {
+			if program.Name == "" || strings.Contains(program.Name, "/") {
+				return api.HandlerMessage{
+					Type:  api.TypeInvalidCommand,
+					Value: []interface{}{api.Problem{Detail: "The name of the program is required, without a slash", Field: "name", Value: program.Name}},
+				}
+			}
+			if controller.findProgram(program.Name) != nil {
+				return api.HandlerMessage{
+					Type:  api.TypeProgramExisted,
+					Value: []interface{}{api.Problem{Detail: fmt.Sprintf("The program %v exists, import it to replace it", program.Name), Field: "name", Value: program.Name}},
+				}
+			}
+		}
+		if p := checkProgram(&program); p != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{*p},
+			}
+		}
+		if program.Steps == nil {
+			program.Steps = []api.Step{}
+		}
+		if program.RecordedBy == "" {
+			program.RecordedBy = email
+		}
+		if program.Script != "" {
+			log.Printf("[Program] Imported %v with a script", program.Name)
+		} else {
+			log.Printf("[Program] Imported %v with %v steps", program.Name, len(program.Steps))
+		}
+		if controller.saveProgram(&program) {
+			return api.HandlerMessage{Type: api.TypeProgramUpdated}
+		}
+		return api.HandlerMessage{Type: api.TypeProgramAdded}
+	case api.TypeDeleteProgram:
+		if _, failure := controller.requireUser(token); failure != nil {
+			return *failure
+		}
+		name, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		for i, program := range controller.Programs {
+			if program.Name == name {
+				controller.Programs = append(controller.Programs[:i], controller.Programs[i+1:]...)
+				log.Printf("[Program] Deleted %v", name)
+				return api.HandlerMessage{Type: api.TypeProgramDeleted}
+			}
+		}
+		return api.HandlerMessage{Type: api.TypeProgramNotFound}
+	}
+
+	// the rest is for the current user
+	userAuth := controller.Validate(token)
+	if userAuth != api.TypeUserExisted && userAuth != api.TypeUserAdded {
+		return controller.authFailure(token, userAuth)
+	}
+	switch msg.Type {
+	case api.TypeStartRecording:
+		rec, ok := msg.Value[1].(api.Recording)
+		if !ok {
+			break
+		}
+		if rec.Program == "" {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{api.Problem{Detail: "The name of the program is required", Field: "program"}},
+			}
+		}
+		if controller.Recording != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{api.Problem{Detail: fmt.Sprintf("Already recording %v, stop it first", controller.Recording.program.Name), Field: "program", Value: rec.Program}},
+			}
+		}
+		controller.Recording = &recording{
+			program: api.Program{Name: rec.Program, Steps: []api.Step{}, RecordedBy: controller.CurrentUser.Email},
+			session: controller.sessionOf(token),
+		}
+		if key := controller.findKey(token); key != nil {
+			controller.Recording.program.RecordedBy = key.Email
+		}
+		log.Printf("[Recording] Recording %v", rec.Program)
+		return api.HandlerMessage{Type: api.TypeRecordingStarted}
+	case api.TypeStopRecording:
+		if controller.Recording == nil || controller.Recording.session != controller.sessionOf(token) {
+			return api.HandlerMessage{
+				Type:  api.TypeRecordingNotFound,
+				Value: []interface{}{api.Problem{Detail: "Nothing is being recorded with the token"}},
+			}
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeRecordingStopped,
+			Value: []interface{}{controller.finishRecording()},
+		}
+	case api.TypeReplayProgram:
+		rp, ok := msg.Value[1].(api.Replay)
+		if !ok {
+			break
+		}
+		if controller.CurrentRobotState == Stopped {
+			return controller.stoppedFailure()
+		}
+		program := controller.findProgram(rp.Program)
+		if program == nil {
+			return api.HandlerMessage{Type: api.TypeProgramNotFound}
+		}
+		if controller.Replaying != nil {
+			return api.HandlerMessage{
+				Type:  api.TypeReplayBusy,
+				Value: []interface{}{api.Problem{Detail: fmt.Sprintf("The program %v is being replayed", controller.Replaying.program)}},
+			}
+		}
+		if rp.Speed == 0 {
+			rp.Speed = 1
+		}
+		if rp.Loops == 0 {
+			rp.Loops = 1
+		}
+		if rp.Speed < 0 {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{api.Problem{Detail: "The speed must be positive", Field: "speed", Value: rp.Speed}},
+			}
+		}
+		var total int64
+		for _, step := range program.Steps {
+			total += step.AfterMs
+		}
+		if rp.Loops < 0 && total == 0 && program.Script == "" {
+			return api.HandlerMessage{
+				Type:  api.TypeInvalidCommand,
+				Value: []interface{}{api.Problem{Detail: "Repeating until stopped needs waits between the steps", Field: "loops", Value: rp.Loops}},
+			}
+		}
+		controller.Replaying = &replaying{program: program.Name, stop: make(chan struct{})}
+		log.Printf("[Replay] Replaying %v %v times at %vx", program.Name, rp.Loops, rp.Speed)
+		go controller.replay(*program, token, rp, controller.Replaying.stop)
+		return api.HandlerMessage{Type: api.TypeReplayStarted}
+	case api.TypeStopReplay:
+		name, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		if controller.findProgram(name) == nil {
+			return api.HandlerMessage{Type: api.TypeProgramNotFound}
+		}
+		if controller.Replaying != nil && controller.Replaying.program == name {
+			controller.stopReplay()
+		}
+		return api.HandlerMessage{Type: api.TypeReplayStopped}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// TestReplayUnderLoad checks that the replay runs all the steps while the others poll
+// the robot, and that the controller takes the next replay once it's over
+func TestReplayUnderLoad(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	delta := uint8(1)
+	program := api.Program{Name: "wave"}
+	for _, v := range []uint16{400, 450, 500, 550, 600} {
+		v := v
+		program.Steps = append(program.Steps, api.Step{AfterMs: 20, Command: "base", Value: &v, Delta: &delta})
+	}
+	if rec := serve(h, http.MethodPut, "/programs/wave", token, program); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT /programs/wave: %v %v", rec.Code, rec.Body)
+	}
+
+	done := make(chan struct{})
+	wg := pollPosture(t, h, 8, done)
+	if rec := serve(h, http.MethodPut, "/programs/wave/replay", token, api.Replay{}); rec.Code != http.StatusAccepted {
+		t.Fatalf("PUT /programs/wave/replay: %v %v", rec.Code, rec.Body)
+	}
+	deadline := time.After(5 * time.Second)
+	for currentPose(t, h).Base != 600 {
+		select {
+		case <-deadline:
+			close(done)
+			t.Fatalf("the replay stopped at %v", currentPose(t, h))
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// the replay tells the controller that it's over
+	for {
+		rec := serve(h, http.MethodPut, "/programs/wave/replay", token, api.Replay{})
+		if rec.Code == http.StatusAccepted {
+			break
+		}
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("PUT /programs/wave/replay: %v %v", rec.Code, rec.Body)
+		}
+		select {
+		case <-deadline:
+			t.Fatal("the replay did not finish")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	serve(h, http.MethodDelete, "/programs/wave/replay", token, nil)
+}
+
+func TestRecordProgram(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	if rec := serve(h, http.MethodPut, "/recording", token, api.Recording{Program: "wave"}); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /recording: %v %v", rec.Code, rec.Body)
+	}
+	for _, v := range []int{450, 500} {
+		if code := moveBase(h, token, v); code != http.StatusAccepted {
+			t.Fatalf("PUT /base: %v", code)
+		}
+	}
+
+	// the moves are saved as the steps of the program
+	rec := serve(h, http.MethodDelete, "/recording", token, nil)
+	var program api.Program
+	if err := json.NewDecoder(rec.Body).Decode(&program); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("DELETE /recording: %v %v", rec.Code, err)
+	}
+	if len(program.Steps) != 2 || program.RecordedBy != "alice@example.com" {
+		t.Fatalf("the recorded program: %+v", program)
+	}
+	for i, v := range []uint16{450, 500} {
+		if step := program.Steps[i]; step.Command != "base" || step.Value == nil || *step.Value != v {
+			t.Errorf("the step %v: %+v, want base at %v", i, step, v)
+		}
+	}
+	if rec := serve(h, http.MethodGet, "/programs/wave", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /programs/wave: %v", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/recording", token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE /recording again: %v, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// queueTicket returns the state of the ticket, nil if there's no such ticket
+func (controller *Controller) queueTicket(ticket string) *api.QueueTicket {
+	if session, ok := controller.QueuePromoted[ticket]; ok {
+		if session != controller.CurrentUser.Session {
+			// the session has ended meanwhile
+			delete(controller.QueuePromoted, ticket)
+			return nil
+		}
+		return &api.QueueTicket{
+			Ticket: ticket,
+			Token:  controller.CurrentUser.Token,
+		}
+	}
+	for i, entry := range controller.Queue {
+		if entry.Ticket == ticket {
+			return &api.QueueTicket{
+				Ticket:        ticket,
+				Position:      i + 1,
+				EstimatedWait: controller.estimateWait(i + 1),
+			}
+		}
+	}
+	return nil
+}
+
+// estimateWait estimates the seconds until the user at the position gets the robot,
+// assuming everyone ahead keeps the robot until the timeout
+func (controller *Controller) estimateWait(position int) int64 {
+	if *userTimeout == 0 {
+		return 0
+	}
+	wait := time.Duration(position-1) * time.Second * time.Duration(*userTimeout)
+	if *controller.CurrentUser != (api.User{}) {
+		wait += time.Until(controller.UserDeadline)
+	}
+	return int64(wait.Seconds())
+}
+
+// checkCallback returns a Problem if the callback is not an http or https URL at one of
+// the --callbackHost if any, as the token of the user is posted there
+func checkCallback(callback string) *api.Problem {
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return &api.Problem{Detail: "The callback must be an http or https URL", Field: "callback", Value: callback}
+	}
+	if len(*callbackHost) == 0 {
+		return nil
+	}
+	for _, host := range *callbackHost {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return &api.Problem{Detail: fmt.Sprintf("The callback must be at %v", strings.Join(*callbackHost, ", ")), Field: "callback", Value: callback}
+}
+
+// joinQueue adds the user at the end of the queue, or returns the ticket
+// if the user is already waiting
+func (controller *Controller) joinQueue(userInfo *api.UserInfo, callback string) *api.QueueTicket {
+	for _, entry := range controller.Queue {
+		if entry.UserInfo.Email == userInfo.Email {
+			if callback != "" {
+				entry.Callback = callback
+			}
+			return controller.queueTicket(entry.Ticket)
+		}
+	}
+	entry := api.NewQueueEntry(userInfo, callback)
+	controller.Queue = append(controller.Queue, entry)
+	log.Printf("[Queue] %v (%v) joined the queue at %v", userInfo.Name, userInfo.Email, len(controller.Queue))
+	controller.notifyQueue()
+	return controller.queueTicket(entry.Ticket)
+}
+
+// notifyQueue wakes up the long-polls on the tickets
+func (controller *Controller) notifyQueue() {
+	close(controller.QueueChanged)
+	controller.QueueChanged = make(chan struct{})
+}
+
+// promoteQueue makes the first user in the queue the current user if the robot
+// is free and not reserved by someone else
+func (controller *Controller) promoteQueue() {
+	if len(controller.Queue) == 0 || *controller.CurrentUser != (api.User{}) {
+		return
+	}
+	entry := controller.Queue[0]
+	rsv := controller.activeReservation(time.Now())
+	if rsv != nil && rsv.Email != entry.UserInfo.Email {
+		return
+	}
+	controller.Queue = controller.Queue[1:]
+	log.Printf("[Queue] Promoting %v (%v)", entry.UserInfo.Name, entry.UserInfo.Email)
+	controller.startSession(api.NewUser(&entry.UserInfo), rsv)
+	controller.QueuePromoted[entry.Ticket] = controller.CurrentUser.Session
+	controller.notifyQueue()
+	if entry.Callback != "" {
+		go postQueueCallback(entry.Callback, api.QueueCallback{
+			Location: api.APIProto + api.APIHost + api.APIBasePath + "/user/" + controller.CurrentUser.Token,
+			Token:    controller.CurrentUser.Token,
+		})
+	}
+}
+
+// postQueueCallback notifies the promoted user at the callback URL
+func postQueueCallback(callback string, qc api.QueueCallback) {
+	js, err := json.Marshal(qc)
+	if err != nil {
+		log.Printf("[Queue] %v", err)
+		return
+	}
+	// the token goes nowhere else than the checked URL
+	client := &http.Client{
+		Timeout:       10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	r, err := client.Post(callback, "application/json", bytes.NewBuffer(js))
+	if err != nil {
+		log.Printf("[Queue] Callback to %v failed: %v", callback, err)
+		return
+	}
+	r.Body.Close()
+}
+
+// handleQueue processes the messages on the queue tickets
+func (controller *Controller) handleQueue(msg api.HandlerMessage) api.HandlerMessage {
+	ticket, ok := msg.Value[0].(string)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	switch msg.Type {
+	case api.TypeGetTicket:
+		qt := controller.queueTicket(ticket)
+		if qt == nil {
+			return api.HandlerMessage{Type: api.TypeTicketNotFound}
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentTicket,
+			Value: []interface{}{*qt, controller.QueueChanged},
+		}
+	case api.TypeDeleteTicket:
+		if _, ok := controller.QueuePromoted[ticket]; ok {
+			delete(controller.QueuePromoted, ticket)
+			return api.HandlerMessage{Type: api.TypeTicketDeleted}
+		}
+		for i, entry := range controller.Queue {
+			if entry.Ticket == ticket {
+				controller.Queue = append(controller.Queue[:i], controller.Queue[i+1:]...)
+				log.Printf("[Queue] %v (%v) left the queue", entry.UserInfo.Name, entry.UserInfo.Email)
+				controller.notifyQueue()
+				return api.HandlerMessage{Type: api.TypeTicketDeleted}
+			}
+		}
+		return api.HandlerMessage{Type: api.TypeTicketNotFound}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
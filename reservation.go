@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/badoux/checkmail"
+)
+
+// activeReservation returns the reservation at the time, nil if there's none
+func (controller *Controller) activeReservation(t time.Time) *api.Reservation {
+	for _, rsv := range controller.Reservations {
+		if rsv.Contains(t) {
+			return rsv
+		}
+	}
+	return nil
+}
+
+// findReservation returns the reservation with the ID, nil if there's none
+func (controller *Controller) findReservation(id string) *api.Reservation {
+	for _, rsv := range controller.Reservations {
+		if rsv.ID == id {
+			return rsv
+		}
+	}
+	return nil
+}
+
+// remaining returns how much of the time slot is still ahead
+func remaining(start time.Time, end time.Time) time.Duration {
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// reservedTime returns how much time the email has reserved ahead, besides the reservation with the id
+func (controller *Controller) reservedTime(email string, id string) time.Duration {
+	var reserved time.Duration
+	for _, rsv := range controller.Reservations {
+		if rsv.ID != id && strings.EqualFold(rsv.Email, email) {
+			reserved += remaining(rsv.Start, rsv.End)
+		}
+	}
+	return reserved
+}
+
+// reserverOf returns who reserves with the token, the holder of a key other than an observer key
+// or the current user, and the feedback if the token may not reserve
+func (controller *Controller) reserverOf(token string) (api.UserInfo, api.Role, *api.HandlerMessage) {
+	if key := controller.findKey(token); key != nil {
+		if key.Role == api.RoleObserver {
+			return api.UserInfo{}, key.Role, &api.HandlerMessage{
+				Type:  api.TypeForbidden,
+				Value: []interface{}{api.Problem{Detail: "Observer keys may only read the state of the robot"}},
+			}
+		}
+		return api.UserInfo{Name: key.Name, Email: key.Email}, key.Role, nil
+	}
+	if session := controller.sessionOf(token); session != "" && session == controller.CurrentUser.Session {
+		return controller.CurrentUser.ToUserInfo(), controller.CurrentUser.Role, nil
+	}
+	return api.UserInfo{}, "", &api.HandlerMessage{
+		Type:  api.TypeInvalidToken,
+		Value: []interface{}{api.Problem{Detail: "Reserving takes an API key or the token of the current user"}},
+	}
+}
+
+// checkReservation returns a Problem if the reservation is invalid or overlaps with another
+// reservation than old, the one changed if any; only the admins may book a slot starting soon
+func (controller *Controller) checkReservation(info *api.ReservationInfo, old *api.Reservation, role api.Role) (api.HandlerMessageType, *api.Problem) {
+	id := ""
+	if old != nil {
+		id = old.ID
+	}
+	if info.Name == "" {
+		return api.TypeInvalidReservation, &api.Problem{Detail: "The name is required", Field: "name"}
+	}
+	if strings.IndexFunc(info.Name, unicode.IsControl) >= 0 {
+		return api.TypeInvalidReservation, &api.Problem{Detail: "The name must not contain control characters", Field: "name", Value: info.Name}
+	}
+	if err := checkmail.ValidateFormat(info.Email); err != nil {
+		return api.TypeInvalidReservation, &api.Problem{Detail: err.Error(), Field: "email", Value: info.Email}
+	}
+	if !info.Start.Before(info.End) {
+		return api.TypeInvalidReservation, &api.Problem{Detail: "The reservation must end after it starts", Field: "end", Value: info.End}
+	}
+	if !info.End.After(time.Now()) {
+		return api.TypeInvalidReservation, &api.Problem{Detail: "The reservation must end in the future", Field: "end", Value: info.End}
+	}
+	if lead := time.Minute * time.Duration(*reservationLead); role != api.RoleAdmin && (old == nil || !info.Start.Equal(old.Start)) && info.Start.Before(time.Now().Add(lead)) {
+		return api.TypeInvalidReservation, &api.Problem{Detail: fmt.Sprintf("The reservation must start at least %v from now, not to take the robot from the current user at once", lead), Field: "start", Value: info.Start}
+	}
+	maxLength := time.Minute * time.Duration(*reservationMaxMinutes)
+	if info.End.Sub(info.Start) > maxLength {
+		return api.TypeInvalidReservation, &api.Problem{Detail: fmt.Sprintf("The reservation must not be longer than %v", maxLength), Field: "end", Value: info.End}
+	}
+	if quota := time.Minute * time.Duration(*reservationQuota); quota > 0 {
+		if reserved := controller.reservedTime(info.Email, id) + remaining(info.Start, info.End); reserved > quota {
+			return api.TypeInvalidReservation, &api.Problem{Detail: fmt.Sprintf("The upcoming reservations of a user must not add up to more than %v", quota), Field: "end", Value: info.End}
+		}
+	}
+	for _, rsv := range controller.Reservations {
+		if rsv.ID != id && rsv.Overlaps(info.Start, info.End) {
+			holder := rsv.ToUserInfo()
+			return api.TypeReservationConflict, &api.Problem{
+				Detail: fmt.Sprintf("The time slot overlaps with the reservation from %v to %v", rsv.Start.Format(time.RFC3339), rsv.End.Format(time.RFC3339)),
+				Holder: &holder,
+			}
+		}
+	}
+	return api.TypeReservationAdded, nil
+}
+
+// ownReservation returns the reservation with the id if the token may change it, being of
+// who reserved it or of an admin, and the feedback otherwise
+func (controller *Controller) ownReservation(token string, id string) (*api.Reservation, api.Role, *api.HandlerMessage) {
+	who, role, failure := controller.reserverOf(token)
+	if failure != nil {
+		return nil, role, failure
+	}
+	rsv := controller.findReservation(id)
+	if rsv == nil {
+		return nil, role, &api.HandlerMessage{Type: api.TypeReservationNotFound}
+	}
+	if role != api.RoleAdmin && !strings.EqualFold(rsv.Email, who.Email) {
+		return nil, role, &api.HandlerMessage{
+			Type:  api.TypeForbidden,
+			Value: []interface{}{api.Problem{Detail: "The reservation is of someone else"}},
+		}
+	}
+	return rsv, role, nil
+}
+
+// scheduleReservations sets ReservationTimer to the next start or end of a reservation after now
+func (controller *Controller) scheduleReservations(now time.Time) {
+	controller.ReservationTimer.Stop()
+	var next time.Time
+	for _, rsv := range controller.Reservations {
+		for _, t := range []time.Time{rsv.Start, rsv.End} {
+			if t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	if !next.IsZero() {
+		controller.ReservationTimer.Reset(time.Until(next))
+		log.Printf("[Reservation] Next boundary at %v", next)
+	}
+}
+
+// enforceReservations drops the past reservations and releases the current user
+// if the robot is reserved by someone else or the reservation of the user is over by now
+func (controller *Controller) enforceReservations(now time.Time) {
+	upcoming := controller.Reservations[:0]
+	for _, rsv := range controller.Reservations {
+		if rsv.End.After(now) {
+			upcoming = append(upcoming, rsv)
+		}
+	}
+	controller.Reservations = upcoming
+
+	// the robot may be free for the next user in the queue
+	if *controller.CurrentUser == (api.User{}) {
+		controller.promoteQueue()
+		return
+	}
+	// the admins are not bound to the reservations
+	if controller.CurrentUser.Role == api.RoleAdmin {
+		return
+	}
+	rsv := controller.activeReservation(now)
+	switch {
+	case rsv != nil && rsv.Email != controller.CurrentUser.Email:
+		log.Printf("[Reservation] Handing over to %v", rsv.Name)
+		controller.releaseUser("reservation")
+	case rsv == nil && controller.UserReservationID != "":
+		log.Printf("[Reservation] Reservation of %v is over", controller.CurrentUser.Name)
+		controller.releaseUser("reservation")
+	case rsv != nil:
+		// the user keeps the robot until the end of the slot
+		controller.UserReservationID = rsv.ID
+	}
+}
+
+// handleReservation processes the messages on the reservations
+func (controller *Controller) handleReservation(msg api.HandlerMessage) api.HandlerMessage {
+	switch msg.Type {
+	case api.TypeGetReservations:
+		controller.enforceReservations(time.Now())
+		rsvs := []api.ReservationInfo{}
+		for _, rsv := range controller.Reservations {
+			rsvs = append(rsvs, rsv.ToReservationInfo())
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentReservations,
+			Value: []interface{}{rsvs},
+		}
+	case api.TypeGetReservation:
+		id, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		rsv := controller.findReservation(id)
+		if rsv == nil {
+			return api.HandlerMessage{Type: api.TypeReservationNotFound}
+		}
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentReservation,
+			Value: []interface{}{rsv.ToReservationInfo()},
+		}
+	case api.TypeAddReservation:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		info, ok := msg.Value[1].(api.ReservationInfo)
+		if !ok {
+			break
+		}
+		who, role, failure := controller.reserverOf(token)
+		if failure != nil {
+			return *failure
+		}
+		// the admins may book for someone else
+		if role != api.RoleAdmin || info.Email == "" {
+			info.Name, info.Email = who.Name, who.Email
+		}
+		if t, p := controller.checkReservation(&info, nil, role); p != nil {
+			return api.HandlerMessage{
+				Type:  t,
+				Value: []interface{}{*p},
+			}
+		}
+		rsv := api.NewReservation(&info)
+		controller.Reservations = append(controller.Reservations, rsv)
+		sort.Slice(controller.Reservations, func(i, j int) bool {
+			return controller.Reservations[i].Start.Before(controller.Reservations[j].Start)
+		})
+		log.Printf("[Reservation] %v (%v) reserved from %v to %v", rsv.Name, rsv.Email, rsv.Start, rsv.End)
+		now := time.Now()
+		controller.enforceReservations(now)
+		controller.scheduleReservations(now)
+		return api.HandlerMessage{
+			Type:  api.TypeReservationAdded,
+			Value: []interface{}{*rsv},
+		}
+	case api.TypeUpdateReservation:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		id, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		info, ok := msg.Value[2].(api.ReservationInfo)
+		if !ok {
+			break
+		}
+		rsv, role, failure := controller.ownReservation(token, id)
+		if failure != nil {
+			return *failure
+		}
+		if role != api.RoleAdmin || info.Email == "" {
+			info.Name, info.Email = rsv.Name, rsv.Email
+		}
+		if t, p := controller.checkReservation(&info, rsv, role); p != nil {
+			return api.HandlerMessage{
+				Type:  t,
+				Value: []interface{}{*p},
+			}
+		}
+		rsv.Name, rsv.Email, rsv.Start, rsv.End = info.Name, info.Email, info.Start.UTC(), info.End.UTC()
+		sort.Slice(controller.Reservations, func(i, j int) bool {
+			return controller.Reservations[i].Start.Before(controller.Reservations[j].Start)
+		})
+		now := time.Now()
+		controller.enforceReservations(now)
+		controller.scheduleReservations(now)
+		return api.HandlerMessage{Type: api.TypeReservationUpdated}
+	case api.TypeDeleteReservation:
+		token, ok := msg.Value[0].(string)
+		if !ok {
+			break
+		}
+		id, ok := msg.Value[1].(string)
+		if !ok {
+			break
+		}
+		if _, _, failure := controller.ownReservation(token, id); failure != nil {
+			return *failure
+		}
+		for i, rsv := range controller.Reservations {
+			if rsv.ID == id {
+				controller.Reservations = append(controller.Reservations[:i], controller.Reservations[i+1:]...)
+				log.Printf("[Reservation] %v canceled the reservation from %v", rsv.Name, rsv.Start)
+				now := time.Now()
+				controller.enforceReservations(now)
+				controller.scheduleReservations(now)
+				return api.HandlerMessage{Type: api.TypeReservationDeleted}
+			}
+		}
+		return api.HandlerMessage{Type: api.TypeReservationNotFound}
+	case api.TypeReservationBoundary:
+		// the timer may fire a bit early by the wall clock, both need the same instant
+		// for the boundary to be enforced now or scheduled again
+		now := time.Now()
+		controller.enforceReservations(now)
+		controller.scheduleReservations(now)
+		return api.HandlerMessage{Type: api.TypeActionPerformed}
+	}
+	return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+}
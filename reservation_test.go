@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// reserve reserves the slot with the token and returns the response
+func reserve(h http.Handler, token string, start time.Time, end time.Time) *httptest.ResponseRecorder {
+	return serve(h, http.MethodPost, "/reservations", token, api.ReservationInfo{Start: start, End: end})
+}
+
+// TestReservationBoundaryUnderLoad checks that the user is released when the reservation
+// of someone else starts while the others poll the robot, and that the holder gets it
+func TestReservationBoundaryUnderLoad(t *testing.T) {
+	lead := *reservationLead
+	t.Cleanup(func() { *reservationLead = lead })
+	*reservationLead = 0
+	_, h := newTestController(t, nil)
+	carol := addTestKey(t, h, "carol", api.RoleOperator)
+	addTestUser(t, h, "alice")
+	if rec := reserve(h, carol.Token, time.Now().Add(time.Second), time.Now().Add(time.Minute)); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /reservations: %v %v", rec.Code, rec.Body)
+	}
+
+	done := make(chan struct{})
+	wg := pollPosture(t, h, 8, done)
+
+	deadline := time.After(5 * time.Second)
+	for currentUser(t, h).Name == "alice" {
+		select {
+		case <-deadline:
+			close(done)
+			t.Fatal("alice kept the robot in the reservation of carol")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// only the holder gets the robot in the slot
+	rec := serve(h, http.MethodPost, "/user", "", api.UserRequest{UserInfo: api.UserInfo{Name: "bob", Email: "bob@example.com"}})
+	if rec.Code == http.StatusCreated {
+		t.Errorf("bob got the robot in the reservation of carol")
+	}
+	addTestUser(t, h, "carol")
+}
+
+func TestReservationChecks(t *testing.T) {
+	_, h := newTestController(t, nil)
+	alice := addTestKey(t, h, "alice", api.RoleOperator)
+	bob := addTestKey(t, h, "bob", api.RoleOperator)
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+
+	mallory := api.ReservationInfo{Name: "mallory\r\nDTSTART:19700101T000000Z", Email: "mallory@example.com", Start: start, End: start.Add(time.Hour)}
+	if rec := serve(h, http.MethodPost, "/reservations", testMasterKey, mallory); rec.Code != http.StatusBadRequest {
+		t.Errorf("the name with the control characters: %v, want 400", rec.Code)
+	}
+	if rec := reserve(h, alice.Token, start, start.Add(2*time.Hour)); rec.Code != http.StatusBadRequest {
+		t.Errorf("the reservation longer than reservationMaxMinutes: %v, want 400", rec.Code)
+	}
+
+	// the quota is 3 hours by default
+	for i := 0; i < 3; i++ {
+		if rec := reserve(h, alice.Token, start.Add(time.Duration(i)*time.Hour), start.Add(time.Duration(i+1)*time.Hour)); rec.Code != http.StatusCreated {
+			t.Fatalf("the reservation %v within the quota: %v %v", i, rec.Code, rec.Body)
+		}
+	}
+	if rec := reserve(h, alice.Token, start.Add(3*time.Hour), start.Add(4*time.Hour)); rec.Code != http.StatusBadRequest {
+		t.Errorf("the reservation beyond the quota: %v, want 400", rec.Code)
+	}
+	if rec := reserve(h, bob.Token, start.Add(3*time.Hour), start.Add(4*time.Hour)); rec.Code != http.StatusCreated {
+		t.Errorf("the reservation of someone else: %v", rec.Code)
+	}
+	if rec := reserve(h, bob.Token, start.Add(210*time.Minute), start.Add(270*time.Minute)); rec.Code != http.StatusConflict {
+		t.Errorf("the overlapping reservation: %v, want 409", rec.Code)
+	}
+}
+
+// TestReservationAuth checks that the reservations are made with a key or the token of the current user,
+// only changed by who made them or an admin, and never take the robot from the current user at once
+func TestReservationAuth(t *testing.T) {
+	_, h := newTestController(t, nil)
+	observer := addTestKey(t, h, "observer", api.RoleObserver)
+	bob := addTestKey(t, h, "bob", api.RoleOperator)
+	token := addTestUser(t, h, "alice")
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+
+	if rec := reserve(h, "", start, start.Add(time.Hour)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /reservations without a token: %v, want 401", rec.Code)
+	}
+	if rec := reserve(h, api.GenerateToken(), start, start.Add(time.Hour)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /reservations with a random token: %v, want 401", rec.Code)
+	}
+	if rec := reserve(h, observer.Token, start, start.Add(time.Hour)); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /reservations with an observer key: %v, want 403", rec.Code)
+	}
+
+	// the current user reserves for themselves whatever the body says
+	info := api.ReservationInfo{Name: "carol", Email: "carol@example.com", Start: start, End: start.Add(time.Hour)}
+	rec := serve(h, http.MethodPost, "/reservations", token, info)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /reservations with the token of the current user: %v %v", rec.Code, rec.Body)
+	}
+	location := rec.Header().Get("Location")
+	id := location[strings.LastIndex(location, "/")+1:]
+	rec = serve(h, http.MethodGet, "/reservations/"+id, "", nil)
+	var got api.ReservationInfo
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got.Email != "alice@example.com" {
+		t.Errorf("GET /reservations/%v: %+v %v", id, got, err)
+	}
+
+	// a slot starting at once would take the robot from alice
+	if rec := reserve(h, bob.Token, time.Now(), time.Now().Add(time.Hour)); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /reservations starting now: %v, want 400", rec.Code)
+	}
+	if rec := reserve(h, bob.Token, time.Now().Add(-time.Minute), time.Now().Add(time.Minute)); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /reservations starting in the past: %v, want 400", rec.Code)
+	}
+	if currentUser(t, h).Name != "alice" {
+		t.Errorf("alice lost the robot to a refused reservation")
+	}
+
+	// only who reserved the slot or an admin changes it
+	info.End = start.Add(30 * time.Minute)
+	if rec := serve(h, http.MethodPut, "/reservations/"+id, bob.Token, info); rec.Code != http.StatusForbidden {
+		t.Errorf("PUT /reservations/%v by someone else: %v, want 403", id, rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/reservations/"+id, bob.Token, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE /reservations/%v by someone else: %v, want 403", id, rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, "/reservations/"+id, token, info); rec.Code != http.StatusNoContent {
+		t.Errorf("PUT /reservations/%v: %v %v", id, rec.Code, rec.Body)
+	}
+	info.Start = time.Now()
+	if rec := serve(h, http.MethodPut, "/reservations/"+id, token, info); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /reservations/%v starting now: %v, want 400", id, rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/reservations/"+id, testMasterKey, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /reservations/%v by an admin: %v", id, rec.Code)
+	}
+
+	// the admins are not bound to the lead time and may reserve for someone else
+	now := time.Now()
+	info = api.ReservationInfo{Name: "carol", Email: "carol@example.com", Start: now, End: now.Add(time.Hour)}
+	if rec := serve(h, http.MethodPost, "/reservations", testMasterKey, info); rec.Code != http.StatusCreated {
+		t.Errorf("POST /reservations by an admin: %v %v", rec.Code, rec.Body)
+	}
+	if user := currentUser(t, h); user.Name == "alice" {
+		t.Errorf("alice kept the robot in the reservation of carol")
+	}
+}
+
+func TestReservationCalendar(t *testing.T) {
+	_, h := newTestController(t, nil)
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	name := `Ada, Lovelace; \ the Countess of Lovelace and the first programmer of the Analytical Engine`
+	info := api.ReservationInfo{Name: name, Email: "ada@example.com", Start: start, End: start.Add(time.Hour)}
+	if rec := serve(h, http.MethodPost, "/reservations", testMasterKey, info); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /reservations: %v %v", rec.Code, rec.Body)
+	}
+
+	rec := serve(h, http.MethodGet, "/reservations.ics", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /reservations.ics: %v", rec.Code)
+	}
+	ics := rec.Body.String()
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("the line is not folded: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if want := `SUMMARY:Leubot reserved by Ada\, Lovelace\; \\ the Countess`; !strings.Contains(unfolded, want) {
+		t.Errorf("the summary is not escaped in\n%v", ics)
+	}
+	if want := "DTSTART:" + start.UTC().Format("20060102T150405Z") + "\r\n"; !strings.Contains(ics, want) {
+		t.Errorf("no %q in\n%v", want, ics)
+	}
+}
@@ -12,4 +12,16 @@ const (
 	Busy
 	// Sleeping - the robot is sleeping
 	Sleeping
+	// Stopped - the emergency stop is engaged
+	Stopped
 )
+
+func (rs RobotState) String() string {
+	return [...]string{
+		"Offline",
+		"Ready",
+		"Busy",
+		"Sleeping",
+		"Stopped",
+	}[rs]
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/armlink"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+)
+
+// scriptMaxSteps bounds the computation of a run of a script, the commands and the waits included
+const scriptMaxSteps = 10000000
+
+func init() {
+	// let the scripts loop and branch at the top level
+	resolve.AllowGlobalReassign = true
+}
+
+// scriptBuiltins lists the names the scripts may call besides the Starlark universe
+var scriptBuiltins = []string{
+	"base", "shoulder", "elbow", "wrist_angle", "wrist_rotation", "gripper",
+	"posture", "pose", "reset", "sleep", "wait", "current",
+}
+
+// scriptJoints maps the names in the scripts to the joint names
+var scriptJoints = map[string]string{
+	"base":           "base",
+	"shoulder":       "shoulder",
+	"elbow":          "elbow",
+	"wrist_angle":    "wristAngle",
+	"wrist_rotation": "wristRotation",
+	"gripper":        "gripper",
+}
+
+// checkScript returns a Problem if the script does not compile or calls an unknown function
+func checkScript(name string, script string) *api.Problem {
+	isPredeclared := func(s string) bool {
+		for _, builtin := range scriptBuiltins {
+			if builtin == s {
+				return true
+			}
+		}
+		return false
+	}
+	if _, _, err := starlark.SourceProgram(name+".star", script, isPredeclared); err != nil {
+		return &api.Problem{Detail: err.Error(), Field: "script"}
+	}
+	return nil
+}
+
+// scriptRun is a script being run with the token, sending each command through HandlerChannel
+// like the requests; the waits are divided by the speed and end early when stop is closed
+type scriptRun struct {
+	controller *Controller
+	token      string
+	speed      float64
+	stop       <-chan struct{}
+	out        io.Writer
+}
+
+// send sends the command through HandlerChannel, returns an error if it was refused
+func (sr *scriptRun) send(msg api.HandlerMessage) error {
+	reply, ok := api.Request(sr.controller.HandlerChannel, msg)
+	if !ok {
+		return errors.New("HandlerChannel closed")
+	}
+	fmt.Fprintf(sr.out, "%v %v: %v\n", msg.Type, auditValues(msg), reply.Type)
+	if reply.Type != api.TypeActionPerformed {
+		p := api.Problem{}
+		if len(reply.Value) > 0 {
+			p, _ = reply.Value[0].(api.Problem)
+		}
+		return fmt.Errorf("%v refused with %v: %v", msg.Type, reply.Type, p.Detail)
+	}
+	return nil
+}
+
+// current returns the current pose through HandlerChannel
+func (sr *scriptRun) current() (api.RobotPose, error) {
+	reply, ok := api.Request(sr.controller.HandlerChannel, api.HandlerMessage{Type: api.TypeGetPosture})
+	if !ok || reply.Type != api.TypeCurrentPosture || len(reply.Value) == 0 {
+		return api.RobotPose{}, fmt.Errorf("current: no pose but %v", reply.Type)
+	}
+	rp, ok := reply.Value[0].(api.RobotPose)
+	if !ok {
+		return api.RobotPose{}, fmt.Errorf("current: unexpected %T", reply.Value[0])
+	}
+	return rp, nil
+}
+
+// delta returns the delta scaled by the speed, the default if it is None
+func (sr *scriptRun) delta(v starlark.Value) (uint8, error) {
+	if v == nil || v == starlark.None {
+		return *defaultDelta, nil
+	}
+	d, err := starlark.AsInt32(v)
+	if err != nil || d < 0 || d > 254 {
+		return 0, fmt.Errorf("delta must be an int within [0, 254], not %v", v)
+	}
+	return scaleDelta(uint8(d), sr.speed), nil
+}
+
+// position returns the int as a joint position
+func position(joint string, v int) (uint16, error) {
+	if v < 0 || v > 0xffff {
+		return 0, fmt.Errorf("%v: %v is not a joint position", joint, v)
+	}
+	return uint16(v), nil
+}
+
+// builtins returns the functions of the script bound to the run
+func (sr *scriptRun) builtins() starlark.StringDict {
+	builtins := starlark.StringDict{}
+	for name, joint := range scriptJoints {
+		name, joint := name, joint
+		builtins[name] = starlark.NewBuiltin(name, func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var v int
+			if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &v); err != nil {
+				return nil, err
+			}
+			value, err := position(name, v)
+			if err != nil {
+				return nil, err
+			}
+			for msgType, j := range putJoints {
+				if j == joint {
+					return starlark.None, sr.send(api.HandlerMessage{Type: msgType, Value: []interface{}{api.RobotCommand{Token: sr.token, Value: value}}})
+				}
+			}
+			return nil, fmt.Errorf("%v: no such joint", name)
+		})
+	}
+	builtins["posture"] = starlark.NewBuiltin("posture", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		// the joints left out stay where they are
+		rp, err := sr.current()
+		if err != nil {
+			return nil, err
+		}
+		values := map[string]*int{}
+		for name, joint := range scriptJoints {
+			v := int(rp.Get(joint))
+			values[name] = &v
+		}
+		var delta starlark.Value
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs,
+			"base?", values["base"], "shoulder?", values["shoulder"], "elbow?", values["elbow"],
+			"wrist_angle?", values["wrist_angle"], "wrist_rotation?", values["wrist_rotation"], "gripper?", values["gripper"],
+			"delta?", &delta); err != nil {
+			return nil, err
+		}
+		for name, joint := range scriptJoints {
+			value, err := position(name, *values[name])
+			if err != nil {
+				return nil, err
+			}
+			rp.Set(joint, value)
+		}
+		d, err := sr.delta(delta)
+		if err != nil {
+			return nil, err
+		}
+		return starlark.None, sr.send(api.HandlerMessage{
+			Type: api.TypePutPosture,
+			Value: []interface{}{api.PostureCommand{
+				Token:         sr.token,
+				Base:          rp.Base,
+				Shoulder:      rp.Shoulder,
+				Elbow:         rp.Elbow,
+				WristAngle:    rp.WristAngle,
+				WristRotation: rp.WristRotation,
+				Gripper:       rp.Gripper,
+				Delta:         d,
+			}},
+		})
+	})
+	builtins["pose"] = starlark.NewBuiltin("pose", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var name string
+		var delta starlark.Value
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "name", &name, "delta?", &delta); err != nil {
+			return nil, err
+		}
+		poseCom := api.PoseCommand{Token: sr.token, Name: name}
+		if delta != nil && delta != starlark.None {
+			d, err := sr.delta(delta)
+			if err != nil {
+				return nil, err
+			}
+			poseCom.Delta = &d
+		}
+		return starlark.None, sr.send(api.HandlerMessage{Type: api.TypeApplyPose, Value: []interface{}{poseCom}})
+	})
+	builtins["reset"] = starlark.NewBuiltin("reset", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		return starlark.None, sr.send(api.HandlerMessage{Type: api.TypePutReset, Value: []interface{}{sr.token}})
+	})
+	builtins["sleep"] = starlark.NewBuiltin("sleep", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		return starlark.None, sr.send(api.HandlerMessage{Type: api.TypePutSleep, Value: []interface{}{sr.token}})
+	})
+	builtins["wait"] = starlark.NewBuiltin("wait", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var ms int
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &ms); err != nil {
+			return nil, err
+		}
+		if ms < 0 {
+			return nil, fmt.Errorf("wait: %v ms is negative", ms)
+		}
+		select {
+		case <-sr.stop:
+			return nil, fmt.Errorf("stopped")
+		case <-time.After(time.Duration(float64(ms)/sr.speed) * time.Millisecond):
+		}
+		return starlark.None, nil
+	})
+	builtins["current"] = starlark.NewBuiltin("current", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		rp, err := sr.current()
+		if err != nil {
+			return nil, err
+		}
+		d := starlark.NewDict(len(scriptJoints))
+		for name, joint := range scriptJoints {
+			d.SetKey(starlark.String(name), starlark.MakeInt(int(rp.Get(joint))))
+		}
+		return d, nil
+	})
+	return builtins
+}
+
+// run executes the script, returns the error of the script or of the first refused command
+func (sr *scriptRun) run(name string, script string) error {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(sr.out, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-sr.stop:
+			thread.Cancel("stopped")
+		case <-done:
+		}
+	}()
+	_, err := starlark.ExecFile(thread, name+".star", script, sr.builtins())
+	return err
+}
+
+// runFile runs the script in the file against a simulated arm without the waits, printing the
+// commands and their feedback to out, to validate the script offline; the script runs with a key
+// of the role for the email, checked against the envelope kept in the store if any
+func runFile(path string, out io.Writer, version string, store *Store, role api.Role, email string) error {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if p := checkScript(name, string(script)); p != nil {
+		return errors.New(p.Detail)
+	}
+	controller := NewController(armlink.NewSimulatedArmLinkSerial(), nil, "", version)
+	defer controller.Shutdown()
+	var snap snapshot
+	if _, err := store.Get("snapshot", &snap); err != nil {
+		return err
+	}
+	controller.restoreEnvelope(&snap)
+	// run with a key only known here
+	key, token := api.NewAPIKey(&api.APIKeyRequest{Name: "leubot run", Email: email, Role: role})
+	controller.Keys = append(controller.Keys, key)
+	sr := &scriptRun{controller: controller, token: token, speed: math.Inf(1), stop: make(chan struct{}), out: out}
+	return sr.run(name, string(script))
+}
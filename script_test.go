@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+func TestCheckScript(t *testing.T) {
+	for script, ok := range map[string]bool{
+		"base(400)\nposture(elbow=500, delta=10)":                   true,
+		"for i in range(3):\n    wait(100)\n    base(300 + 50 * i)": true,
+		"p = current()\nbase(p['base'] + 10)":                       true,
+		"fire()":                                                    false,
+		"base(400":                                                  false,
+	} {
+		if p := checkScript("test", script); (p == nil) != ok {
+			t.Errorf("checkScript(%q) = %v", script, p)
+		}
+	}
+}
+
+// writeScript writes the script to a file in the temporary directory of the test
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+	path := t.TempDir() + "/test.star"
+	if err := os.WriteFile(path, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunFile(t *testing.T) {
+	// the admins narrowed the base for the operators and added a pose
+	store := openTestStore(t)
+	js, _ := json.Marshal(snapshot{
+		SoftLimits: map[string]api.JointRange{"base": {Min: 400, Max: 600}},
+		Poses:      []*api.NamedPose{{Name: "ready", Pose: &api.RobotPose{Base: 450, Shoulder: 400, Elbow: 400, WristAngle: 580, WristRotation: 512, Gripper: 256}}},
+	})
+	if err := store.Put("snapshot", js); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		script string
+		role   api.Role
+		store  *Store
+		fails  string
+	}{
+		{"base(500)\npose('ready')\nprint(current()['base'])", api.RoleOperator, store, ""},
+		{"base(700)", api.RoleOperator, store, "TypeInvalidCommand"},
+		{"base(700)", api.RoleAdmin, store, ""},
+		{"base(700)", api.RoleOperator, nil, ""},
+		{"pose('ready')", api.RoleOperator, nil, "TypePoseNotFound"},
+		{"base(500)", api.RoleObserver, store, "TypeForbidden"},
+		{"for i in range(100000000):\n    pass", api.RoleAdmin, nil, "too many steps"},
+	} {
+		var out strings.Builder
+		err := runFile(writeScript(t, tc.script), &out, "v1", tc.store, tc.role, "")
+		switch {
+		case tc.fails == "" && err != nil:
+			t.Errorf("%q as %v: %v", tc.script, tc.role, err)
+		case tc.fails != "" && (err == nil || !strings.Contains(err.Error(), tc.fails)):
+			t.Errorf("%q as %v: %v, want %v", tc.script, tc.role, err, tc.fails)
+		}
+	}
+}
+
+func TestReplayScript(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	program := api.Program{Name: "sweep", Script: "for i in range(5):\n    base(400 + 50 * i)\nposture(elbow=current()['elbow'] + 10, delta=1)"}
+	if rec := serve(h, http.MethodPost, "/programs", token, program); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /programs: %v %v", rec.Code, rec.Body)
+	}
+	elbow := currentPose(t, h).Elbow
+
+	done := make(chan struct{})
+	wg := pollPosture(t, h, 8, done)
+	if rec := serve(h, http.MethodPut, "/programs/sweep/replay", token, api.Replay{}); rec.Code != http.StatusAccepted {
+		close(done)
+		t.Fatalf("PUT /programs/sweep/replay: %v %v", rec.Code, rec.Body)
+	}
+	deadline := time.After(5 * time.Second)
+	for rp := currentPose(t, h); rp.Base != 600 || rp.Elbow != elbow+10; rp = currentPose(t, h) {
+		select {
+		case <-deadline:
+			close(done)
+			t.Fatalf("the script stopped at %v", rp)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/kinematics"
+)
+
+// travel returns the most degrees any joint turns from the pose to the target
+func travel(from api.RobotPose, to api.RobotPose) float64 {
+	ticks := 0.0
+	for _, joint := range api.JointNames {
+		ticks = math.Max(ticks, math.Abs(float64(to.Get(joint))-float64(from.Get(joint))))
+	}
+	return ticks * 360 / kinematics.TicksPerTurn
+}
+
+// speedDelta returns the delta moving from the pose to the target in the duration or at the
+// top speed if given, the delta otherwise; ArmLink moves at once for up to the longest delta
+func speedDelta(from api.RobotPose, to api.RobotPose, delta uint8, speed api.Speed) (uint8, *api.Problem) {
+	longest := moveTime(uint8(api.JointRanges["delta"].Max))
+	var d time.Duration
+	switch {
+	case speed.DurationMs != nil && speed.MaxVelocity != nil:
+		return 0, &api.Problem{Detail: "Give either the duration or the top speed of the move", Field: "maxVelocity", Value: *speed.MaxVelocity}
+	case speed.DurationMs != nil:
+		if *speed.DurationMs < 0 || *speed.DurationMs > longest.Milliseconds() {
+			return 0, &api.Problem{Detail: fmt.Sprintf("The duration must be within [0, %v] ms", longest.Milliseconds()), Field: "durationMs", Value: *speed.DurationMs}
+		}
+		d = time.Duration(*speed.DurationMs) * time.Millisecond
+	case speed.MaxVelocity != nil:
+		if !(*speed.MaxVelocity > 0) {
+			return 0, &api.Problem{Detail: "The top speed must be positive", Field: "maxVelocity", Value: *speed.MaxVelocity}
+		}
+		degrees := travel(from, to)
+		d = time.Duration(degrees / *speed.MaxVelocity * float64(time.Second))
+		if d > longest {
+			return 0, &api.Problem{Detail: fmt.Sprintf("Turning %.1f° takes longer than %v ms, the top speed must be at least %.1f °/s", degrees, longest.Milliseconds(), degrees/longest.Seconds()), Field: "maxVelocity", Value: *speed.MaxVelocity}
+		}
+	default:
+		return delta, nil
+	}
+	// never faster than asked
+	return uint8(math.Ceil(float64(d) / float64(ticksTime))), nil
+}
+
+// completion returns when the move with the delta sent now completes
+func completion(delta uint8) api.Completion {
+	d := moveTime(delta)
+	return api.Completion{
+		Delta:       delta,
+		DurationMs:  d.Milliseconds(),
+		CompletesAt: time.Now().UTC().Add(d),
+	}
+}
+
+// replyCompletion returns the completion in the reply to a move, false if there's none
+func replyCompletion(reply api.HandlerMessage) (api.Completion, bool) {
+	for _, v := range reply.Value {
+		if c, ok := v.(api.Completion); ok {
+			return c, true
+		}
+	}
+	return api.Completion{}, false
+}
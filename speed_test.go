@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+func TestSpeedDelta(t *testing.T) {
+	to := homePose
+	// half a turn of the servo, 150°
+	to.Base += 512
+	if d := travel(homePose, to); d != 150 {
+		t.Errorf("travel: %v, want 150", d)
+	}
+
+	ms := func(v int64) *int64 { return &v }
+	vel := func(v float64) *float64 { return &v }
+	for name, tc := range map[string]struct {
+		speed api.Speed
+		delta uint8
+		field string
+	}{
+		"no speed":           {api.Speed{}, 100, ""},
+		"duration":           {api.Speed{DurationMs: ms(1000)}, 63, ""},
+		"no duration":        {api.Speed{DurationMs: ms(0)}, 0, ""},
+		"longest duration":   {api.Speed{DurationMs: ms(4064)}, 254, ""},
+		"too long":           {api.Speed{DurationMs: ms(4065)}, 0, "durationMs"},
+		"negative duration":  {api.Speed{DurationMs: ms(-1)}, 0, "durationMs"},
+		"top speed":          {api.Speed{MaxVelocity: vel(150)}, 63, ""},
+		"too slow":           {api.Speed{MaxVelocity: vel(30)}, 0, "maxVelocity"},
+		"no top speed":       {api.Speed{MaxVelocity: vel(0)}, 0, "maxVelocity"},
+		"duration and speed": {api.Speed{DurationMs: ms(1000), MaxVelocity: vel(150)}, 0, "maxVelocity"},
+	} {
+		delta, p := speedDelta(homePose, to, 100, tc.speed)
+		switch {
+		case tc.field == "" && p != nil:
+			t.Errorf("speedDelta with %v: %+v", name, p)
+		case tc.field != "" && (p == nil || p.Field != tc.field):
+			t.Errorf("speedDelta with %v: %+v, want a problem on %v", name, p, tc.field)
+		case tc.field == "" && delta != tc.delta:
+			t.Errorf("speedDelta with %v: %v, want %v", name, delta, tc.delta)
+		}
+	}
+}
+
+func TestMoveDuration(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+
+	rec := serve(h, http.MethodPut, "/base", token, map[string]interface{}{"value": 450, "durationMs": 500})
+	var c api.Completion
+	if err := json.NewDecoder(rec.Body).Decode(&c); rec.Code != http.StatusAccepted || err != nil {
+		t.Fatalf("PUT /base: %v %v", rec.Code, err)
+	}
+	// never faster than asked
+	if c.Delta != 32 || c.DurationMs != 512 || c.CompletesAt.IsZero() {
+		t.Errorf("PUT /base: %+v", c)
+	}
+	if rec := serve(h, http.MethodPut, "/base", token, map[string]interface{}{"value": 460, "durationMs": 5000}); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /base taking too long: %v, want 400", rec.Code)
+	}
+}
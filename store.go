@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	bolt "go.etcd.io/bbolt"
+)
+
+// storeBucket is the bucket holding the state in the Store
+var storeBucket = []byte("leubot")
+
+// Store persists the state of the controller across restarts in a BoltDB file,
+// a nil Store keeps nothing
+type Store struct {
+	db *bolt.DB
+	// counts holds the number of the entries in the append-only buckets once Trim counted them
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// OpenStore opens or creates the Store at the path
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(storeBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, counts: map[string]int{}}, nil
+}
+
+// OpenStoreReadOnly opens the Store at the path only to read it, which fails while Leubot serves with it
+func OpenStoreReadOnly(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, counts: map[string]int{}}, nil
+}
+
+// Put stores the JSON under the name
+func (store *Store) Put(name string, js []byte) error {
+	if store == nil {
+		return nil
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(storeBucket).Put([]byte(name), js)
+	})
+}
+
+// Get decodes the JSON stored under the name into v, returns false if there's none
+func (store *Store) Get(name string, v interface{}) (bool, error) {
+	if store == nil {
+		return false, nil
+	}
+	var js []byte
+	store.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(storeBucket).Get([]byte(name)); b != nil {
+			js = append([]byte{}, b...)
+		}
+		return nil
+	})
+	if js == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(js, v)
+}
+
+// Append adds the JSON to the append-only bucket, keyed by the time in big-endian
+// nanoseconds so that the entries are in order
+func (store *Store) Append(bucket string, t time.Time, js []byte) error {
+	if store == nil {
+		return nil
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		// after the last one even if the clock went back or the time repeats
+		n := uint64(t.UnixNano())
+		if last, _ := b.Cursor().Last(); last != nil && binary.BigEndian.Uint64(last) >= n {
+			n = binary.BigEndian.Uint64(last) + 1
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, n)
+		return b.Put(key, js)
+	})
+	if n, ok := store.counts[bucket]; ok && err == nil {
+		store.counts[bucket] = n + 1
+	}
+	return err
+}
+
+// Trim deletes the oldest JSON appended to the bucket beyond the max; the entries are
+// only counted the first time, after that Append and Trim keep the count
+func (store *Store) Trim(bucket string, max int) error {
+	if store == nil {
+		return nil
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	n, ok := store.counts[bucket]
+	if ok && n <= max {
+		return nil
+	}
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			n = 0
+			return nil
+		}
+		if !ok {
+			n = b.Stats().KeyN
+		}
+		c := b.Cursor()
+		for ; n > max; n-- {
+			k, _ := c.First()
+			if k == nil {
+				n = 0
+				break
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	store.counts[bucket] = n
+	return nil
+}
+
+// Scan calls fn with the JSON appended to the bucket from the time on, until fn returns false
+func (store *Store) Scan(bucket string, from time.Time, fn func(js []byte) bool) error {
+	if store == nil {
+		return nil
+	}
+	return store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		start := make([]byte, 8)
+		if !from.IsZero() && from.UnixNano() > 0 {
+			binary.BigEndian.PutUint64(start, uint64(from.UnixNano()))
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(start); k != nil; k, v = c.Next() {
+			if !fn(v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the Store
+func (store *Store) Close() error {
+	if store == nil {
+		return nil
+	}
+	return store.db.Close()
+}
+
+// snapshot is the state of the controller kept in the Store
+type snapshot struct {
+	User              api.User
+	UserDeadline      time.Time
+	UserReservationID string
+	Pose              api.RobotPose
+	State             RobotState
+	Keys              []*api.APIKey
+	SoftLimits        map[string]api.JointRange
+	Profiles          []*api.SafetyProfile
+	Poses             []*api.NamedPose
+	Programs          []*api.Program
+	Reservations      []*api.Reservation
+	Revoked           map[string]time.Time
+	Workspace         *api.Workspace
+	Constraints       []api.Constraint
+	ActionSettings    *api.ActionSettings
+}
+
+// persist writes the snapshot of the controller to the Store if it changed
+func (controller *Controller) persist() {
+	if controller.Store == nil {
+		return
+	}
+	js, err := json.Marshal(snapshot{
+		User:              *controller.CurrentUser,
+		UserDeadline:      controller.UserDeadline,
+		UserReservationID: controller.UserReservationID,
+		Pose:              *controller.CurrentRobotPose,
+		State:             controller.CurrentRobotState,
+		Keys:              controller.Keys,
+		SoftLimits:        controller.SoftLimits,
+		Profiles:          controller.Profiles,
+		Poses:             controller.Poses,
+		Programs:          controller.Programs,
+		Reservations:      controller.Reservations,
+		Revoked:           controller.Revoked,
+		Workspace:         &controller.Workspace,
+		Constraints:       controller.Constraints,
+		ActionSettings:    &controller.ActionSettings,
+	})
+	if err != nil {
+		log.Printf("[Store] %v", err)
+		return
+	}
+	if bytes.Equal(js, controller.LastSnapshot) {
+		return
+	}
+	if err := controller.Store.Put("snapshot", js); err != nil {
+		log.Printf("[Store] %v", err)
+		return
+	}
+	controller.LastSnapshot = js
+}
+
+// restore takes over the state from the Store, returns false if there's nothing to restore
+func (controller *Controller) restore() bool {
+	var snap snapshot
+	ok, err := controller.Store.Get("snapshot", &snap)
+	if err != nil {
+		log.Printf("[Store] %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	// the master key is always of the current --masterTokenHash,
+	// and the keys kept before hashing the tokens need to be issued again
+	for _, key := range snap.Keys {
+		if key.ID == masterKeyID {
+			continue
+		}
+		if key.TokenHash == "" {
+			log.Printf("[Store] Dropping the %v key %v of %v without the token hash", key.Role, key.ID, key.Name)
+			continue
+		}
+		controller.Keys = append(controller.Keys, key)
+	}
+	for id, expires := range snap.Revoked {
+		controller.Revoked[id] = expires
+	}
+	controller.restoreEnvelope(&snap)
+	controller.Programs = snap.Programs
+	controller.Reservations = snap.Reservations
+	controller.CurrentRobotPose = &snap.Pose
+
+	// keep the robot still if the emergency stop was engaged
+	if snap.State == Stopped {
+		log.Println("[Store] Restored the emergency stop")
+		controller.setState(Stopped)
+	}
+
+	// the user is gone if the session expired in the meantime
+	if snap.User == (api.User{}) || (*userTimeout != 0 && !time.Now().Before(snap.UserDeadline)) {
+		if snap.State != Stopped {
+			controller.SleepRobot()
+		}
+		return true
+	}
+	log.Printf("[Store] Restored the session of %v", snap.User.Name)
+	controller.CurrentUser = &snap.User
+	controller.UserReservationID = snap.UserReservationID
+	sessionsStarted.Inc()
+	controller.resumeRobot(snap.State, snap.UserDeadline)
+	return true
+}
+
+// restoreEnvelope takes over what the commands are checked against from the snapshot: the soft
+// limits, the safety profiles, the named poses, the workspace, the constraints and the action settings
+func (controller *Controller) restoreEnvelope(snap *snapshot) {
+	for joint, jr := range snap.SoftLimits {
+		controller.SoftLimits[joint] = jr
+	}
+	controller.Profiles = snap.Profiles
+	controller.Poses = snap.Poses
+	if snap.Workspace != nil {
+		controller.Workspace = *snap.Workspace
+	}
+	if snap.Constraints != nil {
+		controller.Constraints = snap.Constraints
+	}
+	if snap.ActionSettings != nil {
+		controller.ActionSettings = *snap.ActionSettings
+	}
+}
+
+// resumeRobot moves the robot back to CurrentRobotPose instead of resetting it,
+// and restarts the UserTimer until the deadline
+func (controller *Controller) resumeRobot(state RobotState, deadline time.Time) {
+	switch state {
+	case Ready, Busy:
+		switchLight(true)
+		controller.sendPose(*defaultDelta)
+		controller.setState(Ready)
+	case Sleeping:
+		controller.SleepRobot()
+	}
+
+	if *userTimeout != 0 {
+		controller.UserTimerRunning = true
+		controller.UserTimer.Reset(time.Until(deadline))
+		controller.UserDeadline = deadline
+		log.Printf("[UserTimer] Resumed for %v", controller.CurrentUser.Name)
+		go controller.watchUserTimer()
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// scanAll returns the JSON appended to the bucket in order
+func scanAll(t *testing.T, store *Store, bucket string) []string {
+	t.Helper()
+	var all []string
+	if err := store.Scan(bucket, time.Time{}, func(js []byte) bool {
+		all = append(all, string(js))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestTrim(t *testing.T) {
+	path := t.TempDir() + "/leubot.db"
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Trim("history", 5); err != nil {
+		t.Fatalf("Trim of no bucket: %v", err)
+	}
+
+	// the entries at the same time keep their order
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := store.Append("history", now, []byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Trim("history", 5); err != nil {
+			t.Fatal(err)
+		}
+		want := i + 1
+		if want > 5 {
+			want = 5
+		}
+		if n := store.counts["history"]; n != want {
+			t.Fatalf("after %v appends the count is %v", i+1, n)
+		}
+	}
+	if all := scanAll(t, store, "history"); len(all) != 5 || all[0] != "5" || all[4] != "9" {
+		t.Fatalf("Scan after Trim: %v", all)
+	}
+	store.Close()
+
+	// the entries are counted again after the restart
+	store, err = OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Append("history", now, []byte("10"))
+	if err := store.Trim("history", 3); err != nil {
+		t.Fatal(err)
+	}
+	if all := scanAll(t, store, "history"); len(all) != 3 || all[0] != "8" || store.counts["history"] != 3 {
+		t.Fatalf("Scan after the restart: %v, counted %v", all, store.counts["history"])
+	}
+}
+
+// putSnapshot changes the snapshot in the store before the restart
+func putSnapshot(t *testing.T, store *Store, change func(snap *snapshot)) {
+	t.Helper()
+	var snap snapshot
+	if ok, err := store.Get("snapshot", &snap); !ok || err != nil {
+		t.Fatalf("no snapshot: %v", err)
+	}
+	change(&snap)
+	js, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("snapshot", js); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	store := openTestStore(t)
+	before, h := newTestController(t, store)
+	token := addTestUser(t, h, "alice")
+	if code := moveBase(h, token, 450); code != http.StatusAccepted {
+		t.Fatalf("PUT /base: %v", code)
+	}
+	// the snapshot is kept once the next request is taken
+	currentUser(t, h)
+
+	// the same user with the same token and the rest of the session, the robot moved back
+	// to the pose instead of being reset
+	after, h := newTestController(t, store)
+	if after.CurrentUser.Name != "alice" || !after.UserDeadline.Equal(before.UserDeadline) || !after.UserTimerRunning {
+		t.Errorf("the session after the restart: %+v until %v", after.CurrentUser, after.UserDeadline)
+	}
+	if *after.CurrentRobotPose != *before.CurrentRobotPose || after.CurrentRobotPose.Base != 450 || after.CurrentRobotState != Ready {
+		t.Errorf("the robot after the restart: %v %v", after.CurrentRobotPose, after.CurrentRobotState)
+	}
+	if want := after.CurrentRobotPose.BuildArmLinkPacket(*defaultDelta).Bytes(); len(after.SentPackets) != 1 || !bytes.Equal(after.SentPackets[0], want) {
+		t.Errorf("the packets sent on the restart: %x, want %x", after.SentPackets, want)
+	}
+	rec := serve(h, http.MethodGet, "/status", "", nil)
+	var status api.Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil || !status.UserActive || status.SessionRemaining <= 0 || status.SessionRemaining > int64(*userTimeout) {
+		t.Errorf("GET /status after the restart: %+v %v", status, err)
+	}
+	if code := moveBase(h, token, 460); code != http.StatusAccepted {
+		t.Errorf("PUT /base with the token after the restart: %v", code)
+	}
+	currentUser(t, h)
+
+	// the session expired in the meantime
+	putSnapshot(t, store, func(snap *snapshot) { snap.UserDeadline = time.Now().Add(-time.Minute) })
+	expired, h := newTestController(t, store)
+	if *expired.CurrentUser != (api.User{}) || expired.CurrentRobotState != Sleeping {
+		t.Errorf("the expired session after the restart: %+v %v", expired.CurrentUser, expired.CurrentRobotState)
+	}
+	if code := moveBase(h, token, 470); code == http.StatusAccepted {
+		t.Errorf("PUT /base with the token of the expired session: %v", code)
+	}
+	currentUser(t, h)
+
+	// the emergency stop keeps the robot still
+	putSnapshot(t, store, func(snap *snapshot) {
+		snap.User = *after.CurrentUser
+		snap.UserDeadline = time.Now().Add(time.Minute)
+		snap.State = Stopped
+	})
+	stopped, h := newTestController(t, store)
+	if stopped.CurrentUser.Name != "alice" || stopped.CurrentRobotState != Stopped || len(stopped.SentPackets) != 0 {
+		t.Errorf("the emergency stop after the restart: %+v %v %x", stopped.CurrentUser, stopped.CurrentRobotState, stopped.SentPackets)
+	}
+	if code := moveBase(h, token, 470); code != http.StatusConflict {
+		t.Errorf("PUT /base during the restored emergency stop: %v, want 409", code)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// loadTokenSecret takes the key signing the session tokens from the Store, so that
+// the tokens stay valid across restarts, or keeps a new one there
+func (controller *Controller) loadTokenSecret() {
+	var secret []byte
+	ok, err := controller.Store.Get("tokenSecret", &secret)
+	if err != nil {
+		log.Printf("[Store] %v", err)
+	}
+	if ok && len(secret) > 0 {
+		api.TokenSecret = secret
+		return
+	}
+	js, _ := json.Marshal(api.TokenSecret)
+	if err := controller.Store.Put("tokenSecret", js); err != nil {
+		log.Printf("[Store] %v", err)
+	}
+}
+
+// verifyToken checks the session token against the revocation list
+func (controller *Controller) verifyToken(token string) (*api.SessionClaims, error) {
+	claims, err := api.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := controller.Revoked[claims.ID]; ok {
+		return nil, errors.New("token has been revoked")
+	}
+	return claims, nil
+}
+
+// revoke adds the token ID to the revocation list until the token expires,
+// dropping the tokens which expired meanwhile
+func (controller *Controller) revoke(id string, expires time.Time) {
+	now := time.Now()
+	for revoked, exp := range controller.Revoked {
+		if !now.Before(exp) {
+			delete(controller.Revoked, revoked)
+		}
+	}
+	if id != "" && now.Before(expires) {
+		controller.Revoked[id] = expires
+	}
+}
+
+// refreshToken issues a new session token for the current user in place of the given one
+func (controller *Controller) refreshToken(token string) api.HandlerMessage {
+	if controller.findKey(token) != nil {
+		return api.HandlerMessage{
+			Type:  api.TypeInvalidToken,
+			Value: []interface{}{api.Problem{Detail: "API keys do not expire"}},
+		}
+	}
+	claims, err := controller.verifyToken(token)
+	if err != nil {
+		return api.HandlerMessage{
+			Type:  api.TypeInvalidToken,
+			Value: []interface{}{api.Problem{Detail: "The token is invalid: " + err.Error()}},
+		}
+	}
+	if claims.Session != controller.CurrentUser.Session {
+		return api.HandlerMessage{
+			Type:  api.TypeInvalidToken,
+			Value: []interface{}{api.Problem{Detail: "The session of the token has ended"}},
+		}
+	}
+
+	// the old token is no longer valid
+	controller.revoke(claims.ID, claims.ExpiresAt.Time)
+	if err := api.IssueToken(controller.CurrentUser); err != nil {
+		return api.HandlerMessage{
+			Type:  api.TypeSomethingWentWrong,
+			Value: []interface{}{api.Problem{Detail: err.Error()}},
+		}
+	}
+	log.Printf("[Token] Refreshed the token of %v until %v", controller.CurrentUser.Name, controller.CurrentUser.Expires)
+	return api.HandlerMessage{
+		Type:  api.TypeTokenRefreshed,
+		Value: []interface{}{*controller.CurrentUser},
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// moveBase moves the base with the token and returns the status
+func moveBase(h http.Handler, token string, value int) int {
+	return serve(h, http.MethodPut, "/base", token, map[string]interface{}{"value": value}).Code
+}
+
+func TestRefreshToken(t *testing.T) {
+	_, h := newTestController(t, nil)
+	old := addTestUser(t, h, "alice")
+
+	rec := serve(h, http.MethodPost, "/token", old, nil)
+	var token api.Token
+	if err := json.NewDecoder(rec.Body).Decode(&token); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /token: %v %v", rec.Code, err)
+	}
+	if token.Token == old || !token.Expires.After(time.Now()) {
+		t.Fatalf("POST /token: %+v", token)
+	}
+	if code := moveBase(h, token.Token, 450); code != http.StatusAccepted {
+		t.Errorf("PUT /base with the new token: %v", code)
+	}
+
+	// the old token is revoked
+	if code := moveBase(h, old, 460); code != http.StatusUnauthorized {
+		t.Errorf("PUT /base with the revoked token: %v, want 401", code)
+	}
+	if rec := serve(h, http.MethodPost, "/token", old, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /token with the revoked token: %v, want 401", rec.Code)
+	}
+
+	// the tokens of the session end with it
+	if rec := serve(h, http.MethodDelete, "/user/"+token.Token, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /user: %v", rec.Code)
+	}
+	addTestUser(t, h, "bob")
+	if code := moveBase(h, token.Token, 470); code != http.StatusUnauthorized {
+		t.Errorf("PUT /base with the token of the ended session: %v, want 401", code)
+	}
+	if rec := serve(h, http.MethodPost, "/token", token.Token, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /token of the ended session: %v, want 401", rec.Code)
+	}
+}
+
+func TestInvalidTokens(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	claims, err := api.VerifyToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// expired, or signed with another secret or method
+	expired := *claims
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	js, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, expired).SignedString(api.TokenSecret)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("not the secret"))
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+
+	for name, tok := range map[string]string{"expired": js, "forged": forged, "unsigned": none, "tampered": tampered, "random": api.GenerateToken()} {
+		if code := moveBase(h, tok, 450); code != http.StatusUnauthorized {
+			t.Errorf("PUT /base with the %v token: %v, want 401", name, code)
+		}
+	}
+	if code := moveBase(h, token, 450); code != http.StatusAccepted {
+		t.Errorf("PUT /base with the token: %v", code)
+	}
+}
+
+func TestTokensNotLogged(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(io.Discard)
+
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	moveBase(h, token, 450)
+	moveBase(h, api.GenerateToken(), 450)
+	serve(h, http.MethodPost, "/token", token, nil)
+	serve(h, http.MethodGet, "/keys", testMasterKey, nil)
+	serve(h, http.MethodDelete, "/user/"+token, "", nil)
+
+	for _, secret := range []string{token, testMasterKey + "\n", strings.Split(token, ".")[2]} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("the logs contain %q", secret)
+		}
+	}
+}
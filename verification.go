@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// verificationTTL is how long the link to verify the email is valid
+const verificationTTL = 15 * time.Minute
+
+// pendingUser is a user waiting until the link sent to the email is clicked
+type pendingUser struct {
+	UserInfo api.UserInfo
+	Callback string
+	Expires  time.Time
+}
+
+// requestVerification emails the link to add the user instead of adding the user,
+// replacing the link sent to the same email before
+func (controller *Controller) requestVerification(userInfo *api.UserInfo, callback string) api.HandlerMessage {
+	now := time.Now()
+	for code, pu := range controller.PendingUsers {
+		if !now.Before(pu.Expires) || pu.UserInfo.Email == userInfo.Email {
+			delete(controller.PendingUsers, code)
+		}
+	}
+	code := api.GenerateToken()
+	pu := &pendingUser{
+		UserInfo: *userInfo,
+		Callback: callback,
+		Expires:  now.Add(verificationTTL).UTC().Truncate(time.Second),
+	}
+	controller.PendingUsers[code] = pu
+
+	// send the email without blocking the controller
+	link := api.APIProto + api.APIHost + api.APIBasePath + "/user/verify/" + code
+	text := fmt.Sprintf("Hi %v,\r\n\r\nOpen the link and confirm to start using Leubot:\r\n%v\r\n\r\nThe link expires at %v. Ignore this email if you did not add yourself to Leubot.",
+		userInfo.Name, link, pu.Expires.Format(time.RFC3339))
+	mailer := controller.Mailer
+	go func() {
+		if err := mailer.Notify(api.User{Name: userInfo.Name, Email: userInfo.Email}, text); err != nil {
+			log.Printf("[Verification] Failed to email %v: %v", userInfo.Email, err)
+		}
+	}()
+	log.Printf("[Verification] Sent the link to %v (%v)", userInfo.Name, userInfo.Email)
+	return api.HandlerMessage{
+		Type: api.TypeVerificationSent,
+		Value: []interface{}{api.Verification{
+			Email:   userInfo.Email,
+			Expires: pu.Expires,
+		}},
+	}
+}
+
+// verifyUser adds the user who clicked the link as if the email was verified
+func (controller *Controller) verifyUser(code string) api.HandlerMessage {
+	pu, ok := controller.PendingUsers[code]
+	if !ok || !time.Now().Before(pu.Expires) {
+		delete(controller.PendingUsers, code)
+		return api.HandlerMessage{
+			Type:  api.TypeVerificationNotFound,
+			Value: []interface{}{api.Problem{Detail: "The link is unknown or has expired, add the user again"}},
+		}
+	}
+	delete(controller.PendingUsers, code)
+	log.Printf("[Verification] Verified %v (%v)", pu.UserInfo.Name, pu.UserInfo.Email)
+	return controller.handle(api.HandlerMessage{
+		Type:  api.TypeAddUser,
+		Value: []interface{}{pu.UserInfo, pu.Callback, true},
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Interactions-HSG/leubot/api"
+)
+
+// serveSMTP accepts the emails on a local port like an SMTP relay, passing each message on
+func serveSMTP(t *testing.T) (string, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	mails := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tp := textproto.NewConn(conn)
+				tp.PrintfLine("220 localhost ESMTP")
+				for {
+					line, err := tp.ReadLine()
+					if err != nil {
+						return
+					}
+					switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+					case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+						tp.PrintfLine("250 OK")
+					case "DATA":
+						tp.PrintfLine("354 End with <CR><LF>.<CR><LF>")
+						lines, err := tp.ReadDotLines()
+						if err != nil {
+							return
+						}
+						mails <- strings.Join(lines, "\n")
+						tp.PrintfLine("250 OK")
+					case "QUIT":
+						tp.PrintfLine("221 Bye")
+						return
+					default:
+						tp.PrintfLine("502 %v not implemented", verb)
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+// verifyLink returns the code of the link in the email sent to the address
+func verifyLink(t *testing.T, mails chan string, email string) string {
+	t.Helper()
+	select {
+	case mail := <-mails:
+		if !strings.Contains(mail, "To: "+email) {
+			t.Fatalf("the email is not for %v: %v", email, mail)
+		}
+		m := regexp.MustCompile(`/user/verify/(\S+)`).FindStringSubmatch(mail)
+		if m == nil {
+			t.Fatalf("no link in the email: %v", mail)
+		}
+		return m[1]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email sent")
+	}
+	return ""
+}
+
+func TestVerifyEmail(t *testing.T) {
+	addr, mails := serveSMTP(t)
+	verify, smtp := *verifyEmail, *smtpAddr
+	t.Cleanup(func() { *verifyEmail, *smtpAddr = verify, smtp })
+	*verifyEmail, *smtpAddr = true, addr
+	_, h := newTestController(t, nil)
+
+	alice := api.UserRequest{UserInfo: api.UserInfo{Name: "alice", Email: "alice@example.com"}}
+	if rec := serve(h, http.MethodPost, "/user", "", alice); rec.Code != http.StatusAccepted || strings.Contains(rec.Body.String(), "token") {
+		t.Fatalf("POST /user: %v %v", rec.Code, rec.Body)
+	}
+	code := verifyLink(t, mails, "alice@example.com")
+
+	// the mail clients opening the link don't use it up
+	for i := 0; i < 2; i++ {
+		rec := serve(h, http.MethodGet, "/user/verify/"+code, "", nil)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post">`) {
+			t.Fatalf("GET /user/verify: %v %v", rec.Code, rec.Body)
+		}
+		if name := currentUser(t, h).Name; name != "" {
+			t.Fatalf("GET /user/verify added %v", name)
+		}
+	}
+	if rec := serve(h, http.MethodPost, "/user/verify/"+code, "", nil); rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "token") {
+		t.Fatalf("POST /user/verify: %v %v", rec.Code, rec.Body)
+	}
+	if name := currentUser(t, h).Name; name != "alice" {
+		t.Errorf("POST /user/verify added %q, want alice", name)
+	}
+	if rec := serve(h, http.MethodPost, "/user/verify/"+code, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /user/verify again: %v, want 404", rec.Code)
+	}
+}
+
+func TestVerifyEmailReplaced(t *testing.T) {
+	addr, mails := serveSMTP(t)
+	verify, smtp := *verifyEmail, *smtpAddr
+	t.Cleanup(func() { *verifyEmail, *smtpAddr = verify, smtp })
+	*verifyEmail, *smtpAddr = true, addr
+	_, h := newTestController(t, nil)
+
+	// adding the same email again replaces the link
+	alice := api.UserRequest{UserInfo: api.UserInfo{Name: "alice", Email: "alice@example.com"}}
+	serve(h, http.MethodPost, "/user", "", alice)
+	first := verifyLink(t, mails, "alice@example.com")
+	serve(h, http.MethodPost, "/user", "", alice)
+	second := verifyLink(t, mails, "alice@example.com")
+	if rec := serve(h, http.MethodPost, "/user/verify/"+first, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /user/verify with the replaced link: %v, want 404", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/user/verify/"+second, "", nil); rec.Code != http.StatusCreated {
+		t.Errorf("POST /user/verify: %v %v", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPost, "/user/verify/unknown", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /user/verify/unknown: %v, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/kinematics"
+)
+
+// baseZone keeps the forearm and the hand off the base of the arm itself
+var baseZone = api.Zone{
+	Name:   "base",
+	Shape:  api.ShapeCylinder,
+	Center: &kinematics.Point{},
+	Radius: kinematics.BaseRadius,
+	Height: kinematics.BaseHeight,
+}
+
+const (
+	// sampleStep is the distance in mm between the points checked along a link
+	sampleStep = 5.0
+	// pathStep is the largest change in ticks of a joint between the poses checked along a move
+	pathStep = 8
+)
+
+// collision is where a link of the arm enters a keep-out zone
+type collision struct {
+	zone  string
+	link  string
+	point kinematics.Point
+}
+
+// collide returns the first collision of the arm in the pose, nil if there's none
+func (controller *Controller) collide(rp *api.RobotPose) *collision {
+	margin := controller.Workspace.Margin
+	for _, link := range kinematics.Forward(rp.Base, rp.Shoulder, rp.Elbow, rp.WristAngle) {
+		zones := controller.Workspace.Zones
+		// the upper arm stands on the base
+		if link.Name != "upperArm" {
+			zones = append([]api.Zone{baseZone}, zones...)
+		}
+		n := int(link.From.Distance(link.To)/sampleStep) + 1
+		for i := 0; i <= n; i++ {
+			p := link.From.Lerp(link.To, float64(i)/float64(n))
+			for _, zone := range zones {
+				if zone.Contains(p, margin) {
+					return &collision{zone.Name, link.Name, p}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// startPose returns the pose the next move starts from, the robot wakes up at the home position
+func (controller *Controller) startPose() api.RobotPose {
+	if controller.CurrentRobotState == Sleeping {
+		return homePose
+	}
+	return *controller.CurrentRobotPose
+}
+
+// checkCollision returns a Problem if the arm would enter a keep-out zone at the target
+// or on the way there from the startPose
+func (controller *Controller) checkCollision(target api.RobotPose) *api.Problem {
+	return controller.checkPath(controller.startPose(), target)
+}
+
+// checkPath returns a Problem if the arm would enter a keep-out zone at the target
+// or on the way there, moving the joints linearly from the pose
+func (controller *Controller) checkPath(from api.RobotPose, target api.RobotPose) *api.Problem {
+	steps := 1
+	// leaving a keep-out zone is fine as long as the target is clear
+	if controller.collide(&from) == nil {
+		for _, joint := range api.JointNames {
+			d := int(target.Get(joint)) - int(from.Get(joint))
+			if d < 0 {
+				d = -d
+			}
+			if n := d/pathStep + 1; n > steps {
+				steps = n
+			}
+		}
+	}
+	for i := 1; i <= steps; i++ {
+		rp := target
+		if i < steps {
+			for _, joint := range api.JointNames {
+				v := int(from.Get(joint)) + (int(target.Get(joint))-int(from.Get(joint)))*i/steps
+				rp.Set(joint, uint16(v))
+			}
+		}
+		if c := controller.collide(&rp); c != nil {
+			p := &api.Problem{
+				Detail: fmt.Sprintf("The %v would enter the keep-out zone %v at %v mm", c.link, c.zone, c.point),
+				Value:  c.point,
+				Zone:   c.zone,
+			}
+			if i < steps {
+				p.Detail += " on the way to the pose"
+			}
+			return p
+		}
+	}
+	return nil
+}
+
+// checkWorkspace returns a Problem if the workspace is invalid
+func checkWorkspace(ws *api.Workspace) *api.Problem {
+	if ws.Margin < 0 {
+		return &api.Problem{Detail: "The margin must not be negative", Field: "margin", Value: ws.Margin}
+	}
+	names := map[string]bool{baseZone.Name: true}
+	for _, zone := range ws.Zones {
+		if zone.Name == "" || names[zone.Name] {
+			return &api.Problem{Detail: "The zones need unique names other than base", Field: "name", Value: zone.Name}
+		}
+		names[zone.Name] = true
+		switch zone.Shape {
+		case api.ShapePlane:
+		case api.ShapeBox:
+			if zone.Min == nil || zone.Max == nil || zone.Min.X > zone.Max.X || zone.Min.Y > zone.Max.Y || zone.Min.Z > zone.Max.Z {
+				return &api.Problem{Detail: fmt.Sprintf("The box %v needs min and max, min <= max", zone.Name), Field: zone.Name, Value: zone}
+			}
+		case api.ShapeCylinder:
+			if zone.Center == nil || zone.Radius <= 0 || zone.Height <= 0 {
+				return &api.Problem{Detail: fmt.Sprintf("The cylinder %v needs a center, a radius and a height", zone.Name), Field: zone.Name, Value: zone}
+			}
+		default:
+			return &api.Problem{Detail: fmt.Sprintf("The shape of %v must be plane, box or cylinder", zone.Name), Field: zone.Name, Value: zone.Shape}
+		}
+	}
+	return nil
+}
+
+// handleWorkspace processes the messages on the workspace, changing it is only for the admins
+func (controller *Controller) handleWorkspace(msg api.HandlerMessage) api.HandlerMessage {
+	if msg.Type == api.TypeGetWorkspace {
+		return api.HandlerMessage{
+			Type:  api.TypeCurrentWorkspace,
+			Value: []interface{}{controller.Workspace},
+		}
+	}
+
+	token, ok := msg.Value[0].(string)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	if failure := controller.requireAdmin(token); failure != nil {
+		return *failure
+	}
+	ws, ok := msg.Value[1].(api.Workspace)
+	if !ok {
+		return api.HandlerMessage{Type: api.TypeSomethingWentWrong}
+	}
+	if p := checkWorkspace(&ws); p != nil {
+		return api.HandlerMessage{
+			Type:  api.TypeInvalidCommand,
+			Value: []interface{}{*p},
+		}
+	}
+	if ws.Zones == nil {
+		ws.Zones = []api.Zone{}
+	}
+	controller.Workspace = ws
+	log.Printf("[Workspace] Keeping the arm %v mm off %v zones", ws.Margin, len(ws.Zones))
+	return api.HandlerMessage{Type: api.TypeWorkspaceUpdated}
+}
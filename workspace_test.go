@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Interactions-HSG/leubot/api"
+	"github.com/Interactions-HSG/leubot/kinematics"
+)
+
+// movePosture moves the arm to the pose with the token and returns the problem, nil if the move was accepted
+func movePosture(t *testing.T, h http.Handler, token string, rp api.RobotPose) *api.Problem {
+	t.Helper()
+	rec := serve(h, http.MethodPut, "/posture", token, postureCommand(rp))
+	if rec.Code == http.StatusAccepted {
+		return nil
+	}
+	var p api.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("PUT /posture: %v %v", rec.Code, err)
+	}
+	return &p
+}
+
+// dryRun returns the dry run of the move to the pose
+func dryRun(t *testing.T, h http.Handler, token string, rp api.RobotPose) api.PoseCheck {
+	t.Helper()
+	rec := serve(h, http.MethodPost, "/posture/check", token, postureCommand(rp))
+	var check api.PoseCheck
+	if err := json.NewDecoder(rec.Body).Decode(&check); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /posture/check: %v %v", rec.Code, err)
+	}
+	return check
+}
+
+func TestWorkspace(t *testing.T) {
+	_, h := newTestController(t, nil)
+	token := addTestUser(t, h, "alice")
+	// the tip of the gripper pointing down at (200, 0, -20), (150, -150, 60), (0, 200, 60) and (0, -200, 60)
+	low := api.RobotPose{Base: 512, Shoulder: 390, Elbow: 568, WristAngle: 641, WristRotation: 512, Gripper: 128}
+	camera := api.RobotPose{Base: 358, Shoulder: 448, Elbow: 543, WristAngle: 724, WristRotation: 512, Gripper: 128}
+	left := api.RobotPose{Base: 819, Shoulder: 463, Elbow: 562, WristAngle: 721, WristRotation: 512, Gripper: 128}
+	right := api.RobotPose{Base: 205, Shoulder: 463, Elbow: 562, WristAngle: 721, WristRotation: 512, Gripper: 128}
+
+	// nothing is kept out by default but the base of the arm, which ends up at the left
+	for _, rp := range []api.RobotPose{low, camera, right, left} {
+		if p := movePosture(t, h, token, rp); p != nil {
+			t.Errorf("PUT /posture %v: %+v", rp, p)
+		}
+	}
+	if p := movePosture(t, h, token, api.RobotPose{Base: 512, Shoulder: 512, Elbow: 820, WristAngle: 820, WristRotation: 512, Gripper: 128}); p == nil || p.Zone != "base" {
+		t.Errorf("PUT /posture into the base: %+v", p)
+	}
+
+	ws := api.Workspace{Margin: 10, Zones: []api.Zone{
+		{Name: "table", Shape: api.ShapePlane},
+		{Name: "camera", Shape: api.ShapeBox, Min: &kinematics.Point{X: 100, Y: -200, Z: 0}, Max: &kinematics.Point{X: 200, Y: -100, Z: 120}},
+	}}
+	if rec := serve(h, http.MethodPut, "/workspace", token, ws); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /workspace as the user: %v, want 401", rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, "/workspace", testMasterKey, ws); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT /workspace: %v %v", rec.Code, rec.Body)
+	}
+
+	for rp, zone := range map[api.RobotPose]string{low: "table", camera: "camera"} {
+		if p := movePosture(t, h, token, rp); p == nil || p.Zone != zone {
+			t.Errorf("PUT /posture %v: %+v, want %q", rp, p, zone)
+		}
+	}
+
+	// the base sweeps the hand through the camera at the front right from the left to the right
+	if p := movePosture(t, h, token, right); p == nil || p.Zone != "camera" || !strings.HasSuffix(p.Detail, "on the way to the pose") {
+		t.Errorf("PUT /posture from the left to the right: %+v", p)
+	}
+}
+
+func TestInvalidWorkspace(t *testing.T) {
+	_, h := newTestController(t, nil)
+	box := api.Zone{Name: "box", Shape: api.ShapeBox, Min: &kinematics.Point{X: 10, Y: 10, Z: 10}, Max: &kinematics.Point{X: 20, Y: 20, Z: 20}}
+	for name, ws := range map[string]api.Workspace{
+		"negative margin": {Margin: -1},
+		"no name":         {Zones: []api.Zone{{Shape: api.ShapePlane}}},
+		"named base":      {Zones: []api.Zone{{Name: "base", Shape: api.ShapePlane}}},
+		"same names":      {Zones: []api.Zone{box, box}},
+		"min above max":   {Zones: []api.Zone{{Name: "box", Shape: api.ShapeBox, Min: box.Max, Max: box.Min}}},
+		"box without max": {Zones: []api.Zone{{Name: "box", Shape: api.ShapeBox, Min: box.Min}}},
+		"flat cylinder":   {Zones: []api.Zone{{Name: "cylinder", Shape: api.ShapeCylinder, Center: box.Min, Radius: 10}}},
+		"sphere":          {Zones: []api.Zone{{Name: "sphere", Shape: "sphere"}}},
+	} {
+		if rec := serve(h, http.MethodPut, "/workspace", testMasterKey, ws); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT /workspace with %v: %v, want 400", name, rec.Code)
+		}
+	}
+}